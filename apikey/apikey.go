@@ -0,0 +1,252 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/trivy-web-dash/pkg/db"
+	redisx "github.com/trivy-web-dash/pkg/db/redis"
+	"github.com/trivy-web-dash/pkg/logger"
+)
+
+const (
+	keyPrefix   = "apikey/"
+	tokenPrefix = "twd"
+
+	// DefaultRateLimit is the requests per minute of keys issued without one.
+	DefaultRateLimit = 120
+)
+
+// Scopes are ordered, each one includes the ones before it.
+const (
+	ScopeRead  = "read"
+	ScopeScan  = "scan"
+	ScopeAdmin = "admin"
+)
+
+var scopeRank = map[string]int{ScopeRead: 1, ScopeScan: 2, ScopeAdmin: 3}
+
+var (
+	ErrNotFound = errors.New("api key not found")
+	ErrInvalid  = errors.New("invalid api key")
+)
+
+// Key is an issued API key. Only the SHA-256 of its secret is stored; the
+// token "twd_<id>_<secret>" is shown once when the key is created.
+type Key struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	RateLimit  int        `json:"rateLimit"`
+	Hash       string     `json:"hash,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+func (k Key) Validate() error {
+	switch {
+	case k.Name == "":
+		return errors.New("name is required")
+	case scopeRank[k.Scope] == 0:
+		return fmt.Errorf("scope must be one of %s, %s or %s", ScopeRead, ScopeScan, ScopeAdmin)
+	case k.RateLimit < 0:
+		return errors.New("rateLimit must not be negative")
+	}
+	return nil
+}
+
+// Allows reports whether the key's scope includes scope.
+func (k Key) Allows(scope string) bool {
+	return Allows(k.Scope, scope)
+}
+
+func Allows(granted, required string) bool {
+	return scopeRank[granted] >= scopeRank[required] && scopeRank[required] > 0
+}
+
+func (k Key) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+type APIKeyClient struct {
+	client db.Store
+	log    logger.Logger
+}
+
+var apiKeyClient *APIKeyClient
+
+func NewAPIKeyClient(redisURI, redisPass string, redisDB int, redisTLS, redisTLSSkipVerify bool, log logger.Logger) error {
+	pool, err := redisx.NewPool(redisURI, redisPass, redisDB, redisTLS, redisTLSSkipVerify)
+	if err != nil {
+		return err
+	}
+
+	apiKeyClient = &APIKeyClient{client: redisx.NewStore(pool), log: log}
+	return nil
+}
+
+func GetAPIKeyClient() *APIKeyClient {
+	return apiKeyClient
+}
+
+// Create stores k under a new ID and returns it with the token to hand out.
+func (c *APIKeyClient) Create(ctx context.Context, k Key) (Key, string, error) {
+	if k.RateLimit == 0 {
+		k.RateLimit = DefaultRateLimit
+	}
+	if err := k.Validate(); err != nil {
+		return Key{}, "", err
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		return Key{}, "", err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	k.ID = id
+	k.Hash = hash(secret)
+	k.CreatedAt = time.Now().UTC()
+	k.LastUsedAt = nil
+	if err := c.put(ctx, k); err != nil {
+		return Key{}, "", err
+	}
+	return k, tokenPrefix + "_" + id + "_" + secret, nil
+}
+
+// Verify returns the key a token belongs to if the token is valid and the key
+// has not expired.
+func (c *APIKeyClient) Verify(ctx context.Context, token string) (Key, error) {
+	parts := strings.Split(token, "_")
+	if len(parts) != 3 || parts[0] != tokenPrefix {
+		return Key{}, ErrInvalid
+	}
+
+	k, err := c.Get(ctx, parts[1])
+	if errors.Is(err, ErrNotFound) {
+		return Key{}, ErrInvalid
+	}
+	if err != nil {
+		return Key{}, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hash(parts[2]))) != 1 || k.Expired(time.Now()) {
+		return Key{}, ErrInvalid
+	}
+	return k, nil
+}
+
+// Touch records that a key was used, at most once a minute. A key deleted
+// since it was verified stays deleted. Failures are only logged.
+func (c *APIKeyClient) Touch(ctx context.Context, k Key) {
+	now := time.Now().UTC()
+	if k.LastUsedAt != nil && now.Sub(*k.LastUsedAt) < time.Minute {
+		return
+	}
+
+	current, err := c.Get(ctx, k.ID)
+	if errors.Is(err, ErrNotFound) {
+		return
+	}
+	if err != nil {
+		c.log.Errorf("unable to update api key %s : %v", k.ID, err)
+		return
+	}
+	current.LastUsedAt = &now
+	b, err := json.Marshal(current)
+	if err == nil {
+		_, err = c.client.WithContext(ctx).Replace(keyPrefix+k.ID, b)
+	}
+	if err != nil {
+		c.log.Errorf("unable to update api key %s : %v", k.ID, err)
+	}
+}
+
+func (c *APIKeyClient) Get(ctx context.Context, id string) (Key, error) {
+	b, _, err := c.client.WithContext(ctx).GetwithTTL(keyPrefix + id)
+	if errors.Is(err, redis.ErrNil) {
+		return Key{}, ErrNotFound
+	}
+	if err != nil {
+		c.log.Error(err)
+		return Key{}, err
+	}
+
+	var k Key
+	if err := json.Unmarshal(b, &k); err != nil {
+		return Key{}, err
+	}
+	return k, nil
+}
+
+func (c *APIKeyClient) Delete(ctx context.Context, id string) error {
+	if _, err := c.Get(ctx, id); err != nil {
+		return err
+	}
+	return c.client.WithContext(ctx).Delete(keyPrefix + id)
+}
+
+// List returns every key without its hash.
+func (c *APIKeyClient) List(ctx context.Context) ([]Key, error) {
+	ids, err := c.client.WithContext(ctx).GetAllKeys(keyPrefix + "*")
+	if err != nil {
+		c.log.Error(err)
+		return nil, err
+	}
+
+	keys := []Key{}
+	for _, id := range ids {
+		k, err := c.Get(ctx, strings.TrimPrefix(id, keyPrefix))
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		k.Hash = ""
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (c *APIKeyClient) put(ctx context.Context, k Key) error {
+	b, err := json.Marshal(k)
+	if err != nil {
+		return err
+	}
+	if err := c.client.WithContext(ctx).Set(keyPrefix+k.ID, b); err != nil {
+		c.log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// hash needs no salt or stretching, secrets are 192 random bits.
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,153 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/trivy-web-dash/pkg/logger"
+)
+
+func newTestClient(t *testing.T) *APIKeyClient {
+	t.Helper()
+	redis := miniredis.RunT(t)
+	log := logger.NewAppLogger("fatal")
+	log.InitLogger()
+	if err := NewAPIKeyClient(redis.Addr(), "", 8, false, false, log); err != nil {
+		t.Fatal(err)
+	}
+	return GetAPIKeyClient()
+}
+
+func TestCreateAndVerify(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	k, token, err := c.Create(ctx, Key{Name: "ci", Scope: ScopeScan})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(token, "twd_"+k.ID+"_") || k.RateLimit != DefaultRateLimit || k.Hash == "" || strings.Contains(token, k.Hash) {
+		t.Errorf("Create() = %+v, %q", k, token)
+	}
+
+	got, err := c.Verify(ctx, token)
+	if err != nil || got.ID != k.ID || got.Scope != ScopeScan {
+		t.Errorf("Verify() = %+v, %v", got, err)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	_, expired, err := c.Create(ctx, Key{Name: "old", Scope: ScopeRead, ExpiresAt: &past})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{
+		"wrong secret": token[:len(token)-1] + "x",
+		"unknown id":   "twd_0000000000000000_" + strings.Split(token, "_")[2],
+		"malformed":    "twd_" + k.ID,
+		"other prefix": strings.Replace(token, "twd_", "abc_", 1),
+		"expired":      expired,
+	} {
+		if _, err := c.Verify(ctx, token); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Verify() with %s = %v, want ErrInvalid", name, err)
+		}
+	}
+}
+
+func TestCreateValidation(t *testing.T) {
+	c := newTestClient(t)
+	for _, k := range []Key{
+		{Scope: ScopeRead},
+		{Name: "ci", Scope: "owner"},
+		{Name: "ci"},
+		{Name: "ci", Scope: ScopeRead, RateLimit: -1},
+	} {
+		if _, _, err := c.Create(context.Background(), k); err == nil {
+			t.Errorf("Create(%+v) succeeded", k)
+		}
+	}
+}
+
+func TestListTouchDelete(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	first, _, err := c.Create(ctx, Key{Name: "first", Scope: ScopeRead, RateLimit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.Create(ctx, Key{Name: "second", Scope: ScopeAdmin}); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Touch(ctx, first)
+	touched, err := c.Get(ctx, first.ID)
+	if err != nil || touched.LastUsedAt == nil {
+		t.Fatalf("Touch() did not record the use: %+v, %v", touched, err)
+	}
+	// a key used within the minute is not written again
+	c.Touch(ctx, touched)
+	if again, _ := c.Get(ctx, first.ID); !again.LastUsedAt.Equal(*touched.LastUsedAt) {
+		t.Errorf("Touch() updated a key used %s ago", time.Since(*touched.LastUsedAt))
+	}
+
+	keys, err := c.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].Name != "first" || keys[1].Name != "second" || keys[0].Hash != "" || keys[1].Hash != "" {
+		t.Errorf("List() = %+v", keys)
+	}
+
+	if err := c.Delete(ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(ctx, first.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete() = %v", err)
+	}
+	if keys, _ := c.List(ctx); len(keys) != 1 {
+		t.Errorf("List() after Delete() = %+v", keys)
+	}
+
+	// a key deleted while its request was served is not written back
+	_, token, err := c.Create(ctx, Key{Name: "revoked", Scope: ScopeScan})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified, err := c.Verify(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(ctx, verified.ID); err != nil {
+		t.Fatal(err)
+	}
+	c.Touch(ctx, verified)
+	if _, err := c.Verify(ctx, token); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Verify() of a key touched after its deletion = %v", err)
+	}
+}
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		granted, required string
+		want              bool
+	}{
+		{ScopeAdmin, ScopeRead, true},
+		{ScopeAdmin, ScopeAdmin, true},
+		{ScopeScan, ScopeRead, true},
+		{ScopeScan, ScopeAdmin, false},
+		{ScopeRead, ScopeScan, false},
+		{"", ScopeRead, false},
+		{ScopeAdmin, "", false},
+		{ScopeAdmin, "owner", false},
+	}
+	for _, tt := range tests {
+		if got := Allows(tt.granted, tt.required); got != tt.want {
+			t.Errorf("Allows(%q, %q) = %v, want %v", tt.granted, tt.required, got, tt.want)
+		}
+	}
+}
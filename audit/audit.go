@@ -0,0 +1,152 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/trivy-web-dash/pkg/db"
+	redisx "github.com/trivy-web-dash/pkg/db/redis"
+	"github.com/trivy-web-dash/pkg/logger"
+)
+
+// logKey is the redis list the entries are appended to. Nothing in the
+// application updates or removes entries.
+const logKey = "audit/log"
+
+// Actions recorded in the audit log.
+const (
+	ScanSubmit      = "scan.submit"
+	SBOMSubmit      = "sbom.submit"
+	PushEvent       = "scan.push_event"
+	RegistryCrawl   = "registry.crawl"
+	ExceptionCreate = "exception.create"
+	ExceptionDelete = "exception.delete"
+	VEXUpload       = "vex.upload"
+	VEXDelete       = "vex.delete"
+	RegoPut         = "rego.put"
+	RegoDelete      = "rego.delete"
+	APIKeyCreate    = "apikey.create"
+	APIKeyDelete    = "apikey.delete"
+)
+
+// Entry is one state-changing action. Before and After hold the affected
+// object as it was and as it became, either may be empty.
+type Entry struct {
+	Time     time.Time       `json:"time"`
+	Actor    string          `json:"actor"`
+	KeyID    string          `json:"keyId,omitempty"`
+	SourceIP string          `json:"sourceIp"`
+	Action   string          `json:"action"`
+	Project  string          `json:"project,omitempty"`
+	Target   string          `json:"target"`
+	Before   json.RawMessage `json:"before,omitempty"`
+	After    json.RawMessage `json:"after,omitempty"`
+}
+
+// Filter selects entries. Zero fields match everything, Limit keeps the most
+// recent entries.
+type Filter struct {
+	Actor   string
+	Action  string
+	Target  string
+	Project string
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+}
+
+func (f Filter) matches(e Entry) bool {
+	switch {
+	case f.Actor != "" && e.Actor != f.Actor:
+		return false
+	case f.Action != "" && e.Action != f.Action:
+		return false
+	case f.Target != "" && e.Target != f.Target:
+		return false
+	case f.Project != "" && e.Project != f.Project:
+		return false
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+type AuditClient struct {
+	client db.Store
+	log    logger.Logger
+}
+
+var auditClient *AuditClient
+
+func NewAuditClient(redisURI, redisPass string, redisDB int, redisTLS, redisTLSSkipVerify bool, log logger.Logger) error {
+	pool, err := redisx.NewPool(redisURI, redisPass, redisDB, redisTLS, redisTLSSkipVerify)
+	if err != nil {
+		return err
+	}
+
+	auditClient = &AuditClient{client: redisx.NewStore(pool), log: log}
+	return nil
+}
+
+func GetAuditClient() *AuditClient {
+	return auditClient
+}
+
+// Record appends e, stamping it with the current time when it has none.
+func (c *AuditClient) Record(ctx context.Context, e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := c.client.WithContext(ctx).Append(logKey, b); err != nil {
+		c.log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// Query returns the entries matching f, oldest first.
+func (c *AuditClient) Query(ctx context.Context, f Filter) ([]Entry, error) {
+	values, err := c.client.WithContext(ctx).Range(logKey, 0, -1)
+	if err != nil {
+		c.log.Error(err)
+		return nil, err
+	}
+
+	entries := []Entry{}
+	for _, v := range values {
+		var e Entry
+		if err := json.Unmarshal(v, &e); err != nil {
+			c.log.Errorf("skipping malformed audit entry : %v", err)
+			continue
+		}
+		if f.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+
+	if f.Limit > 0 && len(entries) > f.Limit {
+		entries = entries[len(entries)-f.Limit:]
+	}
+	return entries, nil
+}
+
+// Value marshals an object for Entry.Before or Entry.After. Nil yields an
+// empty value.
+func Value(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
@@ -0,0 +1,97 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/trivy-web-dash/pkg/logger"
+)
+
+func TestQuery(t *testing.T) {
+	redis := miniredis.RunT(t)
+	log := logger.NewAppLogger("fatal")
+	log.InitLogger()
+	if err := NewAuditClient(redis.Addr(), "", 9, false, false, log); err != nil {
+		t.Fatal(err)
+	}
+	c := GetAuditClient()
+	ctx := context.Background()
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, e := range []Entry{
+		{Actor: "ada@example.com", Action: ScanSubmit, Project: "payments", Target: "app:1.0"},
+		{Actor: "ci", KeyID: "k1", Action: ScanSubmit, Target: "app:1.1"},
+		{Actor: "ada@example.com", Action: ExceptionCreate, Project: "payments", Target: "CVE-2024-0727", After: Value(map[string]string{"owner": "ada"})},
+		{Actor: "admin", Action: APIKeyDelete, Target: "k1", Before: Value(map[string]string{"name": "ci"})},
+	} {
+		e.Time = start.Add(time.Duration(i) * time.Hour)
+		if err := c.Record(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// entries are never rewritten, a malformed one is skipped
+	redis.RPush("audit/log", "{")
+
+	tests := []struct {
+		name    string
+		f       Filter
+		targets []string
+	}{
+		{"all, oldest first", Filter{}, []string{"app:1.0", "app:1.1", "CVE-2024-0727", "k1"}},
+		{"actor", Filter{Actor: "ada@example.com"}, []string{"app:1.0", "CVE-2024-0727"}},
+		{"action", Filter{Action: ScanSubmit}, []string{"app:1.0", "app:1.1"}},
+		{"target", Filter{Target: "k1"}, []string{"k1"}},
+		{"project", Filter{Project: "payments", Action: ExceptionCreate}, []string{"CVE-2024-0727"}},
+		{"since is inclusive", Filter{Since: start.Add(2 * time.Hour)}, []string{"CVE-2024-0727", "k1"}},
+		{"until is exclusive", Filter{Until: start.Add(time.Hour)}, []string{"app:1.0"}},
+		{"limit keeps the most recent", Filter{Limit: 2}, []string{"CVE-2024-0727", "k1"}},
+		{"nothing", Filter{Actor: "nobody"}, nil},
+	}
+	for _, tt := range tests {
+		entries, err := c.Query(ctx, tt.f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var targets []string
+		for _, e := range entries {
+			targets = append(targets, e.Target)
+		}
+		if len(targets) != len(tt.targets) {
+			t.Errorf("%s: Query() = %q, want %q", tt.name, targets, tt.targets)
+			continue
+		}
+		for i := range targets {
+			if targets[i] != tt.targets[i] {
+				t.Errorf("%s: Query() = %q, want %q", tt.name, targets, tt.targets)
+				break
+			}
+		}
+	}
+
+	entries, _ := c.Query(ctx, Filter{Action: APIKeyDelete})
+	if len(entries) != 1 || string(entries[0].Before) != `{"name":"ci"}` || entries[0].After != nil {
+		t.Errorf("Query() = %+v", entries)
+	}
+
+	if err := c.Record(ctx, Entry{Actor: "admin", Action: RegoPut, Target: "deny-root"}); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := c.Query(ctx, Filter{Action: RegoPut}); len(entries) != 1 || time.Since(entries[0].Time) > time.Minute {
+		t.Errorf("Record() without a time = %+v", entries)
+	}
+}
+
+func TestValue(t *testing.T) {
+	if v := Value(nil); v != nil {
+		t.Errorf("Value(nil) = %s", v)
+	}
+	if v := Value(func() {}); v != nil {
+		t.Errorf("Value() of an unmarshalable object = %s", v)
+	}
+	if v := Value(map[string]int{"n": 1}); string(v) != `{"n":1}` {
+		t.Errorf("Value() = %s", v)
+	}
+}
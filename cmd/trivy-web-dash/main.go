@@ -0,0 +1,240 @@
+// Command trivy-web-dash submits image scans to a dashboard, waits for them to
+// finish and fails when the findings break a threshold, for use in CI.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/trivy-web-dash/pkg/client"
+	"github.com/trivy-web-dash/pkg/policy"
+	"github.com/trivy-web-dash/types"
+)
+
+const (
+	exitPass      = 0
+	exitViolation = 1
+	exitError     = 2
+)
+
+const usage = `usage: trivy-web-dash <command> [flags] IMAGE
+
+commands:
+  scan    queue a scan of IMAGE, wait for it and print its findings
+  report  print the findings of the latest report of IMAGE
+
+The exit status is 0 when the findings pass, 1 when they break the policy or
+-fail-on threshold and 2 on errors or failed scans.
+`
+
+type options struct {
+	server    string
+	token     string
+	project   string
+	policy    string
+	failOn    string
+	format    string
+	platforms string
+	engine    string
+	timeout   time.Duration
+	interval  time.Duration
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || (args[0] != "scan" && args[0] != "report") {
+		fmt.Fprint(stderr, usage)
+		return exitError
+	}
+	command := args[0]
+
+	var o options
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&o.server, "server", envOr("TRIVY_WEB_DASH_URL", "http://localhost:8001"), "dashboard URL")
+	fs.StringVar(&o.token, "token", os.Getenv("TRIVY_WEB_DASH_TOKEN"), "API key")
+	fs.StringVar(&o.project, "project", os.Getenv("TRIVY_WEB_DASH_PROJECT"), "project, the default project when empty")
+	fs.StringVar(&o.policy, "policy", "", "policy to evaluate, the default policy when empty")
+	fs.StringVar(&o.failOn, "fail-on", "", "fail on unsuppressed findings of this severity or higher")
+	fs.StringVar(&o.format, "format", "table", "output format, table or json")
+	if command == "scan" {
+		fs.StringVar(&o.platforms, "platforms", "", "comma separated os/arch list or all")
+		fs.StringVar(&o.engine, "engine", "", "scan engine, trivy, grype or both, the server's default when empty")
+		fs.DurationVar(&o.timeout, "timeout", 30*time.Minute, "how long to wait for the scan")
+		fs.DurationVar(&o.interval, "interval", 5*time.Second, "how often to poll the scan status")
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprint(stderr, usage)
+		return exitError
+	}
+	image := fs.Arg(0)
+
+	o.failOn = strings.ToUpper(o.failOn)
+	if o.failOn != "" && types.SeverityRank(o.failOn) == 0 && o.failOn != "UNKNOWN" {
+		fmt.Fprintf(stderr, "unknown severity %s\n", o.failOn)
+		return exitError
+	}
+	if o.format != "table" && o.format != "json" {
+		fmt.Fprintf(stderr, "unknown format %s\n", o.format)
+		return exitError
+	}
+
+	c := client.New(o.server, o.token, o.project)
+	ctx := context.Background()
+
+	images := []string{image}
+	if command == "scan" {
+		var err error
+		images, err = scan(ctx, c, image, o, stderr)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	}
+
+	code := exitPass
+	for _, image := range images {
+		passed, err := show(ctx, c, image, o, stdout)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		if !passed {
+			code = exitViolation
+		}
+	}
+	return code
+}
+
+// scan queues image, waits for every platform job to finish and returns the
+// scanned image references.
+func scan(ctx context.Context, c *client.Client, image string, o options, progress io.Writer) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	jobs, err := c.Scan(ctx, image, o.platforms, o.engine)
+	if err != nil {
+		return nil, err
+	}
+
+	platforms := make([]string, 0, len(jobs))
+	for platform := range jobs {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+
+	var images []string
+	for _, platform := range platforms {
+		name := image
+		if platform != "" {
+			name += " (" + platform + ")"
+		}
+		start := time.Now()
+		s, err := c.Wait(ctx, jobs[platform], o.policy, o.interval, func(s client.Status) {
+			fmt.Fprintf(progress, "%s: %s after %s\n", name, s.Status, time.Since(start).Round(time.Second))
+		})
+		if err != nil {
+			return nil, err
+		}
+		if s.Failed() {
+			return nil, fmt.Errorf("scan of %s failed: %s", name, s.Error)
+		}
+		if s.Image == "" {
+			return nil, errors.New("scan of " + name + " finished without a report")
+		}
+		images = append(images, s.Image)
+	}
+	return images, nil
+}
+
+// show prints the findings of image and reports whether they pass both the
+// policy and the -fail-on threshold.
+func show(ctx context.Context, c *client.Client, image string, o options, out io.Writer) (bool, error) {
+	r, err := c.Report(ctx, image)
+	if err != nil {
+		return false, err
+	}
+	v, err := c.Verdict(ctx, image, o.policy)
+	if err != nil {
+		return false, err
+	}
+
+	breaches := 0
+	if o.failOn != "" {
+		for _, res := range r.Results {
+			for _, vuln := range res.Vulnerabilities {
+				if !vuln.Suppressed() && types.SeverityRank(vuln.Severity) >= types.SeverityRank(o.failOn) {
+					breaches++
+				}
+			}
+		}
+	}
+
+	if o.format == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(struct {
+			Image   string         `json:"image"`
+			Report  types.Report   `json:"report"`
+			Verdict policy.Verdict `json:"verdict"`
+		}{image, r, v})
+	} else {
+		err = printTable(out, image, r, v, o.failOn, breaches)
+	}
+	return v.Pass && breaches == 0, err
+}
+
+func printTable(out io.Writer, image string, r types.Report, v policy.Verdict, failOn string, breaches int) error {
+	fmt.Fprintf(out, "%s\n\n", image)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tLIBRARY\tVULNERABILITY\tSEVERITY\tINSTALLED\tFIXED\tTITLE")
+	for _, res := range r.Results {
+		for _, vuln := range res.Vulnerabilities {
+			if vuln.Suppressed() {
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", res.Target, vuln.PkgName, vuln.VulnerabilityID,
+				vuln.Severity, vuln.InstalledVersion, vuln.FixedVersion, vuln.Title)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out)
+	if v.Pass {
+		fmt.Fprintf(out, "policy %s: pass\n", v.Policy)
+	} else {
+		fmt.Fprintf(out, "policy %s: fail\n", v.Policy)
+		for _, violation := range v.Violations {
+			fmt.Fprintf(out, "  %s: %s\n", violation.Rule, violation.Message)
+		}
+	}
+	if failOn != "" {
+		fmt.Fprintf(out, "%d unsuppressed findings at %s or higher\n", breaches, failOn)
+	}
+	return nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
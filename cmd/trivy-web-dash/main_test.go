@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const report = `{
+  "ArtifactName": "alpine:3.19",
+  "Results": [{
+    "Target": "alpine:3.19 (alpine 3.19.1)",
+    "Vulnerabilities": [
+      {"VulnerabilityID": "CVE-2024-0727", "PkgName": "libcrypto3", "InstalledVersion": "3.1.4-r2", "FixedVersion": "3.1.4-r5", "Severity": "MEDIUM"},
+      {"VulnerabilityID": "CVE-2023-5363", "PkgName": "libssl3", "InstalledVersion": "3.1.4-r2", "Severity": "HIGH",
+       "Suppression": {"Source": "exception", "RuleID": "r1", "Justification": "not reachable"}}
+    ]
+  }]
+}`
+
+// newDashboard fakes the API for image alpine:3.19, whose scan ends in status
+// and whose verdict under policy "strict" fails.
+func newDashboard(t *testing.T, status string) string {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/scan/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ID": "a"}`))
+	})
+	mux.HandleFunc("/scan/status/a", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"id": "a", "status": status, "error": "manifest unknown", "image": "alpine:3.19"})
+	})
+	mux.HandleFunc("/api/v1/images/alpine:3.19/report", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(report))
+	})
+	mux.HandleFunc("/api/v1/images/alpine:3.19/verdict", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("policy") == "strict" {
+			w.Write([]byte(`{"policy": "strict", "pass": false, "violations": [{"rule": "max_medium", "message": "1 MEDIUM findings, 0 allowed"}]}`))
+			return
+		}
+		w.Write([]byte(`{"policy": "default", "pass": true}`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestRun(t *testing.T) {
+	server := newDashboard(t, "Done")
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stdout []string
+	}{
+		{"scan passes", []string{"scan", "-interval", "1ms", "alpine:3.19"}, exitPass, []string{"CVE-2024-0727", "policy default: pass"}},
+		{"suppressed findings below threshold", []string{"report", "-fail-on", "high", "alpine:3.19"}, exitPass, []string{"0 unsuppressed findings at HIGH or higher"}},
+		{"threshold broken", []string{"report", "-fail-on", "medium", "alpine:3.19"}, exitViolation, []string{"1 unsuppressed findings at MEDIUM or higher"}},
+		{"policy broken", []string{"report", "-policy", "strict", "alpine:3.19"}, exitViolation, []string{"policy strict: fail", "max_medium: 1 MEDIUM findings, 0 allowed"}},
+		{"json", []string{"report", "-format", "json", "alpine:3.19"}, exitPass, []string{`"image": "alpine:3.19"`, `"pass": true`}},
+		{"no command", nil, exitError, nil},
+		{"no image", []string{"scan"}, exitError, nil},
+		{"unknown severity", []string{"report", "-fail-on", "urgent", "alpine:3.19"}, exitError, nil},
+		{"unknown format", []string{"report", "-format", "xml", "alpine:3.19"}, exitError, nil},
+		{"scan flag on report", []string{"report", "-platforms", "all", "alpine:3.19"}, exitError, nil},
+		{"unknown image", []string{"report", "nginx:1.25"}, exitError, nil},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		args := tt.args
+		if len(args) > 0 {
+			args = append([]string{args[0], "-server", server}, args[1:]...)
+		}
+		if code := run(args, &stdout, &stderr); code != tt.code {
+			t.Errorf("%s: run() = %d, stderr %s", tt.name, code, stderr.String())
+		}
+		for _, s := range tt.stdout {
+			if !strings.Contains(stdout.String(), s) {
+				t.Errorf("%s: output misses %q:\n%s", tt.name, s, stdout.String())
+			}
+		}
+		if !strings.Contains(strings.Join(tt.args, " "), "json") && strings.Contains(stdout.String(), "CVE-2023-5363") {
+			t.Errorf("%s: table lists a suppressed finding", tt.name)
+		}
+	}
+}
+
+func TestRunFailedScan(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"scan", "-server", newDashboard(t, "ScanFail"), "-interval", "1ms", "alpine:3.19"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run() = %d", code)
+	}
+	if !strings.Contains(stderr.String(), "alpine:3.19: ScanFail") || !strings.Contains(stderr.String(), "scan of alpine:3.19 failed: manifest unknown") {
+		t.Errorf("stderr = %s", stderr.String())
+	}
+}
+
+// A failed webhook delivery is the project's problem, the scan has a report.
+func TestRunWebhookFail(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"scan", "-server", newDashboard(t, "WebhookFail"), "-interval", "1ms", "alpine:3.19"}, &stdout, &stderr); code != exitPass {
+		t.Errorf("run() = %d, stderr %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "CVE-2024-0727") {
+		t.Errorf("stdout = %s", stdout.String())
+	}
+}
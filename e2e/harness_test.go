@@ -0,0 +1,186 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+
+	"github.com/trivy-web-dash/apikey"
+	"github.com/trivy-web-dash/audit"
+	"github.com/trivy-web-dash/exception"
+	"github.com/trivy-web-dash/pkg/auth"
+	"github.com/trivy-web-dash/pkg/client"
+	"github.com/trivy-web-dash/pkg/config"
+	redisx "github.com/trivy-web-dash/pkg/db/redis"
+	"github.com/trivy-web-dash/pkg/grype"
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/pkg/osmgr/osmgrtest"
+	"github.com/trivy-web-dash/pkg/policy"
+	"github.com/trivy-web-dash/pkg/project"
+	"github.com/trivy-web-dash/pkg/queue"
+	"github.com/trivy-web-dash/pkg/rbac"
+	"github.com/trivy-web-dash/pkg/registry"
+	trivy "github.com/trivy-web-dash/pkg/trivy"
+	scanner "github.com/trivy-web-dash/pkg/trivy/controller"
+	"github.com/trivy-web-dash/pkg/trivy/handler"
+	"github.com/trivy-web-dash/regopolicy"
+	"github.com/trivy-web-dash/report"
+	"github.com/trivy-web-dash/summary"
+	"github.com/trivy-web-dash/types"
+	"github.com/trivy-web-dash/vex"
+)
+
+// waitTimeout bounds how long a test waits for a job to finish.
+const waitTimeout = 15 * time.Second
+
+// env is the dashboard API, queue and worker running in-process against an
+// in-memory redis, with trivy and grype played by an osmgrtest.Mgr.
+type env struct {
+	t      *testing.T
+	mgr    *osmgrtest.Mgr
+	worker queue.Worker
+	server *httptest.Server
+	client *client.Client
+}
+
+func newEnv(t *testing.T, projects ...project.Project) *env {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	redis := miniredis.RunT(t)
+	cfg := config.Default()
+	addr, db := redis.Addr(), cfg.Redis.DB
+	log := logger.NewAppLogger("fatal")
+	log.InitLogger()
+
+	for _, err := range []error{
+		report.NewReportClient(addr, "", db.Reports, false, false, cfg.Reports.TTL, log),
+		summary.NewSummaryClient(addr, "", db.Summaries, false, false, cfg.Reports.TTL, log),
+		exception.NewExceptionClient(addr, "", db.Exceptions, false, false, log),
+		vex.NewVEXClient(addr, "", db.VEX, false, false, log),
+		regopolicy.NewRegoClient(addr, "", db.Rego, false, false, log),
+		audit.NewAuditClient(addr, "", db.Audit, false, false, log),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	rbac.SetPolicy(nil)
+
+	pool, err := redisx.NewPool(addr, "", db.Jobs, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := redisx.NewStore(pool)
+	projectSet := project.NewSet(projects...)
+	enqueuer := queue.NewEnqueuer(pool, store, projectSet)
+
+	mgr := osmgrtest.New()
+	engines := map[string]trivy.Scanner{
+		types.EngineTrivy: trivy.NewTrivyClientWithMgr(log, "http://trivy:4954", mgr),
+		types.EngineGrype: grype.NewClientWithMgr(log, mgr),
+	}
+	controller := scanner.NewController(store, engines, types.EngineTrivy, log)
+	worker := queue.NewWorker(pool, controller, nil, 2, log)
+
+	h := handler.NewHandler(log, enqueuer, store, policy.NewSet(), registry.NewCrawler(nil, enqueuer, log), projectSet)
+	authn := auth.New(false, "", nil, log)
+	read, scan := authn.Require(apikey.ScopeRead), authn.Require(apikey.ScopeScan)
+	inProject := projectSet.Select()
+
+	r := gin.New()
+	r.POST("/scan/image", scan, inProject, h.AcceptScanRequest)
+	r.POST("/scan/sbom", scan, inProject, h.AcceptSBOMScanRequest)
+	r.GET("/scan/status/:id", read, inProject, h.GetScanStatusForJob)
+	r.GET("/api/v1/images/*path", read, inProject, h.GetImageResource)
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	return &env{
+		t:      t,
+		mgr:    mgr,
+		worker: worker,
+		server: server,
+		client: client.New(server.URL, "", ""),
+	}
+}
+
+// run starts the worker on the jobs queued so far and stops it when the test
+// ends. Starting after the jobs are queued spares waiting for the next poll.
+func (e *env) run() {
+	e.worker.Start()
+	e.t.Cleanup(e.worker.Stop)
+}
+
+// wait polls job id until it finished and returns every status it was seen in.
+func (e *env) wait(id string) (client.Status, []string) {
+	e.t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+
+	var seen []string
+	s, err := e.client.Wait(ctx, id, "", 10*time.Millisecond, func(s client.Status) {
+		seen = append(seen, s.Status)
+	})
+	if err != nil {
+		e.t.Fatalf("waiting for job %s: %v", id, err)
+	}
+	return s, seen
+}
+
+// uploadSBOM posts doc to /scan/sbom and returns the job ID.
+func (e *env) uploadSBOM(doc interface{}, engine string) string {
+	e.t.Helper()
+	b, err := json.Marshal(doc)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	if engine != "" {
+		w.WriteField("engine", engine)
+	}
+	part, _ := w.CreateFormFile("sbom", "image.cdx.json")
+	part.Write(b)
+	w.Close()
+
+	resp, err := http.Post(e.server.URL+"/scan/sbom", w.FormDataContentType(), &body)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		ID    string `json:"ID"`
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&out)
+	if resp.StatusCode != http.StatusOK {
+		e.t.Fatalf("POST /scan/sbom: %d %s", resp.StatusCode, out.Error)
+	}
+	return out.ID
+}
+
+// summaries returns the severity counts of every image of the default
+// project.
+func summaries(t *testing.T) map[string]map[string]int {
+	t.Helper()
+	all, err := summary.GetSummaryClient().GetAll(context.Background(), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := map[string]map[string]int{}
+	for _, s := range all {
+		m[s.Image] = s.VSummary
+	}
+	return m
+}
@@ -0,0 +1,332 @@
+package e2e
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trivy-web-dash/pkg/job"
+	"github.com/trivy-web-dash/pkg/osmgr/osmgrtest"
+	"github.com/trivy-web-dash/pkg/project"
+	"github.com/trivy-web-dash/types"
+)
+
+const image = "registry.example.com/app:1.0"
+
+func trivyReport(vulns ...types.Vulnerability) types.Report {
+	return types.Report{
+		ArtifactName: image,
+		ArtifactType: "container_image",
+		Results: []types.Result{{
+			Target:          image + " (alpine 3.19.1)",
+			Class:           "os-pkgs",
+			Type:            "alpine",
+			Vulnerabilities: vulns,
+		}},
+	}
+}
+
+func vuln(id, pkg, severity, fixed string) types.Vulnerability {
+	return types.Vulnerability{
+		VulnerabilityID:  id,
+		PkgName:          pkg,
+		InstalledVersion: "1.0.0",
+		FixedVersion:     fixed,
+		Severity:         severity,
+	}
+}
+
+// grypeReport is the JSON grype writes for a single apk match.
+func grypeReport(id, pkg, severity string) map[string]interface{} {
+	return map[string]interface{}{
+		"source": map[string]interface{}{
+			"type":   "image",
+			"target": map[string]interface{}{"userInput": image},
+		},
+		"distro": map[string]interface{}{"name": "alpine", "version": "3.19.1"},
+		"matches": []interface{}{map[string]interface{}{
+			"vulnerability": map[string]interface{}{
+				"id":       id,
+				"severity": severity,
+				"fix":      map[string]interface{}{"versions": []string{"1.0.1"}},
+			},
+			"artifact": map[string]interface{}{"name": pkg, "version": "1.0.0", "type": "apk"},
+		}},
+	}
+}
+
+func TestScanPipeline(t *testing.T) {
+	vulnerable := trivyReport(
+		vuln("CVE-2024-0001", "openssl", "CRITICAL", "1.0.1"),
+		vuln("CVE-2024-0002", "busybox", "HIGH", ""),
+	)
+
+	tests := []struct {
+		name      string
+		platforms string
+		engine    string
+		trivy     osmgrtest.Run
+		grype     *osmgrtest.Run
+
+		status      job.ScanJobStatus
+		errContains string
+		pass        bool
+		found       int
+		summary     map[string]int
+		seenPending bool
+		check       func(t *testing.T, r types.Report)
+	}{
+		{
+			name:    "report stored",
+			trivy:   osmgrtest.Run{Report: vulnerable},
+			status:  job.Done,
+			found:   2,
+			summary: map[string]int{"CRITICAL": 1, "HIGH": 1},
+			check: func(t *testing.T, r types.Report) {
+				if r.EngineName() != types.EngineTrivy {
+					t.Errorf("engine = %q, want %q", r.EngineName(), types.EngineTrivy)
+				}
+			},
+		},
+		{
+			name:    "clean image",
+			trivy:   osmgrtest.Run{Report: trivyReport()},
+			status:  job.Done,
+			pass:    true,
+			summary: map[string]int{},
+		},
+		{
+			name:        "trivy fails",
+			trivy:       osmgrtest.Run{Err: errors.New("exit status 1"), Stdout: "FATAL image not found"},
+			status:      job.ScanFail,
+			errContains: "FATAL image not found",
+		},
+		{
+			name:        "malformed report",
+			trivy:       osmgrtest.Run{Report: "{not json"},
+			status:      job.ScanFail,
+			errContains: "decoding scan report",
+		},
+		{
+			name:        "slow scan",
+			trivy:       osmgrtest.Run{Report: vulnerable, Delay: 500 * time.Millisecond},
+			status:      job.Done,
+			found:       2,
+			summary:     map[string]int{"CRITICAL": 1, "HIGH": 1},
+			seenPending: true,
+		},
+		{
+			name:      "multi-platform",
+			platforms: "linux/amd64,linux/arm64",
+			trivy:     osmgrtest.Run{Report: vulnerable},
+			status:    job.Done,
+			found:     2,
+			summary:   map[string]int{"CRITICAL": 1, "HIGH": 1},
+			check: func(t *testing.T, r types.Report) {
+				if len(r.Platforms) != 2 {
+					t.Errorf("combined report has %d platforms, want 2", len(r.Platforms))
+				}
+			},
+		},
+		{
+			name:    "both engines disagree",
+			engine:  types.EngineBoth,
+			trivy:   osmgrtest.Run{Report: vulnerable},
+			grype:   &osmgrtest.Run{Report: grypeReport("CVE-2024-0001", "openssl", "High")},
+			status:  job.Done,
+			found:   2,
+			summary: map[string]int{"CRITICAL": 1, "HIGH": 1},
+			check: func(t *testing.T, r types.Report) {
+				if r.Comparison == nil {
+					t.Fatal("report has no comparison")
+				}
+				if r.Comparison.Agreed != 0 || len(r.Comparison.Disputed) != 2 {
+					t.Errorf("comparison agreed %d disputed %d, want 0 and 2", r.Comparison.Agreed, len(r.Comparison.Disputed))
+				}
+			},
+		},
+		{
+			name:    "second engine fails",
+			engine:  types.EngineBoth,
+			trivy:   osmgrtest.Run{Report: vulnerable},
+			grype:   &osmgrtest.Run{Err: errors.New("exit status 1"), Stdout: "db update failed"},
+			status:  job.Done,
+			found:   2,
+			summary: map[string]int{"CRITICAL": 1, "HIGH": 1},
+			check: func(t *testing.T, r types.Report) {
+				if r.Comparison != nil {
+					t.Errorf("report has a comparison after grype failed")
+				}
+			},
+		},
+		{
+			name:    "grype only",
+			engine:  types.EngineGrype,
+			trivy:   osmgrtest.Run{Err: errors.New("trivy must not run")},
+			grype:   &osmgrtest.Run{Report: grypeReport("GHSA-xxxx", "openssl", "Negligible")},
+			status:  job.Done,
+			pass:    true,
+			found:   1,
+			summary: map[string]int{"LOW": 1},
+			check: func(t *testing.T, r types.Report) {
+				if r.EngineName() != types.EngineGrype {
+					t.Errorf("engine = %q, want %q", r.EngineName(), types.EngineGrype)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEnv(t)
+			e.mgr.On("trivy", image, tt.trivy)
+			if tt.grype != nil {
+				e.mgr.On("grype", image, *tt.grype)
+			}
+
+			ctx := context.Background()
+			jobs, err := e.client.Scan(ctx, image, tt.platforms, tt.engine)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e.run()
+
+			for platform, id := range jobs {
+				s, seen := e.wait(id)
+				if s.Status != tt.status.String() {
+					t.Fatalf("%s: status = %s (%s), want %s", platform, s.Status, s.Error, tt.status)
+				}
+				if !strings.Contains(s.Error, tt.errContains) {
+					t.Errorf("%s: error = %q, want it to contain %q", platform, s.Error, tt.errContains)
+				}
+				if tt.seenPending && !contains(seen, job.Pending.String()) {
+					t.Errorf("%s: statuses %v, want %s among them", platform, seen, job.Pending)
+				}
+				if s.Failed() {
+					continue
+				}
+				if s.VulnerabilitiesFound != tt.found {
+					t.Errorf("%s: found %d vulnerabilities, want %d", platform, s.VulnerabilitiesFound, tt.found)
+				}
+				if s.Verdict == nil || s.Verdict.Pass != tt.pass {
+					t.Errorf("%s: verdict = %+v, want pass %v", platform, s.Verdict, tt.pass)
+				}
+			}
+
+			if tt.status == job.ScanFail {
+				if _, err := e.client.Report(ctx, image); err == nil {
+					t.Error("failed scan stored a report")
+				}
+				if s := summaries(t); len(s) != 0 {
+					t.Errorf("failed scan stored summaries %v", s)
+				}
+			} else {
+				r, err := e.client.Report(ctx, image)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := summaries(t)[image]; !equalCounts(got, tt.summary) {
+					t.Errorf("summary = %v, want %v", got, tt.summary)
+				}
+				if tt.check != nil {
+					tt.check(t, r)
+				}
+			}
+
+			if files := e.mgr.Files(); len(files) != 0 {
+				t.Errorf("temporary files left behind: %v", files)
+			}
+		})
+	}
+}
+
+func TestSBOMScan(t *testing.T) {
+	e := newEnv(t)
+	report := trivyReport(vuln("CVE-2024-0001", "openssl", "CRITICAL", "1.0.1"))
+	report.ArtifactName = "/tmp/sbom-1.json"
+	e.mgr.On("trivy", "", osmgrtest.Run{Report: report})
+
+	id := e.uploadSBOM(map[string]interface{}{
+		"bomFormat":   "CycloneDX",
+		"specVersion": "1.5",
+		"metadata": map[string]interface{}{
+			"component": map[string]interface{}{"type": "container", "name": "registry.example.com/sbom-app", "version": "2.0"},
+		},
+	}, "")
+	e.run()
+
+	s, _ := e.wait(id)
+	if s.Status != job.Done.String() {
+		t.Fatalf("status = %s (%s), want %s", s.Status, s.Error, job.Done)
+	}
+	if want := "registry.example.com/sbom-app:2.0"; s.Image != want {
+		t.Errorf("image = %q, want %q", s.Image, want)
+	}
+	if got := summaries(t)[s.Image]; !equalCounts(got, map[string]int{"CRITICAL": 1}) {
+		t.Errorf("summary = %v, want CRITICAL 1", got)
+	}
+
+	cmds := e.mgr.Commands()
+	if len(cmds) != 1 || cmds[0][1] != "sbom" {
+		t.Errorf("commands = %q, want a single trivy sbom", cmds)
+	}
+	if files := e.mgr.Files(); len(files) != 0 {
+		t.Errorf("temporary files left behind: %v", files)
+	}
+}
+
+func TestWebhookFailure(t *testing.T) {
+	var delivered int
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer hook.Close()
+
+	e := newEnv(t, project.Project{Name: "team-a", Webhook: hook.URL})
+	e.client.Project = "team-a"
+	e.mgr.On("trivy", image, osmgrtest.Run{Report: trivyReport()})
+
+	jobs, err := e.client.Scan(context.Background(), image, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.run()
+
+	s, _ := e.wait(jobs[""])
+	if s.Status != job.WebhookFail.String() {
+		t.Fatalf("status = %s, want %s", s.Status, job.WebhookFail)
+	}
+	if delivered != 1 {
+		t.Errorf("webhook called %d times, want 1", delivered)
+	}
+	// the scan itself succeeded, so its report is kept
+	if _, err := e.client.Report(context.Background(), image); err != nil {
+		t.Errorf("report of a scan with a failed webhook: %v", err)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func equalCounts(got, want map[string]int) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for k, v := range want {
+		if got[k] != v {
+			return false
+		}
+	}
+	return true
+}
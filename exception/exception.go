@@ -0,0 +1,249 @@
+package exception
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/trivy-web-dash/pkg/db"
+	redisx "github.com/trivy-web-dash/pkg/db/redis"
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/types"
+	"github.com/trivy-web-dash/util"
+)
+
+const keyPrefix = "exception/"
+
+var ErrNotFound = errors.New("exception not found")
+
+// Rule accepts the risk of a vulnerability until ExpiresAt. Expired rules are
+// kept for reference but no longer suppress anything.
+type Rule struct {
+	ID string `json:"id"`
+	// Project owns the rule, empty for the default project. Rules only apply
+	// to the reports of their project.
+	Project         string    `json:"project,omitempty"`
+	VulnerabilityID string    `json:"vulnerabilityID"`
+	PkgName         string    `json:"pkgName,omitempty"`
+	ImagePattern    string    `json:"imagePattern"`
+	Version         string    `json:"version,omitempty"`
+	Owner           string    `json:"owner"`
+	Justification   string    `json:"justification"`
+	ExpiresAt       time.Time `json:"expiresAt"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+// Validate checks the fields a new rule needs. A rule expiring before now
+// would never apply.
+func (r Rule) Validate(now time.Time) error {
+	switch {
+	case r.VulnerabilityID == "":
+		return errors.New("vulnerabilityID is required")
+	case r.Owner == "":
+		return errors.New("owner is required")
+	case r.Justification == "":
+		return errors.New("justification is required")
+	case r.ExpiresAt.IsZero():
+		return errors.New("expiresAt is required")
+	case !r.Active(now):
+		return errors.New("expiresAt is in the past")
+	}
+	return nil
+}
+
+func (r Rule) Active(now time.Time) bool {
+	return now.Before(r.ExpiresAt)
+}
+
+// MatchesImage reports whether the rule's image pattern covers image.
+func (r Rule) MatchesImage(image string) bool {
+	return util.MatchImage(r.ImagePattern, image)
+}
+
+func (r Rule) matches(v types.Vulnerability) bool {
+	if !strings.EqualFold(r.VulnerabilityID, v.VulnerabilityID) {
+		return false
+	}
+	if r.PkgName != "" && r.PkgName != v.PkgName {
+		return false
+	}
+	if r.Version != "" && r.Version != v.InstalledVersion {
+		return false
+	}
+	return true
+}
+
+// Apply marks the findings of report that are covered by rules for image and
+// returns how many were suppressed. Callers pass only active rules.
+func Apply(rules []Rule, image string, report *types.Report) int {
+	var matching []Rule
+	for _, rule := range rules {
+		if rule.MatchesImage(image) {
+			matching = append(matching, rule)
+		}
+	}
+	if len(matching) == 0 {
+		return 0
+	}
+
+	n := 0
+	for i := range report.Results {
+		vulns := report.Results[i].Vulnerabilities
+		for j := range vulns {
+			for _, rule := range matching {
+				if !rule.matches(vulns[j]) {
+					continue
+				}
+				expiresAt := rule.ExpiresAt
+				vulns[j].Suppression = &types.Suppression{
+					Source:        "exception",
+					RuleID:        rule.ID,
+					Owner:         rule.Owner,
+					Justification: rule.Justification,
+					ExpiresAt:     &expiresAt,
+				}
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+// AnyMatchImage reports whether at least one rule could apply to image, letting
+// callers skip loading full reports when nothing is suppressed.
+func AnyMatchImage(rules []Rule, image string) bool {
+	for _, rule := range rules {
+		if rule.MatchesImage(image) {
+			return true
+		}
+	}
+	return false
+}
+
+type ExceptionClient struct {
+	client db.Store
+	log    logger.Logger
+}
+
+var exceptionClient *ExceptionClient
+
+func NewExceptionClient(redisURI, redisPass string, redisDB int, redisTLS, redisTLSSkipVerify bool, log logger.Logger) error {
+	pool, err := redisx.NewPool(redisURI, redisPass, redisDB, redisTLS, redisTLSSkipVerify)
+	if err != nil {
+		return err
+	}
+
+	exceptionClient = &ExceptionClient{client: redisx.NewStore(pool), log: log}
+	return nil
+}
+
+func GetExceptionClient() *ExceptionClient {
+	return exceptionClient
+}
+
+// Create stores rule for project.
+func (c *ExceptionClient) Create(ctx context.Context, project string, rule Rule) (Rule, error) {
+	if err := rule.Validate(time.Now()); err != nil {
+		return Rule{}, err
+	}
+	if rule.ImagePattern == "" {
+		rule.ImagePattern = "*"
+	}
+	rule.Project = ""
+	if types.ProjectName(project) != types.DefaultProject {
+		rule.Project = project
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return Rule{}, err
+	}
+	rule.ID = hex.EncodeToString(id)
+	rule.CreatedAt = time.Now().UTC()
+
+	b, err := json.Marshal(rule)
+	if err != nil {
+		return Rule{}, err
+	}
+	if err := c.client.WithContext(ctx).Set(keyPrefix+rule.ID, b); err != nil {
+		c.log.Error(err)
+		return Rule{}, err
+	}
+	return rule, nil
+}
+
+// Get returns rule id of project. Rules of other projects are not found.
+func (c *ExceptionClient) Get(ctx context.Context, project, id string) (Rule, error) {
+	b, _, err := c.client.WithContext(ctx).GetwithTTL(keyPrefix + id)
+	if errors.Is(err, redis.ErrNil) {
+		return Rule{}, ErrNotFound
+	}
+	if err != nil {
+		c.log.Error(err)
+		return Rule{}, err
+	}
+
+	var rule Rule
+	if err := json.Unmarshal(b, &rule); err != nil {
+		return Rule{}, err
+	}
+	if types.ProjectName(rule.Project) != types.ProjectName(project) {
+		return Rule{}, ErrNotFound
+	}
+	return rule, nil
+}
+
+func (c *ExceptionClient) Delete(ctx context.Context, project, id string) error {
+	if _, err := c.Get(ctx, project, id); err != nil {
+		return err
+	}
+	return c.client.WithContext(ctx).Delete(keyPrefix + id)
+}
+
+// List returns all rules of project, including expired ones, soonest expiry
+// first.
+func (c *ExceptionClient) List(ctx context.Context, project string) ([]Rule, error) {
+	keys, err := c.client.WithContext(ctx).GetAllKeys(keyPrefix + "*")
+	if err != nil {
+		c.log.Error(err)
+		return nil, err
+	}
+
+	rules := []Rule{}
+	for _, key := range keys {
+		rule, err := c.Get(ctx, project, strings.TrimPrefix(key, keyPrefix))
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ExpiresAt.Before(rules[j].ExpiresAt) })
+	return rules, nil
+}
+
+// Active returns the rules of project that have not expired at now.
+func (c *ExceptionClient) Active(ctx context.Context, project string, now time.Time) ([]Rule, error) {
+	rules, err := c.List(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+
+	var active []Rule
+	for _, rule := range rules {
+		if rule.Active(now) {
+			active = append(active, rule)
+		}
+	}
+	return active, nil
+}
@@ -0,0 +1,178 @@
+package exception
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/types"
+)
+
+var now = time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func rule() Rule {
+	return Rule{
+		VulnerabilityID: "CVE-2024-0001",
+		Owner:           "team@example.com",
+		Justification:   "not reachable",
+		ExpiresAt:       now.Add(24 * time.Hour),
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(*Rule)
+		err  string
+	}{
+		{"valid", func(*Rule) {}, ""},
+		{"no vulnerability", func(r *Rule) { r.VulnerabilityID = "" }, "vulnerabilityID is required"},
+		{"no owner", func(r *Rule) { r.Owner = "" }, "owner is required"},
+		{"no justification", func(r *Rule) { r.Justification = "" }, "justification is required"},
+		{"no expiry", func(r *Rule) { r.ExpiresAt = time.Time{} }, "expiresAt is required"},
+		{"expired", func(r *Rule) { r.ExpiresAt = now.Add(-time.Minute) }, "expiresAt is in the past"},
+		{"expires now", func(r *Rule) { r.ExpiresAt = now }, "expiresAt is in the past"},
+	}
+	for _, tt := range tests {
+		r := rule()
+		tt.edit(&r)
+		err := r.Validate(now)
+		if (err == nil && tt.err != "") || (err != nil && err.Error() != tt.err) {
+			t.Errorf("%s: Validate() = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestActive(t *testing.T) {
+	r := rule()
+	if !r.Active(now) {
+		t.Error("rule is inactive before it expires")
+	}
+	if r.Active(r.ExpiresAt) || r.Active(r.ExpiresAt.Add(time.Second)) {
+		t.Error("rule is active once it expired")
+	}
+}
+
+func TestApply(t *testing.T) {
+	report := func() *types.Report {
+		return &types.Report{Results: []types.Result{{Vulnerabilities: []types.Vulnerability{
+			{VulnerabilityID: "CVE-2024-0001", PkgName: "openssl", InstalledVersion: "3.1.4"},
+			{VulnerabilityID: "CVE-2024-0001", PkgName: "libcrypto3", InstalledVersion: "3.1.4"},
+			{VulnerabilityID: "CVE-2024-0002", PkgName: "openssl", InstalledVersion: "3.1.4"},
+		}}}}
+	}
+
+	tests := []struct {
+		name       string
+		edit       func(*Rule)
+		image      string
+		suppressed []bool
+		matches    bool
+	}{
+		{"every package", func(*Rule) {}, "alpine:3.19", []bool{true, true, false}, true},
+		{"case insensitive id", func(r *Rule) { r.VulnerabilityID = "cve-2024-0001" }, "alpine:3.19", []bool{true, true, false}, true},
+		{"one package", func(r *Rule) { r.PkgName = "openssl" }, "alpine:3.19", []bool{true, false, false}, true},
+		{"other version", func(r *Rule) { r.Version = "3.1.5" }, "alpine:3.19", []bool{false, false, false}, true},
+		{"matching pattern", func(r *Rule) { r.ImagePattern = "registry.example.com/*" }, "registry.example.com/team/app:1", []bool{true, true, false}, true},
+		{"other image", func(r *Rule) { r.ImagePattern = "registry.example.com/*" }, "alpine:3.19", []bool{false, false, false}, false},
+	}
+	for _, tt := range tests {
+		r := rule()
+		r.ID, r.ImagePattern = "r1", "*"
+		tt.edit(&r)
+
+		rep := report()
+		n := Apply([]Rule{r}, tt.image, rep)
+		want := 0
+		for i, v := range rep.Results[0].Vulnerabilities {
+			if tt.suppressed[i] {
+				want++
+			}
+			if (v.Suppression != nil) != tt.suppressed[i] {
+				t.Errorf("%s: finding %d suppressed %v, want %v", tt.name, i, v.Suppression != nil, tt.suppressed[i])
+			}
+		}
+		if n != want {
+			t.Errorf("%s: Apply() = %d, want %d", tt.name, n, want)
+		}
+		if got := AnyMatchImage([]Rule{r}, tt.image); got != tt.matches {
+			t.Errorf("%s: AnyMatchImage() = %v, want %v", tt.name, got, tt.matches)
+		}
+	}
+
+	rep := report()
+	r := rule()
+	r.ID, r.ImagePattern = "r1", "*"
+	Apply([]Rule{r}, "alpine:3.19", rep)
+	s := rep.Results[0].Vulnerabilities[0].Suppression
+	if s.Source != "exception" || s.RuleID != "r1" || s.Owner != r.Owner || !s.ExpiresAt.Equal(r.ExpiresAt) {
+		t.Errorf("suppression = %+v", s)
+	}
+}
+
+func TestClientProjects(t *testing.T) {
+	redis := miniredis.RunT(t)
+	log := logger.NewAppLogger("fatal")
+	log.InitLogger()
+	if err := NewExceptionClient(redis.Addr(), "", 0, false, false, log); err != nil {
+		t.Fatal(err)
+	}
+	c := GetExceptionClient()
+	ctx := context.Background()
+
+	r := rule()
+	r.ExpiresAt = time.Now().Add(time.Hour)
+	if _, err := c.Create(ctx, "", Rule{}); err == nil {
+		t.Error("created an invalid rule")
+	}
+	expired := r
+	expired.ExpiresAt = time.Now().Add(-time.Hour)
+	if _, err := c.Create(ctx, "", expired); err == nil {
+		t.Error("created a rule that already expired")
+	}
+
+	def, err := c.Create(ctx, "", r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def.Project != "" || def.ImagePattern != "*" || def.ID == "" {
+		t.Errorf("default project rule = %+v", def)
+	}
+	team, err := c.Create(ctx, "team-a", r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if team.Project != "team-a" {
+		t.Errorf("team-a rule has project %q", team.Project)
+	}
+
+	for project, want := range map[string]string{"": def.ID, types.DefaultProject: def.ID, "team-a": team.ID} {
+		rules, err := c.Active(ctx, project, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rules) != 1 || rules[0].ID != want {
+			t.Errorf("active rules of %q = %+v, want only %s", project, rules, want)
+		}
+	}
+	if rules, _ := c.Active(ctx, "team-a", time.Now().Add(2*time.Hour)); len(rules) != 0 {
+		t.Errorf("expired rules are active: %+v", rules)
+	}
+
+	if _, err := c.Get(ctx, "team-b", team.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get from another project = %v, want ErrNotFound", err)
+	}
+	if err := c.Delete(ctx, "", team.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete from another project = %v, want ErrNotFound", err)
+	}
+	if err := c.Delete(ctx, "team-a", team.ID); err != nil {
+		t.Fatal(err)
+	}
+	if rules, _ := c.List(ctx, "team-a"); len(rules) != 0 {
+		t.Errorf("rules left after delete: %+v", rules)
+	}
+}
@@ -0,0 +1,41 @@
+package frontend
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trivy-web-dash/pkg/sso"
+)
+
+func Login(p *sso.Provider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := p.Login(c.Writer, c.Request, c.Query("next")); err != nil {
+			log.Println("error starting login: ", err)
+			c.String(http.StatusInternalServerError, "unable to start login")
+		}
+	}
+}
+
+func LoginCallback(p *sso.Provider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		next, err := p.Callback(c, c.Writer, c.Request)
+		if errors.Is(err, sso.ErrNoRole) {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		if err != nil {
+			log.Println("error completing login: ", err)
+			c.String(http.StatusUnauthorized, "login failed: %v", err)
+			return
+		}
+		c.Redirect(http.StatusFound, next)
+	}
+}
+
+func Logout(p *sso.Provider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Redirect(http.StatusSeeOther, p.Logout(c.Writer, c.Request))
+	}
+}
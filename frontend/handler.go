@@ -1,13 +1,19 @@
 package frontend
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
-	"strings"
-	"time"
+	"net/url"
+	"sort"
 
 	"github.com/gin-gonic/gin"
+	"github.com/trivy-web-dash/inventory"
+	"github.com/trivy-web-dash/pkg/auth"
+	"github.com/trivy-web-dash/pkg/project"
+	"github.com/trivy-web-dash/pkg/rbac"
 	"github.com/trivy-web-dash/report"
 	"github.com/trivy-web-dash/scan"
 	"github.com/trivy-web-dash/summary"
@@ -20,53 +26,75 @@ func GetReport() gin.HandlerFunc {
 		image, _ := c.Params.Get("image")
 		log.Println("getting report for image:", image)
 
-		r, ttl, err := report.GetReportClient().Get(c, image)
+		r, ttl, err := report.GetReportClient().Get(c.Request.Context(), project.Current(c).Name, image)
+		// images of other teams are reported as missing rather than forbidden
+		if errors.Is(err, report.ErrNotFound) || (err == nil && !rbac.CanView(c, image, r)) {
+			c.String(http.StatusNotFound, "no report found for %s", image)
+			return
+		}
 		if err != nil {
 			log.Fatalf("REDIS REPORT GET - %v", err)
 		}
 
-		totalCritical, totalHigh, totalMedium, totalLow := 0, 0, 0, 0
-		for _, r := range r.Results {
-			for _, v := range r.Vulnerabilities {
-				if v.Severity == "CRITICAL" {
-					totalCritical++
-				}
-				if v.Severity == "HIGH" {
-					totalHigh++
-				}
-				if v.Severity == "MEDIUM" {
-					totalMedium++
-				}
-				if v.Severity == "LOW" {
-					totalLow++
-				}
-			}
+		if err := report.Annotate(c.Request.Context(), project.Current(c).Name, &r); err != nil {
+			log.Println("error applying exceptions and vex: ", err)
 		}
 
 		report := types.Report{
-			Results: r.Results,
-			TotalSeverities: types.Severities{
-				Critical: totalCritical,
-				High:     totalHigh,
-				Medium:   totalMedium,
-				Low:      totalLow,
-			},
-			LastScanAt: util.ConvertToHumanReadable((2000 * time.Hour) - ttl),
+			ArtifactName:    image,
+			ArtifactType:    r.ArtifactType,
+			Engine:          r.Engine,
+			Project:         r.Project,
+			Digest:          r.Digest,
+			Platform:        r.Platform,
+			Platforms:       r.Platforms,
+			Metadata:        r.Metadata,
+			Results:         r.Results,
+			PolicyResults:   r.PolicyResults,
+			Comparison:      r.Comparison,
+			TotalSeverities: r.CountSeverities(),
+			TotalSuppressed: r.CountSuppressed(),
+			LastScanAt:      util.ConvertToHumanReadable(report.GetReportClient().Age(ttl)),
+			Layers:          r.LayerHistory(),
 		}
+		report.CountPlatforms()
 		c.HTML(http.StatusOK, "report.html", report)
 	}
 }
 
-func GetIndex() gin.HandlerFunc {
+// GetIndex renders the dashboard. self is the base URL the scan status is
+// requested from.
+func GetIndex(projects *project.Set, version func() (*types.VersionInfo, error), self string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		summaries, err := summary.GetSummaryClient().GetAll(c)
+		current := project.Current(c).Name
+		summaries, err := summary.GetSummaryClient().GetAll(c.Request.Context(), current, rbac.Visible(c))
 		if err != nil {
 			log.Fatalf("Summary: REDIS GETALL: %v", err)
 		}
 
+		overlay, err := report.LoadOverlay(c, current)
+		if err != nil {
+			log.Println("error getting exceptions and vex: ", err)
+		}
+
+		workloads, err := inventory.GetInventoryClient().Workloads(c)
+		if err != nil {
+			log.Println("error getting kubernetes inventory: ", err)
+		}
+		cluster, namespace := c.Query("cluster"), c.Query("namespace")
+
 		totalCritical, totalHigh, totalMed, totalLow, totalImages := 0, 0, 0, 0, 0
-		for i, s := range summaries {
-			summaries[i].Image = strings.TrimPrefix(s.Image, "vulndb/")
+		filtered := summaries[:0]
+		for i := range summaries {
+			summaries[i].Workloads = filterWorkloads(workloads[summaries[i].Image], cluster, namespace)
+			if (cluster != "" || namespace != "") && len(summaries[i].Workloads) == 0 {
+				continue
+			}
+			if overlay.Covers(summaries[i].Image) {
+				applyOverlay(c, current, overlay, &summaries[i])
+			}
+			s := summaries[i]
+			filtered = append(filtered, s)
 			totalCritical += s.VSummary["CRITICAL"]
 			totalHigh += s.VSummary["HIGH"]
 			totalMed += s.VSummary["MEDIUM"]
@@ -74,7 +102,7 @@ func GetIndex() gin.HandlerFunc {
 			totalImages++
 		}
 
-		scanstatusBytes, err := scan.GetScanStatus("http://localhost:8001/scan/status")
+		scanstatusBytes, err := scan.GetScanStatus(self+"/scan/status?project="+url.QueryEscape(current), c.Request.Header)
 		if err != nil {
 			log.Println("error getting scan status: ", err)
 		}
@@ -84,7 +112,7 @@ func GetIndex() gin.HandlerFunc {
 
 		indexData := &types.IndexData{
 			Title:   "VulnDB",
-			Summary: summaries,
+			Summary: filtered,
 			TotalSeverities: types.Severities{
 				Critical: totalCritical,
 				High:     totalHigh,
@@ -94,8 +122,91 @@ func GetIndex() gin.HandlerFunc {
 			ScanStatus:          scanStatusMap,
 			TotalImages:         totalImages,
 			TotalVulnerabilties: totalCritical + totalHigh + totalMed + totalLow,
+			Clusters:            clusters(workloads),
+			Namespaces:          namespaces(workloads, cluster),
+			Cluster:             cluster,
+			Namespace:           namespace,
+			Projects:            projectNames(c, projects),
+		}
+		if current != types.DefaultProject {
+			indexData.Project = current
+		}
+		if p, ok := auth.GetPrincipal(c); ok && p.Session {
+			indexData.User = p.Name
+		}
+		if indexData.Trivy, err = version(); err != nil {
+			log.Println("error getting trivy version: ", err)
 		}
 
 		c.HTML(http.StatusOK, "index.html", indexData)
 	}
 }
+
+// projectNames lists the projects the request may switch to.
+func projectNames(c *gin.Context, projects *project.Set) []string {
+	principal, _ := auth.GetPrincipal(c)
+	var names []string
+	for _, p := range projects.Admitted(principal) {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func filterWorkloads(workloads []types.Workload, cluster, namespace string) []types.Workload {
+	var out []types.Workload
+	for _, w := range workloads {
+		if (cluster == "" || w.Cluster == cluster) && (namespace == "" || w.Namespace == namespace) {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+func clusters(workloads map[string][]types.Workload) []string {
+	seen := map[string]bool{}
+	for _, ws := range workloads {
+		for _, w := range ws {
+			seen[w.Cluster] = true
+		}
+	}
+	return sortedKeys(seen)
+}
+
+func namespaces(workloads map[string][]types.Workload, cluster string) []string {
+	seen := map[string]bool{}
+	for _, ws := range workloads {
+		for _, w := range filterWorkloads(ws, cluster, "") {
+			seen[w.Namespace] = true
+		}
+	}
+	return sortedKeys(seen)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// applyOverlay recounts a summary from its full report when exception rules or
+// VEX statements cover the image.
+func applyOverlay(ctx context.Context, project string, overlay *report.Overlay, s *types.Summary) {
+	r, _, err := report.GetReportClient().Get(ctx, project, s.Image)
+	if err != nil {
+		log.Println("error getting report for overlay: ", err)
+		return
+	}
+
+	if overlay.Apply(s.Image, &r) == 0 {
+		return
+	}
+
+	counts := r.CountSeverities()
+	s.VSummary["CRITICAL"] = counts.Critical
+	s.VSummary["HIGH"] = counts.High
+	s.VSummary["MEDIUM"] = counts.Medium
+	s.VSummary["LOW"] = counts.Low
+}
@@ -0,0 +1,117 @@
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/trivy-web-dash/pkg/db"
+	redisx "github.com/trivy-web-dash/pkg/db/redis"
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/types"
+)
+
+const keyPrefix = "inventory/"
+
+var ErrNotFound = errors.New("inventory not found")
+
+// Inventory is the last discovery of one cluster, keyed by image reference.
+type Inventory struct {
+	Cluster   string                      `json:"cluster"`
+	Images    map[string][]types.Workload `json:"images"`
+	UpdatedAt time.Time                   `json:"updatedAt"`
+}
+
+type InventoryClient struct {
+	client db.Store
+	log    logger.Logger
+}
+
+var inventoryClient *InventoryClient
+
+func NewInventoryClient(redisURI, redisPass string, redisDB int, redisTLS, redisTLSSkipVerify bool, log logger.Logger) error {
+	pool, err := redisx.NewPool(redisURI, redisPass, redisDB, redisTLS, redisTLSSkipVerify)
+	if err != nil {
+		return err
+	}
+
+	inventoryClient = &InventoryClient{client: redisx.NewStore(pool), log: log}
+	return nil
+}
+
+func GetInventoryClient() *InventoryClient {
+	return inventoryClient
+}
+
+// Set replaces the inventory of inv.Cluster.
+func (c *InventoryClient) Set(ctx context.Context, inv Inventory) error {
+	b, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	if err := c.client.WithContext(ctx).Set(keyPrefix+inv.Cluster, b); err != nil {
+		c.log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (c *InventoryClient) Get(ctx context.Context, cluster string) (Inventory, error) {
+	b, _, err := c.client.WithContext(ctx).GetwithTTL(keyPrefix + cluster)
+	if errors.Is(err, redis.ErrNil) {
+		return Inventory{}, ErrNotFound
+	}
+	if err != nil {
+		c.log.Error(err)
+		return Inventory{}, err
+	}
+
+	var inv Inventory
+	if err := json.Unmarshal(b, &inv); err != nil {
+		return Inventory{}, err
+	}
+	return inv, nil
+}
+
+func (c *InventoryClient) List(ctx context.Context) ([]Inventory, error) {
+	keys, err := c.client.WithContext(ctx).GetAllKeys(keyPrefix + "*")
+	if err != nil {
+		c.log.Error(err)
+		return nil, err
+	}
+
+	inventories := []Inventory{}
+	for _, key := range keys {
+		inv, err := c.Get(ctx, strings.TrimPrefix(key, keyPrefix))
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		inventories = append(inventories, inv)
+	}
+
+	sort.Slice(inventories, func(i, j int) bool { return inventories[i].Cluster < inventories[j].Cluster })
+	return inventories, nil
+}
+
+// Workloads merges all cluster inventories into the workloads running each image.
+func (c *InventoryClient) Workloads(ctx context.Context) (map[string][]types.Workload, error) {
+	inventories, err := c.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	workloads := map[string][]types.Workload{}
+	for _, inv := range inventories {
+		for image, w := range inv.Images {
+			workloads[image] = append(workloads[image], w...)
+		}
+	}
+	return workloads, nil
+}
@@ -2,108 +2,294 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/trivy-web-dash/apikey"
+	"github.com/trivy-web-dash/audit"
+	"github.com/trivy-web-dash/exception"
 	"github.com/trivy-web-dash/frontend"
+	"github.com/trivy-web-dash/inventory"
+	"github.com/trivy-web-dash/pkg/auth"
+	"github.com/trivy-web-dash/pkg/config"
 	redisx "github.com/trivy-web-dash/pkg/db/redis"
+	"github.com/trivy-web-dash/pkg/grype"
+	"github.com/trivy-web-dash/pkg/health"
+	"github.com/trivy-web-dash/pkg/kube"
 	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/pkg/metrics"
+	"github.com/trivy-web-dash/pkg/pushevent"
 	"github.com/trivy-web-dash/pkg/queue"
+	"github.com/trivy-web-dash/pkg/rbac"
+	"github.com/trivy-web-dash/pkg/registry"
+	"github.com/trivy-web-dash/pkg/sso"
+	"github.com/trivy-web-dash/pkg/tracing"
 	scanner "github.com/trivy-web-dash/pkg/trivy/controller"
 	"github.com/trivy-web-dash/pkg/trivy/handler"
+	"github.com/trivy-web-dash/regopolicy"
 	"github.com/trivy-web-dash/report"
 	"github.com/trivy-web-dash/summary"
+	"github.com/trivy-web-dash/types"
+	"github.com/trivy-web-dash/vex"
 
 	trivy "github.com/trivy-web-dash/pkg/trivy"
 )
 
 func main() {
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "path of the YAML config file")
+	config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
 
-	aLog := logger.NewAppLogger("INFO")
-	aLog.InitLogger()
-	aLog.Info("Starting application with loglevel : INFO")
-	redisURI, ok := os.LookupEnv("REDIS")
-	if !ok {
-		aLog.Fatal("REDIS is unset")
+	cfg, err := config.Load(*configFile, flag.CommandLine)
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
 	}
 
-	redisPass, ok := os.LookupEnv("REDIS_PASSWORD")
-	if !ok {
-		aLog.Info("REDIS_PASSWORD is unset, assuming no auth")
+	aLog := logger.NewAppLogger(cfg.LogLevel)
+	aLog.InitLogger()
+	aLog.Infof("Starting application with loglevel : %s", cfg.LogLevel)
+
+	tc, err := trivy.NewScanner(aLog, cfg.Trivy.Mode, cfg.Trivy.Server, cfg.Trivy.CacheDir)
+	if err != nil {
+		aLog.Fatalf("unable to initialize trivy: %v", err)
 	}
 
-	redisTLS, ok := os.LookupEnv("REDIS_TLS")
-	if !ok {
-		aLog.Info("REDIS_TLS is unset")
+	rc := cfg.Redis
+	pool, err := redisx.NewPool(rc.Addr, rc.Password, rc.DB.Jobs, rc.TLS, rc.TLSSkipVerify)
+	if err != nil {
+		aLog.Fatalf("unable to initialize redis pool: %v", err)
 	}
 
-	redisTLSkipVerify, ok := os.LookupEnv("REDIS_TLS_SKIP_VERIFY")
-	if !ok {
-		aLog.Info("REDIS_TLS_SKIP_VERIFY is unset")
+	// spans are only exported when a collector is configured
+	stopTracing := func(context.Context) error { return nil }
+	if endpointConfigured() {
+		service, ok := os.LookupEnv("OTEL_SERVICE_NAME")
+		if !ok {
+			service = "trivy-web-dash"
+		}
+		stopTracing, err = tracing.Setup(context.Background(), service)
+		if err != nil {
+			aLog.Fatalf("unable to initialize tracing: %v", err)
+		}
 	}
 
-	trivyServer, ok := os.LookupEnv("TRIVY_SERVER")
-	if !ok {
-		log.Fatal("settrivy url in env TRIVY_SERVER")
+	projects, err := loadProjects(cfg.Files.Projects)
+	if err != nil {
+		aLog.Fatalf("unable to load projects: %v", err)
 	}
 
-	tc := trivy.NewTrivyClient(aLog, trivyServer)
+	rstore := redisx.NewStore(pool)
+	enqueuer := queue.NewEnqueuer(pool, rstore, projects)
+	engines := map[string]trivy.Scanner{
+		types.EngineTrivy: tc,
+		types.EngineGrype: grype.NewClient(aLog),
+	}
+	controller := scanner.NewController(rstore, engines, cfg.Scanner.Engine, aLog)
 
-	bredisTLS, _ := strconv.ParseBool(redisTLS)
-	bredisTLSkipVerify, _ := strconv.ParseBool(redisTLSkipVerify)
+	targets, err := loadTargets(cfg.Files.Registries, projects)
+	if err != nil {
+		aLog.Fatalf("unable to load registry targets: %v", err)
+	}
+	crawler := registry.NewCrawler(targets, enqueuer, aLog)
+	worker := queue.NewWorker(pool, controller, crawler, cfg.Worker.Concurrency, aLog)
 
-	pool, err := redisx.NewPool(redisURI, redisPass, "5", bredisTLS, bredisTLSkipVerify)
+	policies, err := loadPolicies(cfg.Files.Policies)
 	if err != nil {
-		aLog.Fatalf("unable to initialize redis pool: %v", err)
+		aLog.Fatalf("unable to load policies: %v", err)
+	}
+	if cfg.Files.Policies == "" {
+		aLog.Info("files.policies is unset, using built-in policies")
 	}
 
-	rstore := redisx.NewStore(pool)
-	enqueuer := queue.NewEnqueuer(pool, rstore)
-	controller := scanner.NewController(rstore, tc, aLog)
-	worker := queue.NewWorker(pool, controller, aLog)
+	// api keys are opt-in so existing deployments behind a VPN keep working
+	authEnabled, adminKey := cfg.Auth.Enabled, cfg.Auth.AdminKey
 
-	backendHandler := handler.NewHandler(aLog, enqueuer, rstore)
+	var provider *sso.Provider
+	if cfg.SSO.Issuer != "" {
+		provider, err = newSSOProvider(cfg.SSO)
+		if err != nil {
+			aLog.Fatalf("unable to initialize oidc login: %v", err)
+		}
+		// sso is required for the dashboard, which protects the api as well
+		authEnabled = true
+	}
+
+	if authEnabled && adminKey == "" {
+		aLog.Info("ADMIN_API_KEY is unset, api keys can only be managed with existing admin keys")
+	}
+	authn := auth.New(authEnabled, adminKey, provider, aLog)
+
+	grants, err := loadRBAC(cfg.Files.RBAC)
+	if err != nil {
+		aLog.Fatalf("unable to load rbac grants: %v", err)
+	}
+	if grants != nil && !authEnabled {
+		aLog.Info("files.rbac is set without auth.enabled, every request is an admin")
+	}
+	rbac.SetPolicy(grants)
+
+	backendHandler := handler.NewHandler(aLog, enqueuer, rstore, policies, crawler, projects)
 
 	r := gin.Default()
+	r.Use(tracing.Middleware())
 	// frontend
 	r.LoadHTMLGlob("./templates/*.html")
 	r.Static("/assets", "./assets")
 	r.Static("./templates/css", "./templates/css")
-	r.GET("/", frontend.GetIndex())
-	r.GET("/report/*image", frontend.GetReport())
+	inProject := projects.Select()
+	r.GET("/", authn.RequireLogin(apikey.ScopeRead), inProject, frontend.GetIndex(projects, tc.Version, "http://localhost:"+strconv.Itoa(cfg.Server.Port)))
+	r.GET("/report/*image", authn.RequireLogin(apikey.ScopeRead), inProject, frontend.GetReport())
+	if provider != nil {
+		r.GET("/auth/login", frontend.Login(provider))
+		r.GET("/auth/callback", frontend.LoginCallback(provider))
+		r.POST("/auth/logout", frontend.Logout(provider))
+	}
 	// r.POST("/summary", frontend.GetSummary())
 
 	// backend
-	r.POST("/scan/image", backendHandler.AcceptScanRequest)
-	r.GET("/scan/status", backendHandler.GetScanStatus)
-	r.GET("/scan/status/:id", backendHandler.GetScanStatusForJob)
+	read, scan, admin := authn.Require(apikey.ScopeRead), authn.Require(apikey.ScopeScan), authn.Require(apikey.ScopeAdmin)
+	r.POST("/scan/image", scan, inProject, backendHandler.AcceptScanRequest)
+	r.POST("/scan/sbom", scan, inProject, backendHandler.AcceptSBOMScanRequest)
+	r.GET("/scan/status", read, inProject, backendHandler.GetScanStatus)
+	r.GET("/scan/status/:id", read, inProject, backendHandler.GetScanStatusForJob)
+	r.GET("/api/v1/images/*path", read, inProject, backendHandler.GetImageResource)
+	r.GET("/api/v1/projects", read, backendHandler.ListProjects)
+	r.GET("/api/v1/policies", read, backendHandler.ListPolicies)
+	r.GET("/api/v1/rego", read, backendHandler.ListRegoPolicies)
+	r.PUT("/api/v1/rego/:name", admin, backendHandler.PutRegoPolicy)
+	r.DELETE("/api/v1/rego/:name", admin, backendHandler.DeleteRegoPolicy)
+	r.GET("/api/v1/exceptions", read, inProject, backendHandler.ListExceptions)
+	r.POST("/api/v1/exceptions", admin, inProject, backendHandler.CreateException)
+	r.DELETE("/api/v1/exceptions/:id", admin, inProject, backendHandler.DeleteException)
+	r.GET("/api/v1/vex", read, backendHandler.ListVEX)
+	r.POST("/api/v1/vex", admin, backendHandler.UploadVEX)
+	r.DELETE("/api/v1/vex/:key", admin, backendHandler.DeleteVEX)
+	r.GET("/api/v1/inventory", read, backendHandler.ListInventory)
+	r.GET("/api/v1/registries", read, backendHandler.ListRegistries)
+	r.POST("/api/v1/registries/:name/crawl", scan, backendHandler.CrawlRegistry)
+	r.GET("/api/v1/keys", admin, backendHandler.ListAPIKeys)
+	r.POST("/api/v1/keys", admin, backendHandler.CreateAPIKey)
+	r.DELETE("/api/v1/keys/:id", admin, backendHandler.DeleteAPIKey)
+	r.GET("/api/v1/audit", admin, backendHandler.ListAudit)
+	r.GET("/metrics", read, gin.WrapH(metrics.Handler()))
+
+	// probes carry no credentials
+	liveness := health.New(5 * time.Second)
+	liveness.Add("worker", worker.Alive)
+	readiness := health.New(5 * time.Second)
+	readiness.Add("redis", health.Redis(pool))
+	readiness.Add("trivy", func(context.Context) error {
+		_, err := tc.Version()
+		return err
+	})
+	readiness.Add("trivy_server", health.TrivyServer(cfg.Trivy.Server))
+	if cfg.Scanner.Engine != types.EngineTrivy {
+		readiness.Add("grype", func(context.Context) error {
+			_, err := engines[types.EngineGrype].Version()
+			return err
+		})
+	}
+	readiness.Add("worker", worker.Alive)
+	readiness.Add("queue", queue.Backlog(pool, cfg.Queue.MaxDepth, cfg.Queue.MaxLatency))
+	r.GET("/healthz", liveness.Handler())
+	r.GET("/readyz", readiness.Handler())
+
+	// registry push receivers are only enabled once their secret is configured,
+	// each one scans into the project it is bound to
+	bound, err := loadHooks(cfg.Hooks, projects)
+	if err != nil {
+		aLog.Fatalf("%v", err)
+	}
+	hooks := pushevent.NewSet(bound...)
+	r.POST("/hooks/:receiver", backendHandler.PushEvent(hooks))
 
 	log.Println("initializing summary & report clients")
-	if err := report.NewReportClient(redisURI, redisPass, bredisTLS, bredisTLSkipVerify, aLog); err != nil {
+	if err := report.NewReportClient(rc.Addr, rc.Password, rc.DB.Reports, rc.TLS, rc.TLSSkipVerify, cfg.Reports.TTL, aLog); err != nil {
 		log.Fatal("Failed to initialize report client: ", err)
 	}
 
-	if err := summary.NewSummaryClient(redisURI, redisPass, bredisTLS, bredisTLSkipVerify, aLog); err != nil {
+	if err := summary.NewSummaryClient(rc.Addr, rc.Password, rc.DB.Summaries, rc.TLS, rc.TLSSkipVerify, cfg.Reports.TTL, aLog); err != nil {
 		log.Fatal("Failed to initialize summary client: ", err)
 	}
 
+	if err := exception.NewExceptionClient(rc.Addr, rc.Password, rc.DB.Exceptions, rc.TLS, rc.TLSSkipVerify, aLog); err != nil {
+		log.Fatal("Failed to initialize exception client: ", err)
+	}
+
+	if err := vex.NewVEXClient(rc.Addr, rc.Password, rc.DB.VEX, rc.TLS, rc.TLSSkipVerify, aLog); err != nil {
+		log.Fatal("Failed to initialize vex client: ", err)
+	}
+
+	if err := regopolicy.NewRegoClient(rc.Addr, rc.Password, rc.DB.Rego, rc.TLS, rc.TLSSkipVerify, aLog); err != nil {
+		log.Fatal("Failed to initialize rego client: ", err)
+	}
+
+	if err := apikey.NewAPIKeyClient(rc.Addr, rc.Password, rc.DB.APIKeys, rc.TLS, rc.TLSSkipVerify, aLog); err != nil {
+		log.Fatal("Failed to initialize api key client: ", err)
+	}
+
+	if err := inventory.NewInventoryClient(rc.Addr, rc.Password, rc.DB.Inventory, rc.TLS, rc.TLSSkipVerify, aLog); err != nil {
+		log.Fatal("Failed to initialize inventory client: ", err)
+	}
+
+	if err := audit.NewAuditClient(rc.Addr, rc.Password, rc.DB.Audit, rc.TLS, rc.TLSSkipVerify, aLog); err != nil {
+		log.Fatal("Failed to initialize audit client: ", err)
+	}
+
 	log.Println("successfully initialized summary & report clients")
 
+	var projectNames []string
+	for _, p := range projects.List() {
+		projectNames = append(projectNames, p.Name)
+	}
+	summaries := func(project string) ([]types.Summary, error) {
+		return summary.GetSummaryClient().GetAll(context.Background(), project, nil)
+	}
+	if err := metrics.Register(
+		metrics.NewQueueCollector(queue.Namespace, pool),
+		metrics.NewJobCollector(rstore, projectNames),
+		metrics.NewVulnerabilityCollector(summaries, projectNames, cfg.Metrics.MaxImages),
+	); err != nil {
+		aLog.Fatalf("unable to register metrics: %v", err)
+	}
+
+	syncCtx, stopSync := context.WithCancel(context.Background())
+	defer stopSync()
+	var syncer *kube.Syncer
+	if kc := cfg.Kube; kc.Cluster != "" {
+		// the kubeconfig is optional, the in-cluster service account is used without it
+		clientset, err := kube.NewClientset(kc.Kubeconfig)
+		if err != nil {
+			aLog.Fatalf("unable to initialize kubernetes client: %v", err)
+		}
+
+		if _, ok := projects.Get(kc.Project); !ok {
+			aLog.Fatalf("kube.project %s is not a known project", kc.Project)
+		}
+
+		syncer = kube.NewSyncer(kube.NewDiscoverer(clientset, kc.Cluster), enqueuer, rstore, kc.Project, kc.Interval, aLog)
+		go syncer.Run(syncCtx)
+	} else {
+		aLog.Info("kube.cluster is unset, kubernetes inventory disabled")
+	}
+
 	httpServer := &http.Server{
-		Addr:           ":" + "8001",
+		Addr:           ":" + strconv.Itoa(cfg.Server.Port),
 		Handler:        r,
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
+		ReadTimeout:    cfg.Server.ReadTimeout,
+		WriteTimeout:   cfg.Server.WriteTimeout,
 		MaxHeaderBytes: 1 << 20,
 	}
 	go func() {
-		aLog.Infof("application server started on port : 8001")
+		aLog.Infof("application server started on port : %d", cfg.Server.Port)
 		if err := httpServer.ListenAndServe(); err != nil {
 			aLog.Fatal("server shutting down: %+v", err)
 		}
@@ -113,12 +299,59 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, os.Interrupt)
 
+	// SIGHUP applies the settings that can change without a restart
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			next, err := config.Load(*configFile, flag.CommandLine)
+			if err != nil {
+				aLog.Errorf("keeping the current configuration: %v", err)
+				continue
+			}
+			if changed := cfg.Restart(next); len(changed) > 0 {
+				aLog.Warnf("a restart is required to apply %v", changed)
+			}
+			reload(next, reloadables{aLog, projects, policies, crawler, hooks, worker, syncer})
+			cfg.LogLevel, cfg.Files, cfg.Kube.Interval, cfg.Hooks = next.LogLevel, next.Files, next.Kube.Interval, next.Hooks
+		}
+	}()
+
 	<-quit
 
 	ctx, shutdown := context.WithTimeout(context.Background(), 5*time.Second)
 	defer shutdown()
+	stopSync()
 	worker.Stop()
 	if err := httpServer.Shutdown(ctx); err != nil {
 		aLog.Fatalf("unable to start server: %v", err)
 	}
+	if err := stopTracing(ctx); err != nil {
+		aLog.Errorf("unable to flush spans: %v", err)
+	}
+}
+
+func endpointConfigured() bool {
+	_, traces := os.LookupEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	_, all := os.LookupEnv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	return traces || all
+}
+
+func newSSOProvider(c config.SSO) (*sso.Provider, error) {
+	mapping, err := sso.ParseRoleMapping(c.RoleMapping)
+	if err != nil {
+		return nil, err
+	}
+
+	return sso.NewProvider(context.Background(), sso.Config{
+		Issuer:        c.Issuer,
+		ClientID:      c.ClientID,
+		ClientSecret:  c.ClientSecret,
+		RedirectURL:   c.RedirectURL,
+		GroupsClaim:   c.GroupsClaim,
+		RoleMapping:   mapping,
+		DefaultRole:   c.DefaultRole,
+		SessionSecret: c.SessionSecret,
+		SessionTTL:    c.SessionTTL,
+	})
 }
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+
+	"github.com/trivy-web-dash/apikey"
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/pkg/sso"
+)
+
+// principalKey is the gin context key the authenticated principal is stored
+// under.
+const principalKey = "auth.principal"
+
+// Principal is whoever made a request.
+type Principal struct {
+	Name  string
+	Scope string
+	// KeyID is empty for the bootstrap admin key.
+	KeyID string
+	// RateLimit is in requests per minute, 0 means unlimited.
+	RateLimit int
+	// Email and Groups are set for users signed in with SSO, Session is set
+	// for them only.
+	Email   string
+	Groups  []string
+	Session bool
+}
+
+// Authenticator checks API keys sent as "Authorization: Bearer <token>" or
+// "X-API-Key: <token>" and rate limits each key. With SSO configured, browser
+// sessions are accepted too.
+type Authenticator struct {
+	enabled  bool
+	adminKey string
+	sso      *sso.Provider
+	log      logger.Logger
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// New returns an authenticator. When disabled every request is let through as
+// an anonymous admin, which is how the dashboard behaved before keys existed.
+// adminKey is accepted as an admin key without rate limit so the first keys
+// can be issued. provider may be nil.
+func New(enabled bool, adminKey string, provider *sso.Provider, l logger.Logger) *Authenticator {
+	return &Authenticator{
+		enabled:  enabled,
+		adminKey: adminKey,
+		sso:      provider,
+		log:      l,
+		limiters: map[string]*rate.Limiter{},
+	}
+}
+
+// Require rejects requests without a key or session that has scope.
+func (a *Authenticator) Require(scope string) gin.HandlerFunc {
+	return a.require(scope, false)
+}
+
+// RequireLogin is Require for HTML pages: without credentials the browser is
+// sent to the SSO login instead of getting a 401.
+func (a *Authenticator) RequireLogin(scope string) gin.HandlerFunc {
+	return a.require(scope, true)
+}
+
+func (a *Authenticator) require(scope string, page bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.enabled {
+			c.Set(principalKey, Principal{Name: "anonymous", Scope: apikey.ScopeAdmin})
+			c.Next()
+			return
+		}
+
+		var p Principal
+		if token := Token(c.Request); token != "" {
+			var err error
+			p, err = a.authenticate(c, token)
+			if errors.Is(err, apikey.ErrInvalid) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				a.log.Errorf("unable to verify api key : %v", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"status": "error verifying api key"})
+				return
+			}
+		} else if s, err := a.session(c.Request); err == nil {
+			p = Principal{Name: s.Name, Scope: s.Role, Email: s.Email, Groups: s.Groups, Session: true}
+		} else if page && a.sso != nil {
+			c.Redirect(http.StatusFound, "/auth/login?next="+url.QueryEscape(c.Request.URL.RequestURI()))
+			c.Abort()
+			return
+		} else {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "api key required"})
+			return
+		}
+
+		if p.RateLimit > 0 && !a.limiter(p.KeyID, p.RateLimit).Allow() {
+			retry := math.Ceil(60 / float64(p.RateLimit))
+			c.Header("Retry-After", strconv.Itoa(int(retry)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+			return
+		}
+
+		if !apikey.Allows(p.Scope, scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "missing scope " + scope})
+			return
+		}
+
+		c.Set(principalKey, p)
+		c.Next()
+	}
+}
+
+func (a *Authenticator) session(r *http.Request) (sso.Session, error) {
+	if a.sso == nil {
+		return sso.Session{}, sso.ErrNoSession
+	}
+	return a.sso.Session(r)
+}
+
+func (a *Authenticator) authenticate(c *gin.Context, token string) (Principal, error) {
+	if a.adminKey != "" && subtle.ConstantTimeCompare([]byte(a.adminKey), []byte(token)) == 1 {
+		return Principal{Name: "admin", Scope: apikey.ScopeAdmin}, nil
+	}
+
+	k, err := apikey.GetAPIKeyClient().Verify(c.Request.Context(), token)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	apikey.GetAPIKeyClient().Touch(c.Request.Context(), k)
+	return Principal{Name: k.Name, Scope: k.Scope, KeyID: k.ID, RateLimit: k.RateLimit}, nil
+}
+
+// limiter allows a key perMinute requests per minute with bursts of up to a
+// sixth of that.
+func (a *Authenticator) limiter(keyID string, perMinute int) *rate.Limiter {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	limit := rate.Limit(float64(perMinute) / 60)
+	burst := perMinute / 6
+	if burst < 1 {
+		burst = 1
+	}
+
+	l, ok := a.limiters[keyID]
+	if !ok {
+		l = rate.NewLimiter(limit, burst)
+		a.limiters[keyID] = l
+	} else if l.Limit() != limit {
+		l.SetLimit(limit)
+		l.SetBurst(burst)
+	}
+	return l
+}
+
+// Token extracts an API key from the request headers.
+func Token(r *http.Request) string {
+	if k := r.Header.Get("X-API-Key"); k != "" {
+		return k
+	}
+	h := r.Header.Get("Authorization")
+	if len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return ""
+}
+
+// GetPrincipal returns the principal Require stored for the request.
+func GetPrincipal(c *gin.Context) (Principal, bool) {
+	v, ok := c.Get(principalKey)
+	if !ok {
+		return Principal{}, false
+	}
+	p, ok := v.(Principal)
+	return p, ok
+}
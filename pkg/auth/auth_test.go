@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+
+	"github.com/trivy-web-dash/apikey"
+	"github.com/trivy-web-dash/pkg/logger"
+)
+
+func newTestRouter(t *testing.T, enabled bool) *gin.Engine {
+	t.Helper()
+	redis := miniredis.RunT(t)
+	log := logger.NewAppLogger("fatal")
+	log.InitLogger()
+	if err := apikey.NewAPIKeyClient(redis.Addr(), "", 8, false, false, log); err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+
+	a := New(enabled, "bootstrap", nil, log)
+	r := gin.New()
+	whoami := func(c *gin.Context) {
+		p, _ := GetPrincipal(c)
+		c.String(http.StatusOK, p.Name+" "+p.Scope)
+	}
+	r.GET("/read", a.Require(apikey.ScopeRead), whoami)
+	r.POST("/admin", a.Require(apikey.ScopeAdmin), whoami)
+	r.GET("/page", a.RequireLogin(apikey.ScopeRead), whoami)
+	return r
+}
+
+func newKey(t *testing.T, k apikey.Key) string {
+	t.Helper()
+	_, token, err := apikey.GetAPIKeyClient().Create(context.Background(), k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func serve(r *gin.Engine, method, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRequire(t *testing.T) {
+	r := newTestRouter(t, true)
+	reader := newKey(t, apikey.Key{Name: "dashboard", Scope: apikey.ScopeRead})
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		headers map[string]string
+		code    int
+		body    string
+	}{
+		{"bearer key", http.MethodGet, "/read", map[string]string{"Authorization": "Bearer " + reader}, http.StatusOK, "dashboard read"},
+		{"x-api-key", http.MethodGet, "/read", map[string]string{"X-API-Key": reader}, http.StatusOK, "dashboard read"},
+		{"admin key", http.MethodPost, "/admin", map[string]string{"X-API-Key": "bootstrap"}, http.StatusOK, "admin admin"},
+		{"missing scope", http.MethodPost, "/admin", map[string]string{"X-API-Key": reader}, http.StatusForbidden, ""},
+		{"invalid key", http.MethodGet, "/read", map[string]string{"X-API-Key": reader + "x"}, http.StatusUnauthorized, ""},
+		{"basic auth is no key", http.MethodGet, "/read", map[string]string{"Authorization": "Basic " + reader}, http.StatusUnauthorized, ""},
+		{"no key", http.MethodGet, "/read", nil, http.StatusUnauthorized, ""},
+		// without sso there is no login to send browsers to
+		{"page without sso", http.MethodGet, "/page", nil, http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		rec := serve(r, tt.method, tt.path, tt.headers)
+		if rec.Code != tt.code || (tt.body != "" && rec.Body.String() != tt.body) {
+			t.Errorf("%s: %d %s", tt.name, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestRequireRateLimit(t *testing.T) {
+	r := newTestRouter(t, true)
+	// 12 a minute allow bursts of 2
+	token := newKey(t, apikey.Key{Name: "ci", Scope: apikey.ScopeScan, RateLimit: 12})
+	headers := map[string]string{"X-API-Key": token}
+
+	for i := 0; i < 2; i++ {
+		if rec := serve(r, http.MethodGet, "/read", headers); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: %d", i+1, rec.Code)
+		}
+	}
+	rec := serve(r, http.MethodGet, "/read", headers)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "5" {
+		t.Errorf("request beyond the burst: %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	// other keys have their own budget, the admin key has none
+	other := newKey(t, apikey.Key{Name: "other", Scope: apikey.ScopeRead, RateLimit: 12})
+	if rec := serve(r, http.MethodGet, "/read", map[string]string{"X-API-Key": other}); rec.Code != http.StatusOK {
+		t.Errorf("other key: %d", rec.Code)
+	}
+	for i := 0; i < 5; i++ {
+		if rec := serve(r, http.MethodGet, "/read", map[string]string{"X-API-Key": "bootstrap"}); rec.Code != http.StatusOK {
+			t.Errorf("admin key request %d: %d", i+1, rec.Code)
+		}
+	}
+}
+
+func TestRequireDisabled(t *testing.T) {
+	r := newTestRouter(t, false)
+	if rec := serve(r, http.MethodPost, "/admin", nil); rec.Code != http.StatusOK || rec.Body.String() != "anonymous admin" {
+		t.Errorf("disabled auth: %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,202 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/trivy-web-dash/pkg/job"
+	"github.com/trivy-web-dash/pkg/policy"
+	"github.com/trivy-web-dash/types"
+)
+
+// Client calls the scan and report API of a dashboard.
+type Client struct {
+	Server string
+	// Token is an API key, sent as bearer token when set.
+	Token string
+	// Project scopes every request, empty is the default project.
+	Project string
+	HTTP    *http.Client
+}
+
+func New(server, token, project string) *Client {
+	return &Client{
+		Server:  strings.TrimSuffix(server, "/"),
+		Token:   token,
+		Project: project,
+		HTTP:    &http.Client{Timeout: time.Minute},
+	}
+}
+
+// Status is the state of a scan job, Image and Verdict are set once the scan
+// finished.
+type Status struct {
+	ID                   string          `json:"id"`
+	Status               string          `json:"status"`
+	Error                string          `json:"error,omitempty"`
+	Image                string          `json:"image,omitempty"`
+	VulnerabilitiesFound int             `json:"vulnerabilities_found"`
+	Verdict              *policy.Verdict `json:"verdict,omitempty"`
+}
+
+// terminal are the statuses a job does not leave.
+var terminal = map[string]bool{
+	job.ScanFail.String():    true,
+	job.WebhookFail.String(): true,
+	job.Done.String():        true,
+}
+
+// Finished reports whether the job reached a terminal status.
+func (s Status) Finished() bool {
+	return terminal[s.Status]
+}
+
+// Failed reports whether the scan itself failed. A failed webhook delivery
+// still produced a report.
+func (s Status) Failed() bool {
+	return s.Status == job.ScanFail.String()
+}
+
+// Scan queues a scan of image and returns the job IDs by platform. Without
+// platforms the only job is under the empty platform. platforms is a comma
+// separated list of os/arch or "all". engine overrides the server's default
+// scan engine when set.
+func (c *Client) Scan(ctx context.Context, image, platforms, engine string) (map[string]string, error) {
+	form := url.Values{"image": {image}}
+	if platforms != "" {
+		form.Set("platforms", platforms)
+	}
+	if engine != "" {
+		form.Set("engine", engine)
+	}
+
+	var resp struct {
+		ID   string            `json:"ID"`
+		Jobs map[string]string `json:"jobs"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/scan/image", form, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Jobs != nil {
+		return resp.Jobs, nil
+	}
+	return map[string]string{"": resp.ID}, nil
+}
+
+// Status returns the state of job id, with the verdict of the named policy
+// once it finished.
+func (c *Client) Status(ctx context.Context, id, policyName string) (Status, error) {
+	var s Status
+	err := c.do(ctx, http.MethodGet, "/scan/status/"+url.PathEscape(id)+query("policy", policyName), nil, &s)
+	return s, err
+}
+
+// Wait polls job id every interval until it finished, calling progress
+// whenever its status changes.
+func (c *Client) Wait(ctx context.Context, id, policyName string, interval time.Duration, progress func(Status)) (Status, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := ""
+	for {
+		s, err := c.Status(ctx, id, policyName)
+		if err != nil {
+			return s, err
+		}
+		if s.Status != last && progress != nil {
+			progress(s)
+		}
+		last = s.Status
+		if s.Finished() {
+			return s, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return s, fmt.Errorf("waiting for job %s: %w", id, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Report returns the latest report of image with exceptions and VEX applied.
+func (c *Client) Report(ctx context.Context, image string) (types.Report, error) {
+	var r types.Report
+	err := c.do(ctx, http.MethodGet, "/api/v1/images/"+image+"/report", nil, &r)
+	return r, err
+}
+
+// Verdict evaluates the latest report of image against the named policy.
+func (c *Client) Verdict(ctx context.Context, image, policyName string) (policy.Verdict, error) {
+	var v policy.Verdict
+	err := c.do(ctx, http.MethodGet, "/api/v1/images/"+image+"/verdict"+query("policy", policyName), nil, &v)
+	return v, err
+}
+
+func query(key, value string) string {
+	if value == "" {
+		return ""
+	}
+	return "?" + url.Values{key: {value}}.Encode()
+}
+
+// do sends form, if any, and decodes the JSON response into out. Error
+// responses are returned with the message the server gave.
+func (c *Client) do(ctx context.Context, method, path string, form url.Values, out interface{}) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.Server+path, body)
+	if err != nil {
+		return err
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if c.Project != "" {
+		req.Header.Set("X-Project", c.Project)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s", method, path, errorMessage(resp.StatusCode, b))
+	}
+	return json.Unmarshal(b, out)
+}
+
+// errorMessage picks the message out of the {"error": ...} or {"status": ...}
+// bodies the API answers with.
+func errorMessage(status int, b []byte) string {
+	var body struct {
+		Error  string `json:"error"`
+		Status string `json:"status"`
+	}
+	if json.Unmarshal(b, &body) == nil {
+		if body.Error != "" {
+			return body.Error
+		}
+		if body.Status != "" {
+			return body.Status
+		}
+	}
+	return http.StatusText(status)
+}
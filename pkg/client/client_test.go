@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScan(t *testing.T) {
+	var form string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" || r.Header.Get("X-Project") != "payments" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "invalid API key"}`))
+			return
+		}
+		r.ParseForm()
+		form = r.PostForm.Encode()
+		if r.PostForm.Get("platforms") != "" {
+			w.Write([]byte(`{"jobs": {"linux/amd64": "a", "linux/arm64": "b"}}`))
+			return
+		}
+		w.Write([]byte(`{"ID": "a"}`))
+	}))
+	t.Cleanup(srv.Close)
+	c := New(srv.URL+"/", "tok", "payments")
+	ctx := context.Background()
+
+	jobs, err := c.Scan(ctx, "alpine:3.19", "", "")
+	if err != nil || len(jobs) != 1 || jobs[""] != "a" || form != "image=alpine%3A3.19" {
+		t.Errorf("Scan() = %v, %v with form %s", jobs, err, form)
+	}
+	jobs, err = c.Scan(ctx, "alpine:3.19", "all", "grype")
+	if err != nil || len(jobs) != 2 || jobs["linux/arm64"] != "b" || !strings.Contains(form, "engine=grype") {
+		t.Errorf("Scan() of all platforms = %v, %v with form %s", jobs, err, form)
+	}
+
+	c.Token = "wrong"
+	if _, err := c.Scan(ctx, "alpine:3.19", "", ""); err == nil || err.Error() != "POST /scan/image: invalid API key" {
+		t.Errorf("Scan() with a wrong token = %v", err)
+	}
+}
+
+func TestWait(t *testing.T) {
+	statuses := []string{"Queued", "Pending", "Pending", "Done"}
+	polls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/scan/status/a" || r.URL.Query().Get("policy") != "strict" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		s := statuses[min(polls, len(statuses)-1)]
+		polls++
+		w.Write([]byte(`{"id": "a", "status": "` + s + `", "image": "alpine@sha256:c5b1"}`))
+	}))
+	t.Cleanup(srv.Close)
+	c := New(srv.URL, "", "")
+
+	var seen []string
+	s, err := c.Wait(context.Background(), "a", "strict", time.Millisecond, func(s Status) { seen = append(seen, s.Status) })
+	if err != nil || !s.Finished() || s.Failed() || s.Image != "alpine@sha256:c5b1" {
+		t.Errorf("Wait() = %+v, %v", s, err)
+	}
+	if strings.Join(seen, " ") != "Queued Pending Done" {
+		t.Errorf("progress = %q", seen)
+	}
+
+	statuses = []string{"Pending"}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.Wait(ctx, "a", "strict", time.Millisecond, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() past the deadline = %v", err)
+	}
+	if _, err := c.Status(context.Background(), "b", ""); err == nil || !strings.Contains(err.Error(), "Not Found") {
+		t.Errorf("Status() of an unknown job = %v", err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	for status, want := range map[string][2]bool{
+		"Queued":      {false, false},
+		"Pending":     {false, false},
+		"ScanFail":    {true, true},
+		"WebhookFail": {true, false},
+		"Done":        {true, false},
+	} {
+		s := Status{Status: status}
+		if s.Finished() != want[0] || s.Failed() != want[1] {
+			t.Errorf("%s: Finished() = %v, Failed() = %v", status, s.Finished(), s.Failed())
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/types"
+)
+
+// Config is read from defaults, then the YAML file, then the environment, then
+// command line flags, each overriding the one before. Fields tagged reload are
+// applied again on SIGHUP, the others need a restart.
+type Config struct {
+	LogLevel string  `yaml:"logLevel" env:"LOG_LEVEL" flag:"log-level" reload:"true"`
+	Server   Server  `yaml:"server"`
+	Redis    Redis   `yaml:"redis"`
+	Trivy    Trivy   `yaml:"trivy"`
+	Scanner  Scanner `yaml:"scanner"`
+	Worker   Worker  `yaml:"worker"`
+	Reports  Reports `yaml:"reports"`
+	Files    Files   `yaml:"files"`
+	Auth     Auth    `yaml:"auth"`
+	SSO      SSO     `yaml:"sso"`
+	Kube     Kube    `yaml:"kube"`
+	Hooks    Hooks   `yaml:"hooks"`
+	Metrics  Metrics `yaml:"metrics"`
+	Queue    Queue   `yaml:"queue"`
+}
+
+type Server struct {
+	Port         int           `yaml:"port" env:"PORT" flag:"port"`
+	ReadTimeout  time.Duration `yaml:"readTimeout" env:"SERVER_READ_TIMEOUT"`
+	WriteTimeout time.Duration `yaml:"writeTimeout" env:"SERVER_WRITE_TIMEOUT"`
+}
+
+type Redis struct {
+	Addr          string  `yaml:"addr" env:"REDIS" flag:"redis"`
+	Password      string  `yaml:"password" env:"REDIS_PASSWORD"`
+	TLS           bool    `yaml:"tls" env:"REDIS_TLS"`
+	TLSSkipVerify bool    `yaml:"tlsSkipVerify" env:"REDIS_TLS_SKIP_VERIFY"`
+	DB            RedisDB `yaml:"db"`
+}
+
+// RedisDB holds the database number of each kind of data. Reports and
+// summaries share key names, so every kind needs its own database.
+type RedisDB struct {
+	Reports    int `yaml:"reports" env:"REDIS_DB_REPORTS"`
+	Summaries  int `yaml:"summaries" env:"REDIS_DB_SUMMARIES"`
+	Exceptions int `yaml:"exceptions" env:"REDIS_DB_EXCEPTIONS"`
+	VEX        int `yaml:"vex" env:"REDIS_DB_VEX"`
+	Jobs       int `yaml:"jobs" env:"REDIS_DB_JOBS"`
+	Rego       int `yaml:"rego" env:"REDIS_DB_REGO"`
+	Inventory  int `yaml:"inventory" env:"REDIS_DB_INVENTORY"`
+	APIKeys    int `yaml:"apiKeys" env:"REDIS_DB_API_KEYS"`
+	Audit      int `yaml:"audit" env:"REDIS_DB_AUDIT"`
+}
+
+type Trivy struct {
+	Server string `yaml:"server" env:"TRIVY_SERVER" flag:"trivy-server"`
+	// Mode is cli to run the trivy binary, or library to scan in-process,
+	// which needs a build with the trivylib tag.
+	Mode     string `yaml:"mode" env:"TRIVY_MODE" flag:"trivy-mode"`
+	CacheDir string `yaml:"cacheDir" env:"TRIVY_CACHE_DIR"`
+}
+
+type Scanner struct {
+	// Engine is the default scan engine: trivy, grype, or both to attach a
+	// comparison with grype to trivy's reports.
+	Engine string `yaml:"engine" env:"SCAN_ENGINE" flag:"engine"`
+}
+
+type Worker struct {
+	Concurrency uint `yaml:"concurrency" env:"WORKER_CONCURRENCY" flag:"workers"`
+}
+
+type Reports struct {
+	// TTL is how long reports and summaries are kept after their last scan.
+	TTL time.Duration `yaml:"ttl" env:"REPORT_TTL"`
+}
+
+// Files are reread on SIGHUP.
+type Files struct {
+	Projects   string `yaml:"projects" env:"PROJECTS_FILE" reload:"true"`
+	Policies   string `yaml:"policies" env:"POLICY_FILE" reload:"true"`
+	Registries string `yaml:"registries" env:"REGISTRY_CRAWL_FILE" reload:"true"`
+	RBAC       string `yaml:"rbac" env:"RBAC_FILE" reload:"true"`
+}
+
+type Auth struct {
+	Enabled  bool   `yaml:"enabled" env:"AUTH_ENABLED"`
+	AdminKey string `yaml:"adminKey" env:"ADMIN_API_KEY"`
+}
+
+type SSO struct {
+	Issuer       string `yaml:"issuer" env:"OIDC_ISSUER"`
+	ClientID     string `yaml:"clientId" env:"OIDC_CLIENT_ID"`
+	ClientSecret string `yaml:"clientSecret" env:"OIDC_CLIENT_SECRET"`
+	RedirectURL  string `yaml:"redirectUrl" env:"OIDC_REDIRECT_URL"`
+	GroupsClaim  string `yaml:"groupsClaim" env:"OIDC_GROUPS_CLAIM"`
+	// RoleMapping is "group=role,group=role".
+	RoleMapping   string        `yaml:"roleMapping" env:"OIDC_ROLE_MAPPING"`
+	DefaultRole   string        `yaml:"defaultRole" env:"OIDC_DEFAULT_ROLE"`
+	SessionSecret string        `yaml:"sessionSecret" env:"SESSION_SECRET"`
+	SessionTTL    time.Duration `yaml:"sessionTtl" env:"SESSION_TTL"`
+}
+
+type Kube struct {
+	Cluster    string        `yaml:"cluster" env:"KUBE_CLUSTER_NAME"`
+	Kubeconfig string        `yaml:"kubeconfig" env:"KUBECONFIG"`
+	Interval   time.Duration `yaml:"interval" env:"KUBE_INVENTORY_INTERVAL" reload:"true"`
+	Project    string        `yaml:"project" env:"KUBE_PROJECT"`
+}
+
+// Hooks holds the secrets of the registry push receivers, a receiver is only
+// enabled once its secret is set. Each receiver scans into its own project,
+// the default project when none is set. Receivers are rebuilt on SIGHUP.
+type Hooks struct {
+	RegistryHost    string `yaml:"registryHost" env:"REGISTRY_HOST" reload:"true"`
+	RegistryToken   string `yaml:"registryToken" env:"REGISTRY_WEBHOOK_TOKEN" reload:"true"`
+	RegistryProject string `yaml:"registryProject" env:"REGISTRY_WEBHOOK_PROJECT" reload:"true"`
+	HarborAuth      string `yaml:"harborAuth" env:"HARBOR_WEBHOOK_AUTH" reload:"true"`
+	HarborHost      string `yaml:"harborHost" env:"HARBOR_HOST" reload:"true"`
+	HarborProject   string `yaml:"harborProject" env:"HARBOR_WEBHOOK_PROJECT" reload:"true"`
+	GHCRSecret      string `yaml:"ghcrSecret" env:"GHCR_WEBHOOK_SECRET" reload:"true"`
+	GHCRProject     string `yaml:"ghcrProject" env:"GHCR_WEBHOOK_PROJECT" reload:"true"`
+	GitLabToken     string `yaml:"gitlabToken" env:"GITLAB_WEBHOOK_TOKEN" reload:"true"`
+	GitLabHost      string `yaml:"gitlabHost" env:"GITLAB_REGISTRY_HOST" reload:"true"`
+	GitLabProject   string `yaml:"gitlabProject" env:"GITLAB_WEBHOOK_PROJECT" reload:"true"`
+}
+
+type Metrics struct {
+	MaxImages int `yaml:"maxImages" env:"METRICS_MAX_IMAGES"`
+}
+
+type Queue struct {
+	MaxDepth   int64         `yaml:"maxDepth" env:"QUEUE_MAX_DEPTH"`
+	MaxLatency time.Duration `yaml:"maxLatency" env:"QUEUE_MAX_LATENCY"`
+}
+
+// Default returns the settings used before a file or environment applies.
+func Default() *Config {
+	return &Config{
+		LogLevel: "info",
+		Server: Server{
+			Port:         8001,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		},
+		Redis: Redis{
+			DB: RedisDB{
+				Reports:    1,
+				Summaries:  2,
+				Exceptions: 3,
+				VEX:        4,
+				Jobs:       5,
+				Rego:       6,
+				Inventory:  7,
+				APIKeys:    8,
+				Audit:      9,
+			},
+		},
+		Trivy:   Trivy{Mode: "cli", CacheDir: "/tmp/"},
+		Scanner: Scanner{Engine: types.EngineTrivy},
+		Worker:  Worker{Concurrency: 5},
+		Reports: Reports{TTL: 2000 * time.Hour},
+		SSO:     SSO{SessionTTL: 8 * time.Hour},
+		Kube:    Kube{Interval: 15 * time.Minute},
+		Metrics: Metrics{MaxImages: 50},
+		Queue:   Queue{MaxDepth: 1000, MaxLatency: time.Hour},
+	}
+}
+
+// RegisterFlags defines a flag for every field tagged with one. Load applies
+// the flags that were set on the command line.
+func RegisterFlags(fs *flag.FlagSet) {
+	walk(reflect.ValueOf(Default()).Elem(), "", func(f reflect.StructField, v reflect.Value, path string) error {
+		if name := f.Tag.Get("flag"); name != "" {
+			fs.String(name, "", "overrides "+path)
+		}
+		return nil
+	})
+}
+
+// Load reads the YAML file at path, when not empty, applies the environment
+// and the flags of fs that were set, and validates the result.
+func Load(path string, fs *flag.FlagSet) (*Config, error) {
+	c := Default()
+	if path != "" {
+		if err := c.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	err := walk(reflect.ValueOf(c).Elem(), "", func(f reflect.StructField, v reflect.Value, path string) error {
+		name := f.Tag.Get("env")
+		if name == "" {
+			return nil
+		}
+		if s, ok := os.LookupEnv(name); ok {
+			if err := setValue(v, s); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if fs != nil {
+		visited := map[string]string{}
+		fs.Visit(func(f *flag.Flag) { visited[f.Name] = f.Value.String() })
+		err := walk(reflect.ValueOf(c).Elem(), "", func(f reflect.StructField, v reflect.Value, path string) error {
+			s, ok := visited[f.Tag.Get("flag")]
+			if !ok {
+				return nil
+			}
+			if err := setValue(v, s); err != nil {
+				return fmt.Errorf("-%s: %w", f.Tag.Get("flag"), err)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(logger.ValidLevel(c.LogLevel), "logLevel: unknown level %q", c.LogLevel)
+	check(c.Server.Port > 0 && c.Server.Port < 65536, "server.port: %d is not a port", c.Server.Port)
+	check(c.Server.ReadTimeout > 0, "server.readTimeout must be positive")
+	check(c.Server.WriteTimeout > 0, "server.writeTimeout must be positive")
+	check(c.Redis.Addr != "", "redis.addr is required")
+	check(c.Trivy.Server != "", "trivy.server is required")
+	check(c.Trivy.Mode == "cli" || c.Trivy.Mode == "library", "trivy.mode: %q is not cli or library", c.Trivy.Mode)
+	check(c.Scanner.Engine != "" && types.ValidEngine(c.Scanner.Engine), "scanner.engine: %q is not trivy, grype or both", c.Scanner.Engine)
+	check(c.Worker.Concurrency > 0, "worker.concurrency must be at least 1")
+	check(c.Reports.TTL > 0, "reports.ttl must be positive")
+	check(c.SSO.SessionTTL > 0, "sso.sessionTtl must be positive")
+	check(c.Kube.Interval > 0, "kube.interval must be positive")
+	check(c.Metrics.MaxImages >= 0, "metrics.maxImages must not be negative")
+	check(c.Queue.MaxDepth >= 0, "queue.maxDepth must not be negative")
+	check(c.Queue.MaxLatency >= 0, "queue.maxLatency must not be negative")
+	if c.SSO.Issuer != "" {
+		check(c.SSO.ClientID != "", "sso.clientId is required with sso.issuer")
+		check(c.SSO.RedirectURL != "", "sso.redirectUrl is required with sso.issuer")
+		check(c.SSO.SessionSecret != "", "sso.sessionSecret is required with sso.issuer")
+	}
+
+	used := map[int]string{}
+	walk(reflect.ValueOf(&c.Redis.DB).Elem(), "redis.db", func(f reflect.StructField, v reflect.Value, path string) error {
+		db := int(v.Int())
+		check(db >= 0 && db < 16, "%s: %d is not a redis database", path, db)
+		if other, ok := used[db]; ok {
+			errs = append(errs, fmt.Errorf("%s: database %d is already used by %s", path, db, other))
+		}
+		used[db] = path
+		return nil
+	})
+
+	return errors.Join(errs...)
+}
+
+// Restart lists the settings that differ between c and next and only take
+// effect after a restart.
+func (c *Config) Restart(next *Config) []string {
+	var changed []string
+	old := reflect.ValueOf(c).Elem()
+	walk(reflect.ValueOf(next).Elem(), "", func(f reflect.StructField, v reflect.Value, path string) error {
+		if f.Tag.Get("reload") == "" && !reflect.DeepEqual(v.Interface(), lookup(old, path).Interface()) {
+			changed = append(changed, path)
+		}
+		return nil
+	})
+	return changed
+}
+
+// walk calls fn with every leaf field of the struct v and its dotted YAML path.
+func walk(v reflect.Value, prefix string, fn func(f reflect.StructField, v reflect.Value, path string) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		path := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		var err error
+		if f.Type.Kind() == reflect.Struct {
+			err = walk(v.Field(i), path, fn)
+		} else {
+			err = fn(f, v.Field(i), path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookup returns the field of the struct v at a dotted YAML path.
+func lookup(v reflect.Value, path string) reflect.Value {
+	for _, name := range strings.Split(path, ".") {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0] == name {
+				v = v.Field(i)
+				break
+			}
+		}
+	}
+	return v
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setValue parses s into v, which is one of the field types used in Config.
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeFile(t, `
+logLevel: debug
+server:
+  port: 9000
+redis:
+  addr: redis:6379
+trivy:
+  server: http://trivy:4954
+worker:
+  concurrency: 3
+hooks:
+  ghcrSecret: from-file
+`)
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("PORT", "9100")
+	t.Setenv("GHCR_WEBHOOK_SECRET", "from-env")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	RegisterFlags(fs)
+	if err := fs.Parse([]string{"-port", "9200"}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Load(path, fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"default", c.Server.ReadTimeout, 10 * time.Second},
+		{"file over default", c.Worker.Concurrency, uint(3)},
+		{"env over file", c.LogLevel, "warn"},
+		{"env over file in a section", c.Hooks.GHCRSecret, "from-env"},
+		{"flag over env", c.Server.Port, 9200},
+		{"file only", c.Redis.Addr, "redis:6379"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	valid := "redis:\n  addr: redis:6379\ntrivy:\n  server: http://trivy:4954\n"
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		flag string
+		err  string
+	}{
+		{"unknown key", valid + "unknown: 1\n", nil, "", "field unknown not found"},
+		{"bad yaml", "redis: [", nil, "", "parsing config file"},
+		{"bad env", valid, map[string]string{"REPORT_TTL": "forever"}, "", "REPORT_TTL"},
+		{"bad flag", valid, nil, "-workers=-1", "-workers"},
+		{"invalid", valid, map[string]string{"SCAN_ENGINE": "clair"}, "", "scanner.engine"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			RegisterFlags(fs)
+			if tt.flag != "" {
+				if err := fs.Parse([]string{tt.flag}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := Load(writeFile(t, tt.file), fs); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Load() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml"), nil); err == nil {
+		t.Error("Load() of a missing file succeeded")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		c := Default()
+		c.Redis.Addr, c.Trivy.Server = "redis:6379", "http://trivy:4954"
+		return c
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		errs   []string
+	}{
+		{"defaults", func(c *Config) { *c = *Default() }, []string{"redis.addr is required", "trivy.server is required"}},
+		{"log level", func(c *Config) { c.LogLevel = "loud" }, []string{"logLevel"}},
+		{"port", func(c *Config) { c.Server.Port = 70000 }, []string{"server.port"}},
+		{"trivy mode", func(c *Config) { c.Trivy.Mode = "remote" }, []string{"trivy.mode"}},
+		{"workers", func(c *Config) { c.Worker.Concurrency = 0 }, []string{"worker.concurrency"}},
+		{"sso", func(c *Config) { c.SSO.Issuer = "https://idp.example.com" }, []string{"sso.clientId", "sso.redirectUrl", "sso.sessionSecret"}},
+		{"shared db", func(c *Config) { c.Redis.DB.Audit = c.Redis.DB.Jobs }, []string{"redis.db.audit: database 5 is already used by redis.db.jobs"}},
+		{"db range", func(c *Config) { c.Redis.DB.VEX = 16 }, []string{"redis.db.vex: 16"}},
+		{"queue", func(c *Config) { c.Queue.MaxDepth = -1 }, []string{"queue.maxDepth"}},
+	}
+	for _, tt := range tests {
+		c := valid()
+		tt.change(c)
+		err := c.Validate()
+		if err == nil {
+			t.Errorf("%s: Validate() succeeded", tt.name)
+			continue
+		}
+		// every invalid setting is reported, not only the first
+		for _, want := range tt.errs {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: Validate() = %v, want %q", tt.name, err, want)
+			}
+		}
+	}
+}
+
+func TestRestart(t *testing.T) {
+	c := Default()
+	next := Default()
+	next.LogLevel = "debug"
+	next.Files.Projects = "/etc/trivy-web-dash/projects.json"
+	next.Kube.Interval = time.Minute
+	next.Hooks = Hooks{RegistryToken: "rotated", RegistryProject: "payments", GHCRSecret: "s3cret"}
+	if changed := c.Restart(next); len(changed) != 0 {
+		t.Errorf("Restart() = %q for reloadable settings", changed)
+	}
+
+	next.Server.Port = 9000
+	next.Redis.DB.Jobs = 10
+	next.Auth.Enabled = true
+	changed := c.Restart(next)
+	if strings.Join(changed, " ") != "server.port redis.db.jobs auth.enabled" {
+		t.Errorf("Restart() = %q", changed)
+	}
+}
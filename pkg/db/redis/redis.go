@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -11,16 +12,20 @@ import (
 	"github.com/trivy-web-dash/pkg/db"
 	"github.com/trivy-web-dash/pkg/job"
 	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/pkg/tracing"
 	"github.com/trivy-web-dash/types"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/xerrors"
 )
 
 type store struct {
 	pool *redis.Pool
 	log  logger.Logger
+	ctx  context.Context
 }
 
-func NewPool(redisurl, redisPass, redisDB string, redisTLS, redisTLSkipVerify bool) (*redis.Pool, error) {
+func NewPool(redisurl, redisPass string, redisDB int, redisTLS, redisTLSkipVerify bool) (*redis.Pool, error) {
 	var rp = &redis.Pool{
 		MaxActive: 5,
 		MaxIdle:   5,
@@ -59,6 +64,30 @@ func NewStore(rp *redis.Pool) db.Store {
 	}
 }
 
+// WithContext returns a store whose commands are traced as children of the
+// span in ctx.
+func (s *store) WithContext(ctx context.Context) db.Store {
+	c := *s
+	c.ctx = ctx
+	return &c
+}
+
+// do runs cmd on conn, in a span when the store carries a traced context.
+func (s *store) do(conn redis.Conn, cmd string, args ...interface{}) (interface{}, error) {
+	if s.ctx == nil || !trace.SpanContextFromContext(s.ctx).IsValid() {
+		return conn.Do(cmd, args...)
+	}
+
+	_, span := tracing.Start(s.ctx, "redis "+cmd, semconv.DBSystemRedis, semconv.DBOperationName(cmd))
+	reply, err := conn.Do(cmd, args...)
+	if err == redis.ErrNil {
+		tracing.End(span, nil)
+	} else {
+		tracing.End(span, err)
+	}
+	return reply, err
+}
+
 func (s *store) Create(scanJob job.ScanJob) error {
 	conn := s.pool.Get()
 	defer s.close(conn)
@@ -68,8 +97,8 @@ func (s *store) Create(scanJob job.ScanJob) error {
 		return xerrors.Errorf("marshalling scan job: %w", err)
 	}
 
-	key := s.getKeyForScanJob(scanJob.ID)
-	_, err = conn.Do("SET", key, string(bytes), "NX", "EX", int((1 * time.Hour).Seconds()))
+	key := s.getKeyForScanJob(scanJob.Project, scanJob.ID)
+	_, err = s.do(conn, "SET", key, string(bytes), "NX", "EX", int((1 * time.Hour).Seconds()))
 	if err != nil {
 		return xerrors.Errorf("error scan job: %w", err)
 	}
@@ -77,12 +106,12 @@ func (s *store) Create(scanJob job.ScanJob) error {
 	return nil
 }
 
-func (s *store) Get(scanJobID string) (*job.ScanJob, error) {
+func (s *store) Get(project, scanJobID string) (*job.ScanJob, error) {
 	conn := s.pool.Get()
 	defer s.close(conn)
 
-	key := s.getKeyForScanJob(scanJobID)
-	value, err := redis.String(conn.Do("GET", key))
+	key := s.getKeyForScanJob(project, scanJobID)
+	value, err := redis.String(s.do(conn, "GET", key))
 	if err != nil {
 		if err == redis.ErrNil {
 			return nil, nil
@@ -98,11 +127,11 @@ func (s *store) Get(scanJobID string) (*job.ScanJob, error) {
 	return &scanJob, nil
 }
 
-func (s *store) GetAllJobStatus() ([]job.ScanJob, error) {
+func (s *store) GetAllJobStatus(project string) ([]job.ScanJob, error) {
 	conn := s.pool.Get()
 	defer s.close(conn)
 
-	values, err := (conn.Do("KEYS", "*scan-job*"))
+	values, err := (s.do(conn, "KEYS", s.getKeyForScanJob(project, "*")))
 	if err != nil {
 		if err == redis.ErrNil {
 			return nil, nil
@@ -127,7 +156,7 @@ func (s *store) GetAllJobStatus() ([]job.ScanJob, error) {
 			return nil, err
 		}
 
-		value, err := redis.String(conn.Do("GET", decodedValue))
+		value, err := redis.String(s.do(conn, "GET", decodedValue))
 		if err != nil {
 			if err == redis.ErrNil {
 				return nil, nil
@@ -155,8 +184,8 @@ func (s *store) update(scanJob job.ScanJob) error {
 	if err != nil {
 		return xerrors.Errorf("marshalling scan job: %w", err)
 	}
-	key := s.getKeyForScanJob(scanJob.ID)
-	_, err = conn.Do("SET", key, string(scanJobBytes), "EX", int((1 * time.Hour).Seconds()))
+	key := s.getKeyForScanJob(scanJob.Project, scanJob.ID)
+	_, err = s.do(conn, "SET", key, string(scanJobBytes), "EX", int((1 * time.Hour).Seconds()))
 	if err != nil {
 		return xerrors.Errorf("error scan job: %w", err)
 	}
@@ -164,8 +193,8 @@ func (s *store) update(scanJob job.ScanJob) error {
 	return nil
 }
 
-func (s *store) UpdateStatus(scanJobID string, newStatus job.ScanJobStatus, errs ...string) error {
-	scanJob, err := s.Get(scanJobID)
+func (s *store) UpdateStatus(project, scanJobID string, newStatus job.ScanJobStatus, errs ...string) error {
+	scanJob, err := s.Get(project, scanJobID)
 	if err != nil {
 		return err
 	}
@@ -178,8 +207,8 @@ func (s *store) UpdateStatus(scanJobID string, newStatus job.ScanJobStatus, errs
 	return s.update(*scanJob)
 }
 
-func (s *store) UpdateReport(scanJobID string, report types.Report) error {
-	scanJob, err := s.Get(scanJobID)
+func (s *store) UpdateReport(project, scanJobID string, report types.Report) error {
+	scanJob, err := s.Get(project, scanJobID)
 	if err != nil {
 		return err
 	}
@@ -187,8 +216,44 @@ func (s *store) UpdateReport(scanJobID string, report types.Report) error {
 	return s.update(*scanJob)
 }
 
-func (s *store) getKeyForScanJob(scanJobID string) string {
-	return fmt.Sprintf("%s:scan-job:%s", "trivy-scanner", scanJobID)
+func (s *store) SaveSBOM(digest string, sbom []byte) error {
+	conn := s.pool.Get()
+	defer s.close(conn)
+
+	_, err := s.do(conn, "SET", s.getKeyForSBOM(digest), sbom, "EX", int((1 * time.Hour).Seconds()))
+	if err != nil {
+		return xerrors.Errorf("error saving sbom: %w", err)
+	}
+
+	return nil
+}
+
+func (s *store) GetSBOM(digest string) ([]byte, error) {
+	conn := s.pool.Get()
+	defer s.close(conn)
+
+	value, err := redis.Bytes(s.do(conn, "GET", s.getKeyForSBOM(digest)))
+	if err != nil {
+		if err == redis.ErrNil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func (s *store) getKeyForSBOM(digest string) string {
+	return fmt.Sprintf("%s:sbom:%s", "trivy-scanner", digest)
+}
+
+// getKeyForScanJob keeps the jobs of the default project under the keys used
+// before projects existed.
+func (s *store) getKeyForScanJob(project, scanJobID string) string {
+	if types.ProjectName(project) == types.DefaultProject {
+		return fmt.Sprintf("%s:scan-job:%s", "trivy-scanner", scanJobID)
+	}
+	return fmt.Sprintf("%s:%s:scan-job:%s", "trivy-scanner", project, scanJobID)
 }
 
 func (s *store) close(conn redis.Conn) {
@@ -202,7 +267,7 @@ func (s *store) SetwithTTL(key string, value []byte, ttl time.Duration) error {
 	conn := s.pool.Get()
 	defer s.close(conn)
 
-	_, err := conn.Do("SET", "vulndb/"+key, value, "EX", int(ttl.Seconds()))
+	_, err := s.do(conn, "SET", "vulndb/"+key, value, "EX", int(ttl.Seconds()))
 	if err != nil {
 		return xerrors.Errorf("error perform redis set: %w", err)
 	}
@@ -213,12 +278,12 @@ func (s *store) SetwithTTL(key string, value []byte, ttl time.Duration) error {
 func (s *store) GetwithTTL(key string) ([]byte, time.Duration, error) {
 	conn := s.pool.Get()
 	defer s.close(conn)
-	value, err := redis.Bytes(conn.Do("GET", key))
+	value, err := redis.Bytes(s.do(conn, "GET", key))
 	if err != nil {
 		return nil, 0, xerrors.Errorf("error perform redis get: %w", err)
 	}
 
-	ttl, err := redis.Int64(conn.Do("TTL", key))
+	ttl, err := redis.Int64(s.do(conn, "TTL", key))
 	if err != nil {
 		return nil, 0, xerrors.Errorf("error perform redis ttl: %w", err)
 	}
@@ -230,9 +295,61 @@ func (s *store) GetAllKeys(pattern string) ([]string, error) {
 	conn := s.pool.Get()
 	defer s.close(conn)
 
-	value, err := redis.Strings(conn.Do("KEYS", pattern))
+	value, err := redis.Strings(s.do(conn, "KEYS", pattern))
 	if err != nil {
 		return nil, xerrors.Errorf("error perform redis get all: %v", err)
 	}
 	return value, nil
 }
+
+func (s *store) Set(key string, value []byte) error {
+	conn := s.pool.Get()
+	defer s.close(conn)
+
+	if _, err := s.do(conn, "SET", key, value); err != nil {
+		return xerrors.Errorf("error perform redis set: %w", err)
+	}
+	return nil
+}
+
+func (s *store) Replace(key string, value []byte) (bool, error) {
+	conn := s.pool.Get()
+	defer s.close(conn)
+
+	reply, err := s.do(conn, "SET", key, value, "XX")
+	if err != nil {
+		return false, xerrors.Errorf("error perform redis set: %w", err)
+	}
+	return reply != nil, nil
+}
+
+func (s *store) Delete(key string) error {
+	conn := s.pool.Get()
+	defer s.close(conn)
+
+	if _, err := s.do(conn, "DEL", key); err != nil {
+		return xerrors.Errorf("error perform redis del: %w", err)
+	}
+	return nil
+}
+
+func (s *store) Append(key string, value []byte) error {
+	conn := s.pool.Get()
+	defer s.close(conn)
+
+	if _, err := s.do(conn, "RPUSH", key, value); err != nil {
+		return xerrors.Errorf("error perform redis rpush: %w", err)
+	}
+	return nil
+}
+
+func (s *store) Range(key string, start, stop int) ([][]byte, error) {
+	conn := s.pool.Get()
+	defer s.close(conn)
+
+	values, err := redis.ByteSlices(s.do(conn, "LRANGE", key, start, stop))
+	if err != nil {
+		return nil, xerrors.Errorf("error perform redis lrange: %w", err)
+	}
+	return values, nil
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"time"
 
 	"github.com/trivy-web-dash/pkg/job"
@@ -8,12 +9,26 @@ import (
 )
 
 type Store interface {
+	// WithContext returns the store tracing its commands under the span of ctx.
+	WithContext(ctx context.Context) Store
+	// Scan jobs are stored per project, an empty project is the default one.
 	Create(scanJob job.ScanJob) error
-	Get(scanJobID string) (*job.ScanJob, error)
-	GetAllJobStatus() ([]job.ScanJob, error)
-	UpdateStatus(scanJobID string, newStatus job.ScanJobStatus, error ...string) error
-	UpdateReport(scanJobID string, report types.Report) error
+	Get(project, scanJobID string) (*job.ScanJob, error)
+	GetAllJobStatus(project string) ([]job.ScanJob, error)
+	UpdateStatus(project, scanJobID string, newStatus job.ScanJobStatus, error ...string) error
+	UpdateReport(project, scanJobID string, report types.Report) error
+	SaveSBOM(digest string, sbom []byte) error
+	GetSBOM(digest string) ([]byte, error)
 	SetwithTTL(key string, value []byte, ttl time.Duration) error
 	GetwithTTL(key string) ([]byte, time.Duration, error)
 	GetAllKeys(pattern string) ([]string, error)
+	Set(key string, value []byte) error
+	// Replace sets key only if it exists and reports whether it did.
+	Replace(key string, value []byte) (bool, error)
+	Delete(key string) error
+	// Append adds value to the end of the list at key.
+	Append(key string, value []byte) error
+	// Range returns the list at key from start to stop inclusive, negative
+	// indexes count from the end.
+	Range(key string, start, stop int) ([][]byte, error)
 }
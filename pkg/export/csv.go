@@ -0,0 +1,35 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+
+	"github.com/trivy-web-dash/types"
+)
+
+var csvHeader = []string{
+	"Target", "VulnerabilityID", "Severity", "PkgName",
+	"InstalledVersion", "FixedVersion", "Title", "PrimaryURL", "Suppression",
+}
+
+func writeCSV(w io.Writer, r types.Report) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, res := range r.Results {
+		for _, v := range res.Vulnerabilities {
+			err := cw.Write([]string{
+				res.Target, v.VulnerabilityID, v.Severity, v.PkgName,
+				v.InstalledVersion, v.FixedVersion, v.Title, v.PrimaryURL, suppressionText(v),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,252 @@
+package export
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trivy-web-dash/types"
+)
+
+const cycloneDXSpecVersion = "1.5"
+
+type cdxBOM struct {
+	BOMFormat       string             `json:"bomFormat"`
+	SpecVersion     string             `json:"specVersion"`
+	SerialNumber    string             `json:"serialNumber"`
+	Version         int                `json:"version"`
+	Metadata        cdxMetadata        `json:"metadata"`
+	Components      []cdxComponent     `json:"components"`
+	Vulnerabilities []cdxVulnerability `json:"vulnerabilities"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     cdxTools     `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	BOMRef  string `json:"bom-ref,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	PURL    string `json:"purl,omitempty"`
+}
+
+type cdxVulnerability struct {
+	BOMRef         string        `json:"bom-ref"`
+	ID             string        `json:"id"`
+	Source         *cdxSource    `json:"source,omitempty"`
+	Ratings        []cdxRating   `json:"ratings,omitempty"`
+	CWEs           []int         `json:"cwes,omitempty"`
+	Description    string        `json:"description,omitempty"`
+	Recommendation string        `json:"recommendation,omitempty"`
+	Advisories     []cdxAdvisory `json:"advisories,omitempty"`
+	Published      string        `json:"published,omitempty"`
+	Updated        string        `json:"updated,omitempty"`
+	Analysis       *cdxAnalysis  `json:"analysis,omitempty"`
+	Affects        []cdxAffect   `json:"affects"`
+}
+
+type cdxAnalysis struct {
+	State         string   `json:"state,omitempty"`
+	Justification string   `json:"justification,omitempty"`
+	Response      []string `json:"response,omitempty"`
+	Detail        string   `json:"detail,omitempty"`
+}
+
+type cdxSource struct {
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+type cdxRating struct {
+	Source   *cdxSource `json:"source,omitempty"`
+	Score    *float32   `json:"score,omitempty"`
+	Severity string     `json:"severity"`
+	Method   string     `json:"method,omitempty"`
+	Vector   string     `json:"vector,omitempty"`
+}
+
+type cdxAdvisory struct {
+	URL string `json:"url"`
+}
+
+type cdxAffect struct {
+	Ref string `json:"ref"`
+}
+
+// cdxStates and cdxJustifications map OpenVEX terms to CycloneDX analysis terms.
+var cdxStates = map[string]string{
+	"not_affected":        "not_affected",
+	"affected":            "exploitable",
+	"fixed":               "resolved",
+	"under_investigation": "in_triage",
+}
+
+var cdxJustifications = map[string]string{
+	"component_not_present":                             "code_not_present",
+	"vulnerable_code_not_present":                       "code_not_present",
+	"vulnerable_code_not_in_execute_path":               "code_not_reachable",
+	"vulnerable_code_cannot_be_controlled_by_adversary": "requires_environment",
+	"inline_mitigations_already_exist":                  "protected_by_mitigating_control",
+}
+
+// writeCycloneDX emits a BOM of the vulnerable packages with a vulnerabilities
+// section referencing them, the shape CycloneDX calls a BOM with embedded VEX.
+func writeCycloneDX(w io.Writer, r types.Report) error {
+	bom := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  cycloneDXSpecVersion,
+		SerialNumber: "urn:uuid:" + newUUID(),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Tools: cdxTools{Components: []cdxComponent{
+				{Type: "application", Name: "trivy-web-dash"},
+			}},
+			Component: cdxComponent{
+				BOMRef: r.ArtifactKey(),
+				Type:   "container",
+				Name:   r.ArtifactKey(),
+			},
+		},
+		Components:      []cdxComponent{},
+		Vulnerabilities: []cdxVulnerability{},
+	}
+
+	components := map[string]bool{}
+	vulns := map[string]*cdxVulnerability{}
+	var order []string
+	for _, res := range r.Results {
+		for _, v := range res.Vulnerabilities {
+			ref := componentRef(v)
+			if !components[ref] {
+				components[ref] = true
+				c := cdxComponent{BOMRef: ref, Type: "library", Name: v.PkgName, Version: v.InstalledVersion}
+				if v.PkgIdentifier != nil {
+					c.PURL = v.PkgIdentifier.PURL
+				}
+				bom.Components = append(bom.Components, c)
+			}
+
+			cv, ok := vulns[v.VulnerabilityID]
+			if !ok {
+				cv = newCDXVulnerability(v)
+				vulns[v.VulnerabilityID] = cv
+				order = append(order, v.VulnerabilityID)
+			}
+			cv.Affects = append(cv.Affects, cdxAffect{Ref: ref})
+		}
+	}
+	for _, id := range order {
+		bom.Vulnerabilities = append(bom.Vulnerabilities, *vulns[id])
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(bom)
+}
+
+func componentRef(v types.Vulnerability) string {
+	if v.PkgIdentifier != nil && v.PkgIdentifier.PURL != "" {
+		return v.PkgIdentifier.PURL
+	}
+	return fmt.Sprintf("%s@%s", v.PkgName, v.InstalledVersion)
+}
+
+func newCDXVulnerability(v types.Vulnerability) *cdxVulnerability {
+	cv := &cdxVulnerability{
+		BOMRef:      v.VulnerabilityID,
+		ID:          v.VulnerabilityID,
+		Description: v.Description,
+	}
+	if v.PrimaryURL != "" {
+		cv.Source = &cdxSource{URL: v.PrimaryURL}
+	}
+	if v.FixedVersion != "" {
+		cv.Recommendation = fmt.Sprintf("Upgrade %s to version %s", v.PkgName, v.FixedVersion)
+	}
+	for _, ref := range v.References {
+		cv.Advisories = append(cv.Advisories, cdxAdvisory{URL: ref})
+	}
+	for _, cwe := range v.CweIDs {
+		if n, err := strconv.Atoi(strings.TrimPrefix(cwe, "CWE-")); err == nil {
+			cv.CWEs = append(cv.CWEs, n)
+		}
+	}
+	if v.PublishedDate != nil {
+		cv.Published = v.PublishedDate.UTC().Format(time.RFC3339)
+	}
+	if v.LastModifiedDate != nil {
+		cv.Updated = v.LastModifiedDate.UTC().Format(time.RFC3339)
+	}
+
+	severity := strings.ToLower(v.Severity)
+	sources := make([]string, 0, len(v.CVSS))
+	for s := range v.CVSS {
+		sources = append(sources, s)
+	}
+	sort.Strings(sources)
+	for _, s := range sources {
+		c := v.CVSS[s]
+		if c.V3Score != nil {
+			cv.Ratings = append(cv.Ratings, cdxRating{
+				Source: &cdxSource{Name: s}, Score: c.V3Score, Severity: severity, Method: "CVSSv31", Vector: c.V3Vector,
+			})
+		}
+		if c.V2Score != nil {
+			cv.Ratings = append(cv.Ratings, cdxRating{
+				Source: &cdxSource{Name: s}, Score: c.V2Score, Severity: severity, Method: "CVSSv2", Vector: c.V2Vector,
+			})
+		}
+	}
+	if len(cv.Ratings) == 0 {
+		cv.Ratings = []cdxRating{{Severity: severity}}
+	}
+
+	switch {
+	case v.Suppression != nil:
+		// an accepted exception: still exploitable, but a deliberate decision not to fix
+		cv.Analysis = &cdxAnalysis{
+			State:    "exploitable",
+			Response: []string{"will_not_fix"},
+			Detail:   suppressionText(v),
+		}
+	case v.VEX != nil:
+		cv.Analysis = &cdxAnalysis{
+			State:         cdxStates[v.VEX.Status],
+			Justification: cdxJustifications[v.VEX.Justification],
+			Detail:        firstNonEmpty(v.VEX.ImpactStatement, v.VEX.ActionStatement, v.VEX.Justification),
+		}
+	}
+
+	return cv
+}
+
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func firstNonEmpty(s ...string) string {
+	for _, v := range s {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
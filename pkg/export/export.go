@@ -0,0 +1,90 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/trivy-web-dash/types"
+)
+
+type Format string
+
+const (
+	JSON      Format = "json"
+	SARIF     Format = "sarif"
+	CSV       Format = "csv"
+	JUnit     Format = "junit"
+	CycloneDX Format = "cyclonedx"
+)
+
+var contentTypes = map[Format]string{
+	JSON:      "application/json",
+	SARIF:     "application/sarif+json",
+	CSV:       "text/csv",
+	JUnit:     "application/xml",
+	CycloneDX: "application/vnd.cyclonedx+json",
+}
+
+type Options struct {
+	// Threshold is the lowest severity that fails a JUnit test case.
+	Threshold string
+}
+
+// ParseFormat validates the ?format= value of the report API, defaulting to JSON.
+func ParseFormat(s string) (Format, error) {
+	if s == "" {
+		return JSON, nil
+	}
+	f := Format(strings.ToLower(s))
+	if _, ok := contentTypes[f]; !ok {
+		return "", fmt.Errorf("unsupported export format %q", s)
+	}
+	return f, nil
+}
+
+// ParseThreshold validates the ?threshold= value of the report API. Empty
+// keeps the JUnit default.
+func ParseThreshold(s string) (string, error) {
+	s = strings.ToUpper(s)
+	if s != "" && !types.ValidSeverity(s) {
+		return "", fmt.Errorf("unknown severity %q", s)
+	}
+	return s, nil
+}
+
+func (f Format) ContentType() string {
+	return contentTypes[f]
+}
+
+// Write renders a stored report in the given format.
+func Write(w io.Writer, f Format, r types.Report, opts Options) error {
+	switch f {
+	case JSON:
+		return json.NewEncoder(w).Encode(r)
+	case SARIF:
+		return writeSARIF(w, r)
+	case CSV:
+		return writeCSV(w, r)
+	case JUnit:
+		return writeJUnit(w, r, opts.Threshold)
+	case CycloneDX:
+		return writeCycloneDX(w, r)
+	}
+	return fmt.Errorf("unsupported export format %q", f)
+}
+
+func artifactLocation(target string) string {
+	return strings.Split(target, " ")[0]
+}
+
+func suppressionText(v types.Vulnerability) string {
+	switch {
+	case v.Suppression != nil:
+		return fmt.Sprintf("%s %s: %s", v.Suppression.Source, v.Suppression.RuleID, v.Suppression.Justification)
+	case v.VEX != nil:
+		return fmt.Sprintf("vex %s (%s): %s", v.VEX.Status, v.VEX.DocumentID, v.VEX.Justification)
+	}
+	return ""
+}
@@ -0,0 +1,278 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/trivy-web-dash/types"
+)
+
+func score(f float32) *float32 { return &f }
+
+func testReport() types.Report {
+	return types.Report{
+		ArtifactName: "alpine:3.19",
+		Results: []types.Result{{
+			Target: "alpine:3.19 (alpine 3.19.1)",
+			Vulnerabilities: []types.Vulnerability{
+				{
+					VulnerabilityID:  "CVE-2024-0001",
+					PkgName:          "openssl",
+					PkgIdentifier:    &types.PkgIdentifier{PURL: "pkg:apk/alpine/openssl@3.1.4"},
+					InstalledVersion: "3.1.4",
+					FixedVersion:     "3.1.5",
+					Severity:         "CRITICAL",
+					Title:            "openssl: overflow",
+					PrimaryURL:       "https://avd.aquasec.com/nvd/cve-2024-0001",
+					CweIDs:           []string{"CWE-787"},
+					CVSS: map[string]types.CVSSInfo{
+						"nvd":    {V3Score: score(9.8), V3Vector: "CVSS:3.1/AV:N"},
+						"redhat": {V3Score: score(7.5), V2Score: score(5.0)},
+					},
+				},
+				{
+					VulnerabilityID:  "CVE-2024-0001",
+					PkgName:          "libcrypto3",
+					PkgIdentifier:    &types.PkgIdentifier{PURL: "pkg:apk/alpine/libcrypto3@3.1.4"},
+					InstalledVersion: "3.1.4",
+					Severity:         "CRITICAL",
+				},
+				{
+					VulnerabilityID:  "CVE-2024-0002",
+					PkgName:          "busybox",
+					InstalledVersion: "1.36.1",
+					Severity:         "MEDIUM",
+					Suppression:      &types.Suppression{Source: "exception", RuleID: "r1", Justification: "not reachable"},
+				},
+				{
+					VulnerabilityID:  "CVE-2024-0003",
+					PkgName:          "zlib",
+					InstalledVersion: "1.3",
+					Severity:         "LOW",
+					VEX:              &types.VEXStatement{Status: "not_affected", Justification: "vulnerable_code_not_present", DocumentID: "doc-1"},
+				},
+			},
+		}},
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Format
+		err  bool
+	}{
+		{"", JSON, false},
+		{"sarif", SARIF, false},
+		{"JUnit", JUnit, false},
+		{"CycloneDX", CycloneDX, false},
+		{"xml", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseFormat(%q) = %q, %v, want %q, error %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestParseThreshold(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{"", "", false},
+		{"critical", "CRITICAL", false},
+		{"Unknown", "UNKNOWN", false},
+		{"HIGH", "HIGH", false},
+		{"hihg", "", true},
+		{"severe", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseThreshold(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseThreshold(%q) = %q, %v, want %q, error %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, SARIF, testReport(), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+
+	run := log.Runs[0]
+	if run.Tool.Driver.Name != "Trivy" {
+		t.Errorf("driver = %s, want Trivy", run.Tool.Driver.Name)
+	}
+	if len(run.Tool.Driver.Rules) != 3 {
+		t.Fatalf("got %d rules, want one per vulnerability ID", len(run.Tool.Driver.Rules))
+	}
+	if got := run.Tool.Driver.Rules[0].Properties["security-severity"]; got != "9.8" {
+		t.Errorf("security-severity = %v, want the highest v3 score 9.8", got)
+	}
+	if len(run.Results) != 4 {
+		t.Fatalf("got %d results, want 4", len(run.Results))
+	}
+
+	levels := []string{"error", "error", "warning", "note"}
+	for i, res := range run.Results {
+		if res.Level != levels[i] {
+			t.Errorf("result %d level = %s, want %s", i, res.Level, levels[i])
+		}
+	}
+	if run.Results[1].RuleIndex != 0 {
+		t.Errorf("second openssl finding has rule index %d, want 0", run.Results[1].RuleIndex)
+	}
+	if uri := run.Results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI; uri != "alpine:3.19" {
+		t.Errorf("location uri = %q, want alpine:3.19", uri)
+	}
+	if s := run.Results[2].Suppressions; len(s) != 1 || s[0].Status != "accepted" {
+		t.Errorf("suppressed finding has suppressions %+v, want one accepted", s)
+	}
+	if len(run.Results[0].Suppressions) != 0 {
+		t.Errorf("open finding has suppressions %+v", run.Results[0].Suppressions)
+	}
+}
+
+func TestWriteSARIFGrype(t *testing.T) {
+	r := testReport()
+	r.Engine = types.EngineGrype
+	var buf bytes.Buffer
+	if err := Write(&buf, SARIF, r, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if d := log.Runs[0].Tool.Driver; d.Name != "Grype" || d.InformationURI != "https://github.com/anchore/grype" {
+		t.Errorf("driver = %s %s, want Grype", d.Name, d.InformationURI)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, CSV, testReport(), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("got %d rows, want header and 4 findings", len(rows))
+	}
+	if rows[0][0] != "Target" || rows[0][8] != "Suppression" {
+		t.Errorf("header = %q", rows[0])
+	}
+	if rows[1][1] != "CVE-2024-0001" || rows[1][5] != "3.1.5" {
+		t.Errorf("first row = %q", rows[1])
+	}
+	if want := "exception r1: not reachable"; rows[3][8] != want {
+		t.Errorf("suppression = %q, want %q", rows[3][8], want)
+	}
+	if want := "vex not_affected (doc-1): vulnerable_code_not_present"; rows[4][8] != want {
+		t.Errorf("vex suppression = %q, want %q", rows[4][8], want)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	tests := []struct {
+		threshold string
+		failures  int
+	}{
+		{"", 2},
+		{"CRITICAL", 2},
+		{"LOW", 2},
+		{"UNKNOWN", 2},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := Write(&buf, JUnit, testReport(), Options{Threshold: tt.threshold}); err != nil {
+			t.Fatal(err)
+		}
+		var suites junitTestSuites
+		if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+			t.Fatal(err)
+		}
+		suite := suites.Suites[0]
+		if suites.Tests != 4 || suite.Skipped != 2 {
+			t.Errorf("threshold %q: %d tests %d skipped, want 4 and the 2 suppressed", tt.threshold, suites.Tests, suite.Skipped)
+		}
+		if suites.Failures != tt.failures {
+			t.Errorf("threshold %q: %d failures, want %d", tt.threshold, suites.Failures, tt.failures)
+		}
+	}
+
+	// a MEDIUM finding only fails from the MEDIUM threshold down
+	r := testReport()
+	r.Results[0].Vulnerabilities[2].Suppression = nil
+	for threshold, failures := range map[string]int{"HIGH": 2, "MEDIUM": 3} {
+		var buf bytes.Buffer
+		if err := Write(&buf, JUnit, r, Options{Threshold: threshold}); err != nil {
+			t.Fatal(err)
+		}
+		var suites junitTestSuites
+		if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+			t.Fatal(err)
+		}
+		if suites.Failures != failures {
+			t.Errorf("threshold %s: %d failures, want %d", threshold, suites.Failures, failures)
+		}
+	}
+}
+
+func TestWriteCycloneDX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, CycloneDX, testReport(), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	var bom cdxBOM
+	if err := json.Unmarshal(buf.Bytes(), &bom); err != nil {
+		t.Fatal(err)
+	}
+
+	if bom.BOMFormat != "CycloneDX" || bom.Metadata.Component.Name != "alpine:3.19" {
+		t.Errorf("bom header = %s %+v", bom.BOMFormat, bom.Metadata.Component)
+	}
+	if len(bom.Components) != 4 {
+		t.Errorf("got %d components, want 4", len(bom.Components))
+	}
+	if len(bom.Vulnerabilities) != 3 {
+		t.Fatalf("got %d vulnerabilities, want 3", len(bom.Vulnerabilities))
+	}
+
+	v := bom.Vulnerabilities[0]
+	if len(v.Affects) != 2 || v.Affects[0].Ref != "pkg:apk/alpine/openssl@3.1.4" {
+		t.Errorf("affects = %+v, want both openssl packages by purl", v.Affects)
+	}
+	if len(v.Ratings) != 3 || v.Ratings[0].Source.Name != "nvd" || v.Ratings[0].Severity != "critical" {
+		t.Errorf("ratings = %+v", v.Ratings)
+	}
+	if len(v.CWEs) != 1 || v.CWEs[0] != 787 {
+		t.Errorf("cwes = %v, want [787]", v.CWEs)
+	}
+	if v.Recommendation != "Upgrade openssl to version 3.1.5" {
+		t.Errorf("recommendation = %q", v.Recommendation)
+	}
+	if v.Analysis != nil {
+		t.Errorf("open finding has analysis %+v", v.Analysis)
+	}
+
+	if a := bom.Vulnerabilities[1].Analysis; a == nil || a.State != "exploitable" || a.Response[0] != "will_not_fix" {
+		t.Errorf("exception analysis = %+v", a)
+	}
+	if a := bom.Vulnerabilities[2].Analysis; a == nil || a.State != "not_affected" || a.Justification != "code_not_present" {
+		t.Errorf("vex analysis = %+v", a)
+	}
+}
@@ -0,0 +1,96 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/trivy-web-dash/types"
+)
+
+const defaultJUnitThreshold = "HIGH"
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// writeJUnit emits one test case per vulnerability, failing those at or above
+// threshold so CI test tabs show the blocking findings. Suppressed findings are
+// reported as skipped.
+func writeJUnit(w io.Writer, r types.Report, threshold string) error {
+	if threshold == "" {
+		threshold = defaultJUnitThreshold
+	}
+	minRank := types.SeverityRank(threshold)
+
+	suites := junitTestSuites{Name: r.ArtifactKey()}
+	for _, res := range r.Results {
+		suite := junitTestSuite{Name: res.Target}
+		for _, v := range res.Vulnerabilities {
+			tc := junitTestCase{
+				ClassName: fmt.Sprintf("%s-%s", v.PkgName, v.InstalledVersion),
+				Name:      fmt.Sprintf("[%s] %s", v.Severity, v.VulnerabilityID),
+			}
+			if v.Suppressed() {
+				tc.Skipped = &junitSkipped{Message: suppressionText(v)}
+				suite.Skipped++
+			} else if types.SeverityRank(v.Severity) >= minRank {
+				msg := v.Title
+				if msg == "" {
+					msg = v.VulnerabilityID
+				}
+				tc.Failure = &junitFailure{
+					Message: msg,
+					Type:    v.Severity,
+					Body:    fmt.Sprintf("%s\nFixed version: %s\n%s", v.Description, v.FixedVersion, v.PrimaryURL),
+				}
+				suite.Failures++
+			}
+			suite.Tests++
+			suite.TestCases = append(suite.TestCases, tc)
+		}
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,229 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/trivy-web-dash/types"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// sarifDrivers names the tool of a report after the engine that scanned it.
+var sarifDrivers = map[string]sarifDriver{
+	types.EngineTrivy: {Name: "Trivy", InformationURI: "https://github.com/aquasecurity/trivy"},
+	types.EngineGrype: {Name: "Grype", InformationURI: "https://github.com/anchore/grype"},
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifMessage struct {
+	Text     string `json:"text"`
+	Markdown string `json:"markdown,omitempty"`
+}
+
+type sarifRule struct {
+	ID                   string                 `json:"id"`
+	Name                 string                 `json:"name"`
+	ShortDescription     sarifMessage           `json:"shortDescription"`
+	FullDescription      sarifMessage           `json:"fullDescription"`
+	HelpURI              string                 `json:"helpUri,omitempty"`
+	Help                 sarifMessage           `json:"help"`
+	DefaultConfiguration sarifConfiguration     `json:"defaultConfiguration"`
+	Properties           map[string]interface{} `json:"properties"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifResult struct {
+	RuleID       string             `json:"ruleId"`
+	RuleIndex    int                `json:"ruleIndex"`
+	Level        string             `json:"level"`
+	Message      sarifMessage       `json:"message"`
+	Locations    []sarifLocation    `json:"locations"`
+	Suppressions []sarifSuppression `json:"suppressions,omitempty"`
+}
+
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Status        string `json:"status"`
+	Justification string `json:"justification"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          sarifMessage          `json:"message"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+func writeSARIF(w io.Writer, r types.Report) error {
+	driver := sarifDrivers[r.EngineName()]
+	driver.Rules = []sarifRule{}
+	run := sarifRun{
+		Tool:    sarifTool{Driver: driver},
+		Results: []sarifResult{},
+	}
+
+	ruleIndex := map[string]int{}
+	for _, res := range r.Results {
+		for _, v := range res.Vulnerabilities {
+			idx, ok := ruleIndex[v.VulnerabilityID]
+			if !ok {
+				idx = len(run.Tool.Driver.Rules)
+				ruleIndex[v.VulnerabilityID] = idx
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, newSARIFRule(v))
+			}
+
+			msg := fmt.Sprintf("Package: %s\nInstalled Version: %s\nVulnerability %s\nSeverity: %s\nFixed Version: %s\nLink: %s",
+				v.PkgName, v.InstalledVersion, v.VulnerabilityID, v.Severity, v.FixedVersion, v.PrimaryURL)
+			result := sarifResult{
+				RuleID:    v.VulnerabilityID,
+				RuleIndex: idx,
+				Level:     sarifLevel(v.Severity),
+				Message:   sarifMessage{Text: msg},
+				Locations: []sarifLocation{{
+					PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifactLocation{
+							URI:       artifactLocation(res.Target),
+							URIBaseID: "ROOTPATH",
+						},
+						Region: sarifRegion{StartLine: 1, StartColumn: 1, EndLine: 1, EndColumn: 1},
+					},
+					Message: sarifMessage{Text: fmt.Sprintf("%s: %s@%s", res.Target, v.PkgName, v.InstalledVersion)},
+				}},
+			}
+			if v.Suppression != nil || v.VEX != nil {
+				result.Suppressions = []sarifSuppression{{
+					Kind:          "external",
+					Status:        sarifSuppressionStatus(v),
+					Justification: suppressionText(v),
+				}}
+			}
+			run.Results = append(run.Results, result)
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs:    []sarifRun{run},
+	})
+}
+
+func newSARIFRule(v types.Vulnerability) sarifRule {
+	title := v.Title
+	if title == "" {
+		title = v.VulnerabilityID
+	}
+
+	props := map[string]interface{}{
+		"precision": "very-high",
+		"tags":      []string{"vulnerability", "security", v.Severity},
+	}
+	if score, _, ok := cvssScore(v); ok {
+		props["security-severity"] = fmt.Sprintf("%.1f", score)
+	}
+
+	return sarifRule{
+		ID:               v.VulnerabilityID,
+		Name:             "OsPackageVulnerability",
+		ShortDescription: sarifMessage{Text: title},
+		FullDescription:  sarifMessage{Text: strings.TrimSpace(v.Description)},
+		HelpURI:          v.PrimaryURL,
+		Help: sarifMessage{
+			Text: fmt.Sprintf("Vulnerability %s\nSeverity: %s\nPackage: %s\nFixed Version: %s\nLink: %s\n%s",
+				v.VulnerabilityID, v.Severity, v.PkgName, v.FixedVersion, v.PrimaryURL, v.Description),
+			Markdown: fmt.Sprintf("**Vulnerability %s**\n| Severity | Package | Fixed Version | Link |\n| --- | --- | --- | --- |\n|%s|%s|%s|[%s](%s)|\n\n%s",
+				v.VulnerabilityID, v.Severity, v.PkgName, v.FixedVersion, v.VulnerabilityID, v.PrimaryURL, v.Description),
+		},
+		DefaultConfiguration: sarifConfiguration{Level: sarifLevel(v.Severity)},
+		Properties:           props,
+	}
+}
+
+func sarifSuppressionStatus(v types.Vulnerability) string {
+	if v.Suppressed() {
+		return "accepted"
+	}
+	return "underReview"
+}
+
+func sarifLevel(severity string) string {
+	switch severity {
+	case "CRITICAL", "HIGH":
+		return "error"
+	case "MEDIUM":
+		return "warning"
+	case "LOW", "UNKNOWN":
+		return "note"
+	}
+	return "none"
+}
+
+// cvssScore returns the highest v3 (or, failing that, v2) score reported by
+// any source along with its vector.
+func cvssScore(v types.Vulnerability) (float32, string, bool) {
+	var (
+		best   float32
+		vector string
+		found  bool
+	)
+	for _, c := range v.CVSS {
+		if c.V3Score != nil && (!found || *c.V3Score > best) {
+			best, vector, found = *c.V3Score, c.V3Vector, true
+		}
+	}
+	if found {
+		return best, vector, true
+	}
+	for _, c := range v.CVSS {
+		if c.V2Score != nil && (!found || *c.V2Score > best) {
+			best, vector, found = *c.V2Score, c.V2Vector, true
+		}
+	}
+	return best, vector, found
+}
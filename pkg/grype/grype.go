@@ -0,0 +1,153 @@
+package grype
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/pkg/metrics"
+	"github.com/trivy-web-dash/pkg/osmgr"
+	"github.com/trivy-web-dash/pkg/tracing"
+	tc "github.com/trivy-web-dash/pkg/trivy"
+	"github.com/trivy-web-dash/types"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/xerrors"
+)
+
+const grypeCmd = "grype"
+
+// Client scans with the grype binary and normalizes its JSON output into
+// trivy's report format. Grype keeps its own vulnerability DB.
+type Client struct {
+	logger logger.Logger
+	mgr    osmgr.Mgr
+}
+
+func NewClient(l logger.Logger) *Client {
+	return NewClientWithMgr(l, osmgr.DefaultMgr)
+}
+
+// NewClientWithMgr runs grype through mgr, which tests replace.
+func NewClientWithMgr(l logger.Logger, mgr osmgr.Mgr) *Client {
+	return &Client{
+		logger: l,
+		mgr:    mgr,
+	}
+}
+
+// Scan scans imageRef, or its platform variant when platform is set.
+func (g *Client) Scan(ctx context.Context, imageRef, platform string) (*types.Report, error) {
+	var args []string
+	if platform != "" {
+		args = []string{"--platform", platform}
+	}
+	return g.run(ctx, "image", imageRef, args...)
+}
+
+// ScanSBOM scans a CycloneDX, SPDX or syft document.
+func (g *Client) ScanSBOM(ctx context.Context, sbom []byte) (report *types.Report, err error) {
+	sbomFile, err := g.mgr.TempFile("/tmp/", "sbom_*.json")
+	if err != nil {
+		g.logger.Debugf("error creating sbom tmp file : %v", err)
+		return nil, err
+	}
+
+	defer func() {
+		if err := g.mgr.Remove(sbomFile.Name()); err != nil {
+			g.logger.Errorf("unable to remove sbom tmp file : %s", err.Error())
+		}
+	}()
+
+	if _, err := sbomFile.Write(sbom); err != nil {
+		sbomFile.Close()
+		return nil, xerrors.Errorf("writing sbom tmp file: %w", err)
+	}
+	if err := sbomFile.Close(); err != nil {
+		return nil, xerrors.Errorf("closing sbom tmp file: %w", err)
+	}
+
+	return g.run(ctx, "sbom", "sbom:"+sbomFile.Name())
+}
+
+func (g *Client) run(ctx context.Context, kind, target string, extraArgs ...string) (report *types.Report, err error) {
+	_, span := tracing.Start(ctx, "grype "+kind, attribute.String("grype.target", target))
+	defer func() { tracing.End(span, err) }()
+
+	reportFile, err := g.mgr.TempFile("/tmp/", "grype_report_*.json")
+	if err != nil {
+		g.logger.Debugf("error creating report tmp file : %v", err)
+		return nil, err
+	}
+	defer func() {
+		if err := g.mgr.Remove(reportFile.Name()); err != nil {
+			g.logger.Errorf("unable to remove grype report tmp file : %s", err.Error())
+		}
+	}()
+
+	name, err := g.mgr.LookPath(grypeCmd)
+	if err != nil {
+		return nil, err
+	}
+	// --only-fixed matches the --ignore-unfixed trivy scans run with
+	args := append([]string{target, "--output", "json", "--file", reportFile.Name(), "--only-fixed", "--quiet"}, extraArgs...)
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(g.mgr.Environ(), "GRYPE_CHECK_FOR_APP_UPDATE=false")
+	if creds, ok := tc.CredentialsFrom(ctx); ok {
+		cmd.Env = append(cmd.Env, "GRYPE_REGISTRY_AUTH_USERNAME="+creds.Username, "GRYPE_REGISTRY_AUTH_PASSWORD="+creds.Password)
+	}
+
+	g.logger.Debugf("executing command path: %s args: %+q", cmd.Path, cmd.Args)
+
+	start := time.Now()
+	stdout, err := g.mgr.RunCmd(cmd)
+	metrics.ScanDuration.WithLabelValues("grype " + kind).Observe(time.Since(start).Seconds())
+	span.SetAttributes(attribute.Int("grype.exit_code", cmd.ProcessState.ExitCode()))
+	if err != nil {
+		g.logger.Errorf("grype run failed target : %s exit_code : %d stdout : %s", target, cmd.ProcessState.ExitCode(), string(stdout))
+		return nil, xerrors.Errorf("running grype: %v: %v", err, string(stdout))
+	}
+
+	var doc document
+	if err := json.NewDecoder(reportFile).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decoding grype report from file: %w", err)
+	}
+	r := doc.report()
+	return &r, nil
+}
+
+// Version returns the grype version, grype reports no DB dates.
+func (g *Client) Version() (*types.VersionInfo, error) {
+	name, err := g.mgr.LookPath(grypeCmd)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := g.mgr.RunCmd(exec.Command(name, "version", "--output", "json"))
+	if err != nil {
+		return nil, fmt.Errorf("running grype: %v: %v", err, string(out))
+	}
+
+	var v struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(out, &v); err != nil {
+		return nil, err
+	}
+	return &types.VersionInfo{Version: v.Version}, nil
+}
+
+// cvssKey names grype's CVSS sources the way trivy does, e.g. "nvd".
+func cvssKey(source string) string {
+	if source == "nvd@nist.gov" || source == "" {
+		return "nvd"
+	}
+	return source
+}
+
+func float32Ptr(f float64) *float32 {
+	v := float32(f)
+	return &v
+}
@@ -0,0 +1,82 @@
+package grype
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/pkg/osmgr/osmgrtest"
+	tc "github.com/trivy-web-dash/pkg/trivy"
+)
+
+func newTestClient(t *testing.T) (*Client, *osmgrtest.Mgr) {
+	t.Helper()
+	log := logger.NewAppLogger("fatal")
+	log.InitLogger()
+	mgr := osmgrtest.New()
+	return NewClientWithMgr(log, mgr), mgr
+}
+
+func TestScan(t *testing.T) {
+	c, mgr := newTestClient(t)
+	mgr.On("grype", "alpine:3.19", osmgrtest.Run{Report: imageDocument})
+	mgr.On("grype", "broken:1", osmgrtest.Run{Err: errors.New("exit status 1"), Stdout: "could not fetch image"})
+	mgr.On("grype", "garbled:1", osmgrtest.Run{Report: "{not json"})
+
+	r, err := c.Scan(context.Background(), "alpine:3.19", "linux/arm64/v8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ArtifactName != "alpine:3.19" || len(r.Results) != 2 {
+		t.Errorf("Scan() = %+v", r)
+	}
+	args := mgr.Commands()[0]
+	if !slices.Contains(args, "--only-fixed") || strings.Join(args[len(args)-2:], " ") != "--platform linux/arm64/v8" {
+		t.Errorf("grype ran with %q", args)
+	}
+
+	if _, err := c.Scan(context.Background(), "broken:1", ""); err == nil || !strings.Contains(err.Error(), "could not fetch image") {
+		t.Errorf("Scan() of a failing run = %v", err)
+	}
+	if _, err := c.Scan(context.Background(), "garbled:1", ""); err == nil {
+		t.Error("Scan() of an unreadable report succeeded")
+	}
+	if files := mgr.Files(); len(files) != 0 {
+		t.Errorf("temporary files left behind: %q", files)
+	}
+}
+
+func TestScanCredentials(t *testing.T) {
+	c, mgr := newTestClient(t)
+	mgr.On("grype", "registry.example.com/team/app:1.0", osmgrtest.Run{Report: imageDocument})
+
+	ctx := tc.WithCredentials(context.Background(), tc.Credentials{Username: "robot", Password: "s3cret"})
+	if _, err := c.Scan(ctx, "registry.example.com/team/app:1.0", ""); err != nil {
+		t.Fatal(err)
+	}
+	env := mgr.Environments()[0]
+	if !slices.Contains(env, "GRYPE_REGISTRY_AUTH_USERNAME=robot") || !slices.Contains(env, "GRYPE_REGISTRY_AUTH_PASSWORD=s3cret") {
+		t.Errorf("grype ran with environment %q", env)
+	}
+	if slices.Contains(mgr.Commands()[0], "s3cret") {
+		t.Errorf("password passed as an argument: %q", mgr.Commands()[0])
+	}
+}
+
+func TestScanSBOM(t *testing.T) {
+	c, mgr := newTestClient(t)
+	mgr.On("grype", "", osmgrtest.Run{Report: `{"source": {"type": "sbom"}, "matches": []}`})
+
+	if _, err := c.ScanSBOM(context.Background(), []byte(`{"bomFormat": "CycloneDX"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if target := mgr.Commands()[0][1]; !strings.HasPrefix(target, "sbom:") {
+		t.Errorf("grype scanned %q", target)
+	}
+	if files := mgr.Files(); len(files) != 0 {
+		t.Errorf("temporary files left behind: %q", files)
+	}
+}
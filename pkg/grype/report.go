@@ -0,0 +1,191 @@
+package grype
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/trivy-web-dash/types"
+)
+
+// document is the part of grype's JSON output the report is built from.
+type document struct {
+	Matches []match `json:"matches"`
+	Source  struct {
+		Type   string          `json:"type"`
+		Target json.RawMessage `json:"target"`
+	} `json:"source"`
+	Distro struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"distro"`
+}
+
+type imageTarget struct {
+	UserInput   string   `json:"userInput"`
+	ImageID     string   `json:"imageID"`
+	Tags        []string `json:"tags"`
+	RepoDigests []string `json:"repoDigests"`
+	Layers      []struct {
+		Digest string `json:"digest"`
+	} `json:"layers"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant"`
+	OS           string `json:"os"`
+}
+
+type match struct {
+	Vulnerability struct {
+		ID          string   `json:"id"`
+		DataSource  string   `json:"dataSource"`
+		Severity    string   `json:"severity"`
+		URLs        []string `json:"urls"`
+		Description string   `json:"description"`
+		CVSS        []cvss   `json:"cvss"`
+		Fix         struct {
+			Versions []string `json:"versions"`
+		} `json:"fix"`
+	} `json:"vulnerability"`
+	RelatedVulnerabilities []struct {
+		ID          string `json:"id"`
+		Description string `json:"description"`
+		CVSS        []cvss `json:"cvss"`
+	} `json:"relatedVulnerabilities"`
+	Artifact struct {
+		Name      string `json:"name"`
+		Version   string `json:"version"`
+		Type      string `json:"type"`
+		PURL      string `json:"purl"`
+		Locations []struct {
+			Path    string `json:"path"`
+			LayerID string `json:"layerID"`
+		} `json:"locations"`
+	} `json:"artifact"`
+}
+
+type cvss struct {
+	Source  string `json:"source"`
+	Version string `json:"version"`
+	Vector  string `json:"vector"`
+	Metrics struct {
+		BaseScore float64 `json:"baseScore"`
+	} `json:"metrics"`
+}
+
+// osPackageTypes are the artifact types grype reports for distro packages.
+var osPackageTypes = map[string]bool{"apk": true, "deb": true, "rpm": true, "alpm": true, "portage": true}
+
+// report groups the matches into results like trivy: one for the distro
+// packages and one per file for language packages.
+func (d document) report() types.Report {
+	r := types.Report{Engine: types.EngineGrype}
+
+	if d.Source.Type == "image" {
+		var t imageTarget
+		if err := json.Unmarshal(d.Source.Target, &t); err == nil {
+			r.ArtifactName = t.UserInput
+			r.ArtifactType = "container_image"
+			r.Metadata = &types.ImageMetadata{
+				ImageID:     t.ImageID,
+				RepoTags:    t.Tags,
+				RepoDigests: t.RepoDigests,
+				ImageConfig: types.ImageConfig{Architecture: t.Architecture, OS: t.OS, Variant: t.Variant},
+			}
+			for _, l := range t.Layers {
+				r.Metadata.DiffIDs = append(r.Metadata.DiffIDs, l.Digest)
+			}
+			if d.Distro.Name != "" {
+				r.Metadata.OS = &types.OS{Family: d.Distro.Name, Name: d.Distro.Version}
+			}
+		}
+	}
+
+	results := map[string]int{}
+	for _, m := range d.Matches {
+		res := types.Result{Target: r.ArtifactName, Class: "os-pkgs", Type: d.Distro.Name}
+		if d.Distro.Name != "" {
+			res.Target += " (" + d.Distro.Name + " " + d.Distro.Version + ")"
+		}
+		if !osPackageTypes[m.Artifact.Type] {
+			res = types.Result{Class: "lang-pkgs", Type: m.Artifact.Type}
+			if len(m.Artifact.Locations) > 0 {
+				res.Target = m.Artifact.Locations[0].Path
+			}
+		}
+
+		key := res.Target + "|" + res.Class + "|" + res.Type
+		i, ok := results[key]
+		if !ok {
+			i = len(r.Results)
+			results[key] = i
+			r.Results = append(r.Results, res)
+		}
+		r.Results[i].Vulnerabilities = append(r.Results[i].Vulnerabilities, m.vulnerability())
+	}
+	return r
+}
+
+// vulnerability converts a match, preferring the CVE among related
+// vulnerabilities so that findings line up with trivy's.
+func (m match) vulnerability() types.Vulnerability {
+	v := types.Vulnerability{
+		VulnerabilityID:  m.Vulnerability.ID,
+		PkgName:          m.Artifact.Name,
+		InstalledVersion: m.Artifact.Version,
+		FixedVersion:     strings.Join(m.Vulnerability.Fix.Versions, ", "),
+		Description:      m.Vulnerability.Description,
+		Severity:         severity(m.Vulnerability.Severity),
+		References:       m.Vulnerability.URLs,
+		PrimaryURL:       m.Vulnerability.DataSource,
+	}
+	if m.Artifact.PURL != "" {
+		v.PkgIdentifier = &types.PkgIdentifier{PURL: m.Artifact.PURL}
+	}
+	if len(m.Artifact.Locations) > 0 && m.Artifact.Locations[0].LayerID != "" {
+		v.Layer = &types.Layer{DiffID: m.Artifact.Locations[0].LayerID}
+	}
+
+	scores := m.Vulnerability.CVSS
+	if !strings.HasPrefix(v.VulnerabilityID, "CVE-") {
+		for _, rel := range m.RelatedVulnerabilities {
+			if strings.HasPrefix(rel.ID, "CVE-") {
+				v.VulnerabilityID = rel.ID
+				if v.Description == "" {
+					v.Description = rel.Description
+				}
+				scores = append(scores, rel.CVSS...)
+				break
+			}
+		}
+	}
+
+	for _, s := range scores {
+		key := cvssKey(s.Source)
+		info := v.CVSS[key]
+		switch {
+		case strings.HasPrefix(s.Version, "3"):
+			info.V3Vector, info.V3Score = s.Vector, float32Ptr(s.Metrics.BaseScore)
+		case strings.HasPrefix(s.Version, "2"):
+			info.V2Vector, info.V2Score = s.Vector, float32Ptr(s.Metrics.BaseScore)
+		default:
+			continue
+		}
+		if v.CVSS == nil {
+			v.CVSS = map[string]types.CVSSInfo{}
+		}
+		v.CVSS[key] = info
+	}
+	return v
+}
+
+// severity maps grype's severities onto trivy's. Negligible findings count as
+// LOW, as trivy does for distros that use it.
+func severity(s string) string {
+	switch s = strings.ToUpper(s); s {
+	case "CRITICAL", "HIGH", "MEDIUM", "LOW":
+		return s
+	case "NEGLIGIBLE":
+		return "LOW"
+	default:
+		return "UNKNOWN"
+	}
+}
@@ -0,0 +1,125 @@
+package grype
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const imageDocument = `{
+  "source": {
+    "type": "image",
+    "target": {
+      "userInput": "alpine:3.19",
+      "imageID": "sha256:05455a08881e",
+      "tags": ["alpine:3.19"],
+      "repoDigests": ["alpine@sha256:c5b1261d6d3e"],
+      "layers": [{"digest": "sha256:d4fc045c9e3a"}],
+      "architecture": "arm64",
+      "variant": "v8",
+      "os": "linux"
+    }
+  },
+  "distro": {"name": "alpine", "version": "3.19.1"},
+  "matches": [
+    {
+      "vulnerability": {
+        "id": "CVE-2024-0727",
+        "dataSource": "https://security.alpinelinux.org/vuln/CVE-2024-0727",
+        "severity": "Medium",
+        "fix": {"versions": ["3.1.4-r5"]},
+        "cvss": [
+          {"source": "nvd@nist.gov", "version": "3.1", "vector": "CVSS:3.1/AV:L", "metrics": {"baseScore": 5.5}},
+          {"source": "nvd@nist.gov", "version": "2.0", "vector": "AV:N/AC:L", "metrics": {"baseScore": 5}}
+        ]
+      },
+      "artifact": {
+        "name": "libcrypto3", "version": "3.1.4-r2", "type": "apk",
+        "purl": "pkg:apk/alpine/libcrypto3@3.1.4-r2",
+        "locations": [{"path": "/lib/apk/db/installed", "layerID": "sha256:d4fc045c9e3a"}]
+      }
+    },
+    {
+      "vulnerability": {"id": "CVE-2024-2511", "severity": "Negligible", "fix": {"versions": ["3.1.4-r6", "3.2.1-r1"]}},
+      "artifact": {"name": "libssl3", "version": "3.1.4-r2", "type": "apk", "locations": [{"path": "/lib/apk/db/installed"}]}
+    },
+    {
+      "vulnerability": {
+        "id": "GHSA-m425-mq94-257g",
+        "severity": "High",
+        "cvss": [{"source": "github", "version": "3.1", "vector": "CVSS:3.1/AV:N", "metrics": {"baseScore": 7.5}}]
+      },
+      "relatedVulnerabilities": [
+        {"id": "CVE-2023-44487", "description": "HTTP/2 rapid reset",
+         "cvss": [{"source": "nvd@nist.gov", "version": "3.1", "vector": "CVSS:3.1/AV:N/AC:L", "metrics": {"baseScore": 7.5}}]}
+      ],
+      "artifact": {"name": "google.golang.org/grpc", "version": "1.56.2", "type": "go-module", "locations": [{"path": "/usr/bin/app"}]}
+    },
+    {
+      "vulnerability": {"id": "GHSA-xxxx-0000-0000", "severity": "weird"},
+      "artifact": {"name": "golang.org/x/net", "version": "0.10.0", "type": "go-module", "locations": [{"path": "/usr/bin/app"}]}
+    }
+  ]
+}`
+
+func TestReport(t *testing.T) {
+	var doc document
+	if err := json.Unmarshal([]byte(imageDocument), &doc); err != nil {
+		t.Fatal(err)
+	}
+	r := doc.report()
+
+	if r.Engine != "grype" || r.ArtifactName != "alpine:3.19" || r.ArtifactType != "container_image" {
+		t.Errorf("report = %+v", r)
+	}
+	m := r.Metadata
+	if m == nil || m.ImageID != "sha256:05455a08881e" || m.ImageConfig.Architecture != "arm64" || m.ImageConfig.Variant != "v8" ||
+		len(m.DiffIDs) != 1 || m.OS == nil || m.OS.Family != "alpine" || m.OS.Name != "3.19.1" {
+		t.Fatalf("metadata = %+v", m)
+	}
+
+	// distro packages share one result, language packages get one per file
+	if len(r.Results) != 2 {
+		t.Fatalf("results = %+v", r.Results)
+	}
+	osPkgs, langPkgs := r.Results[0], r.Results[1]
+	if osPkgs.Target != "alpine:3.19 (alpine 3.19.1)" || osPkgs.Class != "os-pkgs" || osPkgs.Type != "alpine" || len(osPkgs.Vulnerabilities) != 2 {
+		t.Errorf("os result = %+v", osPkgs)
+	}
+	if langPkgs.Target != "/usr/bin/app" || langPkgs.Class != "lang-pkgs" || langPkgs.Type != "go-module" || len(langPkgs.Vulnerabilities) != 2 {
+		t.Errorf("language result = %+v", langPkgs)
+	}
+
+	crypto := osPkgs.Vulnerabilities[0]
+	nvd := crypto.CVSS["nvd"]
+	if crypto.Severity != "MEDIUM" || crypto.FixedVersion != "3.1.4-r5" || crypto.PkgIdentifier == nil || crypto.Layer == nil ||
+		crypto.Layer.DiffID != "sha256:d4fc045c9e3a" || nvd.V3Score == nil || *nvd.V3Score != 5.5 || nvd.V2Vector != "AV:N/AC:L" {
+		t.Errorf("libcrypto3 = %+v", crypto)
+	}
+	if ssl := osPkgs.Vulnerabilities[1]; ssl.Severity != "LOW" || ssl.FixedVersion != "3.1.4-r6, 3.2.1-r1" || ssl.CVSS != nil || ssl.Layer != nil {
+		t.Errorf("libssl3 = %+v", ssl)
+	}
+
+	// GitHub advisories are reported under their CVE to line up with trivy
+	grpc := langPkgs.Vulnerabilities[0]
+	if grpc.VulnerabilityID != "CVE-2023-44487" || grpc.Description != "HTTP/2 rapid reset" || grpc.CVSS["github"].V3Score == nil || grpc.CVSS["nvd"].V3Vector != "CVSS:3.1/AV:N/AC:L" {
+		t.Errorf("grpc = %+v", grpc)
+	}
+	if net := langPkgs.Vulnerabilities[1]; net.VulnerabilityID != "GHSA-xxxx-0000-0000" || net.Severity != "UNKNOWN" {
+		t.Errorf("x/net = %+v", net)
+	}
+}
+
+func TestReportSBOM(t *testing.T) {
+	var doc document
+	err := json.Unmarshal([]byte(`{
+		"source": {"type": "sbom", "target": {}},
+		"matches": [{"vulnerability": {"id": "CVE-2024-0727", "severity": "High"}, "artifact": {"name": "openssl", "version": "3.0.0", "type": "rpm"}}]
+	}`), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := doc.report()
+	if r.Metadata != nil || len(r.Results) != 1 || r.Results[0].Class != "os-pkgs" || r.Results[0].Target != "" {
+		t.Errorf("report = %+v", r)
+	}
+}
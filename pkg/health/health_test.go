@@ -0,0 +1,89 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+
+	redisx "github.com/trivy-web-dash/pkg/db/redis"
+)
+
+func TestRun(t *testing.T) {
+	c := New(50 * time.Millisecond)
+	c.Add("redis", func(ctx context.Context) error { return errors.New("connection refused") })
+	c.Add("redis", func(ctx context.Context) error { return nil })
+	c.Add("slow", func(ctx context.Context) error { time.Sleep(time.Second); return nil })
+
+	start := time.Now()
+	report := c.Run(context.Background())
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Run() waited %v for a hanging check", time.Since(start))
+	}
+	if report.Status != statusFail || len(report.Checks) != 2 || report.Checks["redis"].Status != statusOK ||
+		!strings.Contains(report.Checks["slow"].Error, "timed out") {
+		t.Errorf("Run() = %+v", report)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	redis := miniredis.RunT(t)
+	pool, err := redisx.NewPool(redis.Addr(), "", 0, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(healthy.Close)
+
+	c := New(time.Second)
+	c.Add("redis", Redis(pool))
+	c.Add("trivy", TrivyServer(healthy.URL+"/"))
+	r := gin.New()
+	r.GET("/readyz", c.Handler())
+	get := func() (int, Report) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var report Report
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, report
+	}
+
+	if code, report := get(); code != http.StatusOK || report.Status != statusOK {
+		t.Errorf("healthy: %d %+v", code, report)
+	}
+
+	redis.Close()
+	code, report := get()
+	if code != http.StatusServiceUnavailable || report.Checks["redis"].Status != statusFail || report.Checks["trivy"].Status != statusOK {
+		t.Errorf("without redis: %d %+v", code, report)
+	}
+}
+
+func TestTrivyServer(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(down.Close)
+
+	if err := TrivyServer(down.URL)(context.Background()); err == nil || !strings.Contains(err.Error(), "status 503") {
+		t.Errorf("TrivyServer() = %v", err)
+	}
+	down.Close()
+	if err := TrivyServer(down.URL)(context.Background()); err == nil {
+		t.Error("TrivyServer() of a stopped server succeeded")
+	}
+}
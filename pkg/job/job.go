@@ -20,8 +20,15 @@ func (s ScanJobStatus) String() string {
 	return [...]string{"Queued", "Pending", "Scanned", "ScanFail", "WebhookFail", "Done"}[s]
 }
 
+// Active reports whether a job with status s is still waiting for its scan.
+func (s ScanJobStatus) Active() bool {
+	return s == Queued || s == Pending
+}
+
 type ScanJob struct {
 	ID      string        `json:"id"`
+	Project string        `json:"project,omitempty"`
+	Image   string        `json:"image,omitempty"`
 	Status  ScanJobStatus `json:"status"`
 	Error   string        `json:"error"`
 	Report  types.Report  `json:"report"`
@@ -0,0 +1,173 @@
+package kube
+
+import (
+	"context"
+	"sort"
+
+	"golang.org/x/xerrors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/trivy-web-dash/types"
+)
+
+const listPageSize = 500
+
+// NewClientset connects with kubeconfig, or with the in-cluster service account
+// when kubeconfig is empty.
+func NewClientset(kubeconfig string) (kubernetes.Interface, error) {
+	var (
+		config *rest.Config
+		err    error
+	)
+	if kubeconfig == "" {
+		config, err = rest.InClusterConfig()
+	} else {
+		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("loading kubernetes config: %w", err)
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// Discoverer collects the images of running pods along with the workloads that
+// run them.
+type Discoverer struct {
+	client  kubernetes.Interface
+	cluster string
+}
+
+func NewDiscoverer(client kubernetes.Interface, cluster string) *Discoverer {
+	return &Discoverer{client: client, cluster: cluster}
+}
+
+func (d *Discoverer) Cluster() string {
+	return d.cluster
+}
+
+// Discover lists pods in all namespaces and returns the workloads running each
+// image reference. Completed pods are ignored.
+func (d *Discoverer) Discover(ctx context.Context) (map[string][]types.Workload, error) {
+	owners := newOwnerResolver(d.client)
+	images := map[string][]types.Workload{}
+	seen := map[string]map[types.Workload]bool{}
+
+	opts := metav1.ListOptions{Limit: listPageSize}
+	for {
+		pods, err := d.client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, opts)
+		if err != nil {
+			return nil, xerrors.Errorf("listing pods: %w", err)
+		}
+
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+				continue
+			}
+
+			kind, name := owners.resolve(ctx, pod)
+			w := types.Workload{Cluster: d.cluster, Namespace: pod.Namespace, Kind: kind, Name: name}
+			for _, image := range podImages(pod) {
+				if seen[image] == nil {
+					seen[image] = map[types.Workload]bool{}
+				}
+				if !seen[image][w] {
+					seen[image][w] = true
+					images[image] = append(images[image], w)
+				}
+			}
+		}
+
+		if pods.Continue == "" {
+			break
+		}
+		opts.Continue = pods.Continue
+	}
+
+	for _, workloads := range images {
+		sort.Slice(workloads, func(i, j int) bool {
+			if workloads[i].Namespace != workloads[j].Namespace {
+				return workloads[i].Namespace < workloads[j].Namespace
+			}
+			return workloads[i].Kind+"/"+workloads[i].Name < workloads[j].Kind+"/"+workloads[j].Name
+		})
+	}
+	return images, nil
+}
+
+func podImages(pod *corev1.Pod) []string {
+	var images []string
+	for _, c := range pod.Spec.InitContainers {
+		images = append(images, c.Image)
+	}
+	for _, c := range pod.Spec.Containers {
+		images = append(images, c.Image)
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		images = append(images, c.Image)
+	}
+
+	out := images[:0]
+	for _, image := range images {
+		if image != "" {
+			out = append(out, image)
+		}
+	}
+	return out
+}
+
+// ownerResolver walks controller references up to the top level workload, e.g.
+// Pod -> ReplicaSet -> Deployment or Pod -> Job -> CronJob. Lookups are cached
+// for the duration of one discovery.
+type ownerResolver struct {
+	client kubernetes.Interface
+	cache  map[string]*metav1.OwnerReference
+}
+
+func newOwnerResolver(client kubernetes.Interface) *ownerResolver {
+	return &ownerResolver{client: client, cache: map[string]*metav1.OwnerReference{}}
+}
+
+func (o *ownerResolver) resolve(ctx context.Context, pod *corev1.Pod) (string, string) {
+	ref := metav1.GetControllerOf(pod)
+	if ref == nil {
+		return "Pod", pod.Name
+	}
+
+	switch ref.Kind {
+	case "ReplicaSet", "Job":
+		if parent := o.parent(ctx, pod.Namespace, ref); parent != nil {
+			return parent.Kind, parent.Name
+		}
+	}
+	return ref.Kind, ref.Name
+}
+
+// parent returns the controller of a ReplicaSet or Job, or nil when it has none
+// or cannot be read.
+func (o *ownerResolver) parent(ctx context.Context, namespace string, ref *metav1.OwnerReference) *metav1.OwnerReference {
+	key := ref.Kind + "/" + namespace + "/" + ref.Name
+	if parent, ok := o.cache[key]; ok {
+		return parent
+	}
+
+	var obj metav1.Object
+	var err error
+	switch ref.Kind {
+	case "ReplicaSet":
+		obj, err = o.client.AppsV1().ReplicaSets(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	case "Job":
+		obj, err = o.client.BatchV1().Jobs(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	}
+
+	var parent *metav1.OwnerReference
+	if err == nil && obj != nil {
+		parent = metav1.GetControllerOf(obj)
+	}
+	o.cache[key] = parent
+	return parent
+}
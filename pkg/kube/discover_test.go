@@ -0,0 +1,74 @@
+package kube
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/trivy-web-dash/types"
+)
+
+func controlledBy(kind, name string) []metav1.OwnerReference {
+	controller := true
+	return []metav1.OwnerReference{{Kind: kind, Name: name, Controller: &controller}}
+}
+
+func pod(namespace, name string, phase corev1.PodPhase, owners []metav1.OwnerReference, images ...string) *corev1.Pod {
+	p := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, OwnerReferences: owners},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+	for _, image := range images {
+		p.Spec.Containers = append(p.Spec.Containers, corev1.Container{Image: image})
+	}
+	return p
+}
+
+func testCluster() []runtime.Object {
+	web := pod("shop", "web-abc-1", corev1.PodRunning, controlledBy("ReplicaSet", "web-abc"), "nginx:1.25")
+	web.Spec.InitContainers = []corev1.Container{{Image: "busybox:1.36"}}
+	debug := pod("shop", "debug", corev1.PodRunning, nil, "alpine:3.19")
+	debug.Spec.EphemeralContainers = []corev1.EphemeralContainer{{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Image: "busybox:1.36"}}}
+
+	return []runtime.Object{
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web-abc", OwnerReferences: controlledBy("Deployment", "web")}},
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "orphan"}},
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "ops", Name: "backup-123", OwnerReferences: controlledBy("CronJob", "backup")}},
+		web,
+		pod("shop", "web-abc-2", corev1.PodRunning, controlledBy("ReplicaSet", "web-abc"), "nginx:1.25"),
+		pod("shop", "orphan-1", corev1.PodPending, controlledBy("ReplicaSet", "orphan"), "nginx:1.25"),
+		pod("shop", "db-0", corev1.PodRunning, controlledBy("StatefulSet", "db"), "postgres:16"),
+		pod("ops", "backup-123-x", corev1.PodRunning, controlledBy("Job", "backup-123"), "postgres:16"),
+		pod("ops", "migrate", corev1.PodSucceeded, controlledBy("Job", "migrate"), "migrate:1"),
+		pod("ops", "crashed", corev1.PodFailed, nil, "crash:1"),
+		debug,
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	d := NewDiscoverer(fake.NewSimpleClientset(testCluster()...), "prod")
+	images, err := d.Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := func(namespace, kind, name string) types.Workload {
+		return types.Workload{Cluster: "prod", Namespace: namespace, Kind: kind, Name: name}
+	}
+	want := map[string][]types.Workload{
+		"nginx:1.25":   {w("shop", "Deployment", "web"), w("shop", "ReplicaSet", "orphan")},
+		"busybox:1.36": {w("shop", "Deployment", "web"), w("shop", "Pod", "debug")},
+		"postgres:16":  {w("ops", "CronJob", "backup"), w("shop", "StatefulSet", "db")},
+		"alpine:3.19":  {w("shop", "Pod", "debug")},
+	}
+	if !reflect.DeepEqual(images, want) {
+		t.Errorf("Discover() =\n%v\nwant\n%v", images, want)
+	}
+}
@@ -0,0 +1,125 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/trivy-web-dash/inventory"
+	"github.com/trivy-web-dash/pkg/db"
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/pkg/queue"
+	"github.com/trivy-web-dash/report"
+)
+
+// Syncer periodically refreshes a cluster's inventory and queues scans for
+// images that are new to the cluster or have never been scanned in project,
+// unless a scan of the image is already queued.
+type Syncer struct {
+	discoverer *Discoverer
+	enqueuer   queue.Enqueuer
+	store      db.Store
+	project    string
+	log        logger.Logger
+
+	mu       sync.Mutex
+	interval time.Duration
+}
+
+func NewSyncer(d *Discoverer, e queue.Enqueuer, store db.Store, project string, interval time.Duration, l logger.Logger) *Syncer {
+	return &Syncer{discoverer: d, enqueuer: e, store: store, project: project, interval: interval, log: l}
+}
+
+// SetInterval changes the interval of a running syncer from its next sync on.
+func (s *Syncer) SetInterval(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interval = interval
+}
+
+func (s *Syncer) getInterval() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.interval
+}
+
+// Run syncs immediately and then every interval until ctx is cancelled.
+func (s *Syncer) Run(ctx context.Context) {
+	interval := s.getInterval()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Sync(ctx); err != nil {
+			s.log.Errorf("syncing kubernetes inventory of %s : %v", s.discoverer.Cluster(), err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if i := s.getInterval(); i != interval {
+			interval = i
+			ticker.Reset(interval)
+		}
+	}
+}
+
+func (s *Syncer) Sync(ctx context.Context) error {
+	cluster := s.discoverer.Cluster()
+	images, err := s.discoverer.Discover(ctx)
+	if err != nil {
+		return err
+	}
+
+	previous, err := inventory.GetInventoryClient().Get(ctx, cluster)
+	if err != nil && !errors.Is(err, inventory.ErrNotFound) {
+		return err
+	}
+
+	jobs, err := s.store.WithContext(ctx).GetAllJobStatus(s.project)
+	if err != nil {
+		return err
+	}
+	active := map[string]bool{}
+	for _, j := range jobs {
+		if j.Status.Active() {
+			active[j.Image] = true
+		}
+	}
+
+	queued := 0
+	for image := range images {
+		if active[image] {
+			continue
+		}
+		if _, known := previous.Images[image]; known && scanned(ctx, s.project, image) {
+			continue
+		}
+		if _, err := s.enqueuer.Enqueue(ctx, s.project, image, ""); err != nil {
+			// the remaining images are queued by a later sync
+			var quota *queue.QuotaError
+			if errors.As(err, &quota) {
+				s.log.Warnf("kubernetes inventory of %s : %v", cluster, err)
+				break
+			}
+			s.log.Errorf("unable to queue %s from cluster %s : %v", image, cluster, err)
+			continue
+		}
+		queued++
+	}
+
+	s.log.Infof("kubernetes inventory of %s : %d images running, %d scans queued", cluster, len(images), queued)
+	return inventory.GetInventoryClient().Set(ctx, inventory.Inventory{
+		Cluster:   cluster,
+		Images:    images,
+		UpdatedAt: time.Now().UTC(),
+	})
+}
+
+func scanned(ctx context.Context, project, image string) bool {
+	_, _, err := report.GetReportClient().Get(ctx, project, image)
+	return err == nil
+}
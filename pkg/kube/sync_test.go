@@ -0,0 +1,160 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/trivy-web-dash/inventory"
+	"github.com/trivy-web-dash/pkg/db"
+	redisx "github.com/trivy-web-dash/pkg/db/redis"
+	"github.com/trivy-web-dash/pkg/job"
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/pkg/queue"
+	"github.com/trivy-web-dash/report"
+	"github.com/trivy-web-dash/types"
+)
+
+// fakeEnqueuer records scan jobs in the store like the real one, without
+// queueing them. A non-zero limit fails enqueues beyond it like a quota.
+type fakeEnqueuer struct {
+	queue.Enqueuer
+	store  db.Store
+	images []string
+	limit  int
+}
+
+func (e *fakeEnqueuer) Enqueue(ctx context.Context, project, image, engine string) (job.ScanJob, error) {
+	if e.limit > 0 && len(e.images) >= e.limit {
+		return job.ScanJob{}, &queue.QuotaError{Project: project, Active: len(e.images), Max: e.limit}
+	}
+	e.images = append(e.images, image)
+	j := job.ScanJob{ID: fmt.Sprint(len(e.images)), Project: project, Image: image, Status: job.Queued}
+	return j, e.store.Create(j)
+}
+
+func newSyncClients(t *testing.T) (db.Store, logger.Logger) {
+	t.Helper()
+	redis := miniredis.RunT(t)
+	log := logger.NewAppLogger("fatal")
+	log.InitLogger()
+	if err := inventory.NewInventoryClient(redis.Addr(), "", 7, false, false, log); err != nil {
+		t.Fatal(err)
+	}
+	if err := report.NewReportClient(redis.Addr(), "", 1, false, false, time.Hour, log); err != nil {
+		t.Fatal(err)
+	}
+	pool, err := redisx.NewPool(redis.Addr(), "", 5, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return redisx.NewStore(pool), log
+}
+
+func TestSync(t *testing.T) {
+	store, log := newSyncClients(t)
+	ctx := context.Background()
+
+	// known images were running at the previous sync
+	err := inventory.GetInventoryClient().Set(ctx, inventory.Inventory{Cluster: "prod", Images: map[string][]types.Workload{
+		"scanned:1":   nil,
+		"unscanned:1": nil,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := report.GetReportClient().Set(ctx, types.Report{ArtifactName: "scanned:1", Project: "payments"}); err != nil {
+		t.Fatal(err)
+	}
+	for i, j := range []job.ScanJob{
+		{Image: "queued:1", Status: job.Queued},
+		{Image: "pending:1", Status: job.Pending},
+		{Image: "done:1", Status: job.Done},
+		{Image: "failed:1", Status: job.ScanFail},
+		{Image: "other-project:1", Status: job.Queued},
+	} {
+		j.ID, j.Project = fmt.Sprint("seed-", i), "payments"
+		if j.Image == "other-project:1" {
+			j.Project = ""
+		}
+		if err := store.Create(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var pods []runtime.Object
+	for _, image := range []string{"new:1", "scanned:1", "unscanned:1", "queued:1", "pending:1", "done:1", "failed:1", "other-project:1"} {
+		name := strings.NewReplacer(":", "-").Replace(image)
+		pods = append(pods, pod("apps", name, corev1.PodRunning, nil, image))
+	}
+
+	e := &fakeEnqueuer{store: store}
+	s := NewSyncer(NewDiscoverer(fake.NewSimpleClientset(pods...), "prod"), e, store, "payments", time.Minute, log)
+	if err := s.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(e.images)
+	want := []string{"done:1", "failed:1", "new:1", "other-project:1", "unscanned:1"}
+	if strings.Join(e.images, " ") != strings.Join(want, " ") {
+		t.Errorf("enqueued %q, want %q", e.images, want)
+	}
+
+	inv, err := inventory.GetInventoryClient().Get(ctx, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inv.Images) != len(pods) {
+		t.Errorf("inventory has %d images, want %d", len(inv.Images), len(pods))
+	}
+
+	// the scans queued by the first sync are not queued again
+	e.images = nil
+	if err := s.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.images) != 0 {
+		t.Errorf("second sync enqueued %q", e.images)
+	}
+}
+
+func TestSyncQuota(t *testing.T) {
+	store, log := newSyncClients(t)
+	ctx := context.Background()
+
+	var pods []runtime.Object
+	for _, name := range []string{"a", "b", "c"} {
+		pods = append(pods, pod("apps", name, corev1.PodRunning, nil, name+":1"))
+	}
+	e := &fakeEnqueuer{store: store, limit: 2}
+	s := NewSyncer(NewDiscoverer(fake.NewSimpleClientset(pods...), "prod"), e, store, "payments", time.Minute, log)
+	if err := s.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.images) != 2 {
+		t.Fatalf("enqueued %q with a quota of 2", e.images)
+	}
+
+	// once the queued scans are done the rest is queued by the next sync
+	jobs, _ := store.GetAllJobStatus("payments")
+	for _, j := range jobs {
+		if err := store.UpdateStatus("payments", j.ID, job.Done); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e.limit = 3
+	if err := s.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.images) != 3 {
+		t.Errorf("enqueued %q, want every image once the quota allows it", e.images)
+	}
+}
@@ -1,7 +1,9 @@
 package logger
 
 import (
+	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
@@ -27,6 +29,7 @@ type Logger interface {
 
 type appLogger struct {
 	level       string
+	atomicLevel zap.AtomicLevel
 	sugarLogger *zap.SugaredLogger
 }
 
@@ -44,8 +47,26 @@ var loggerLevelMap = map[string]zapcore.Level{
 	"fatal":  zapcore.FatalLevel,
 }
 
+// ValidLevel reports whether level is one of the names accepted by NewAppLogger.
+func ValidLevel(level string) bool {
+	_, ok := loggerLevelMap[strings.ToLower(level)]
+	return ok
+}
+
+// SetLevel changes the level of an initialized logger, the encoding chosen
+// for the initial level is kept.
+func (l *appLogger) SetLevel(level string) error {
+	zl, ok := loggerLevelMap[strings.ToLower(level)]
+	if !ok {
+		return fmt.Errorf("unknown log level %q", level)
+	}
+	l.level = strings.ToLower(level)
+	l.atomicLevel.SetLevel(zl)
+	return nil
+}
+
 func (l *appLogger) getLoggerLevel() zapcore.Level {
-	level, exist := loggerLevelMap[l.level]
+	level, exist := loggerLevelMap[strings.ToLower(l.level)]
 	if !exist {
 		return zapcore.DebugLevel
 	}
@@ -72,7 +93,8 @@ func (l *appLogger) InitLogger() {
 
 	encoderCfg.EncodeTime = zapcore.TimeEncoderOfLayout(time.RFC3339)
 	encoder = zapcore.NewConsoleEncoder(encoderCfg)
-	core := zapcore.NewCore(encoder, logWriter, zap.NewAtomicLevelAt(logLevel))
+	l.atomicLevel = zap.NewAtomicLevelAt(logLevel)
+	core := zapcore.NewCore(encoder, logWriter, l.atomicLevel)
 	logger := zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1))
 	l.sugarLogger = logger.Sugar()
 	defer l.sugarLogger.Sync()
@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"sort"
+
+	"github.com/gocraft/work"
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/trivy-web-dash/pkg/db"
+	"github.com/trivy-web-dash/types"
+)
+
+// OtherImages labels the summed counts of images beyond the per-image limit.
+const OtherImages = "_other"
+
+var severities = []string{"CRITICAL", "HIGH", "MEDIUM", "LOW"}
+
+type queueCollector struct {
+	client  *work.Client
+	depth   *prometheus.Desc
+	latency *prometheus.Desc
+}
+
+// NewQueueCollector reports the backlog of every job queue in the gocraft
+// namespace jobNamespace.
+func NewQueueCollector(jobNamespace string, pool *redis.Pool) prometheus.Collector {
+	return &queueCollector{
+		client: work.NewClient(jobNamespace, pool),
+		depth: prometheus.NewDesc(prometheus.BuildFQName(namespace, "queue", "depth"),
+			"Jobs waiting in the queue.", []string{"job"}, nil),
+		latency: prometheus.NewDesc(prometheus.BuildFQName(namespace, "queue", "latency_seconds"),
+			"Age of the oldest job waiting in the queue.", []string{"job"}, nil),
+	}
+}
+
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.depth
+	ch <- c.latency
+}
+
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	queues, err := c.client.Queues()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.depth, err)
+		return
+	}
+	for _, q := range queues {
+		ch <- prometheus.MustNewConstMetric(c.depth, prometheus.GaugeValue, float64(q.Count), q.JobName)
+		ch <- prometheus.MustNewConstMetric(c.latency, prometheus.GaugeValue, float64(q.Latency), q.JobName)
+	}
+}
+
+type jobCollector struct {
+	store    db.Store
+	projects []string
+	jobs     *prometheus.Desc
+}
+
+// NewJobCollector reports the scan jobs of projects by status. Jobs expire an
+// hour after their last update, so this covers recent jobs only.
+func NewJobCollector(store db.Store, projects []string) prometheus.Collector {
+	return &jobCollector{
+		store:    store,
+		projects: projects,
+		jobs: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "scan_jobs"),
+			"Scan jobs by status.", []string{"project", "status"}, nil),
+	}
+}
+
+func (c *jobCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.jobs
+}
+
+func (c *jobCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, project := range c.projects {
+		jobs, err := c.store.GetAllJobStatus(project)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(c.jobs, err)
+			return
+		}
+
+		counts := map[string]int{}
+		for _, j := range jobs {
+			counts[j.Status.String()]++
+		}
+		for status, n := range counts {
+			ch <- prometheus.MustNewConstMetric(c.jobs, prometheus.GaugeValue, float64(n), project, status)
+		}
+	}
+}
+
+type vulnerabilityCollector struct {
+	summaries func(project string) ([]types.Summary, error)
+	projects  []string
+	maxImages int
+	total     *prometheus.Desc
+	perImage  *prometheus.Desc
+}
+
+// NewVulnerabilityCollector reports the findings of the latest reports per
+// severity. Per image, only the maxImages images with the most severe findings
+// of each project get their own series, the others are summed under the image
+// OtherImages. A maxImages of 0 turns per-image series off.
+func NewVulnerabilityCollector(summaries func(project string) ([]types.Summary, error), projects []string, maxImages int) prometheus.Collector {
+	return &vulnerabilityCollector{
+		summaries: summaries,
+		projects:  projects,
+		maxImages: maxImages,
+		total: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "vulnerabilities"),
+			"Vulnerabilities in the latest reports.", []string{"project", "severity"}, nil),
+		perImage: prometheus.NewDesc(prometheus.BuildFQName(namespace, "image", "vulnerabilities"),
+			"Vulnerabilities in the latest report of an image.", []string{"project", "image", "severity"}, nil),
+	}
+}
+
+func (c *vulnerabilityCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.total
+	ch <- c.perImage
+}
+
+func (c *vulnerabilityCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, project := range c.projects {
+		summaries, err := c.summaries(project)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(c.total, err)
+			return
+		}
+
+		total := map[string]int{}
+		for _, s := range summaries {
+			for _, severity := range severities {
+				total[severity] += s.VSummary[severity]
+			}
+		}
+		for _, severity := range severities {
+			ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(total[severity]), project, severity)
+		}
+
+		if c.maxImages == 0 {
+			continue
+		}
+		sort.Slice(summaries, func(i, j int) bool { return moreSevere(summaries[i], summaries[j]) })
+		other := map[string]int{}
+		for i, s := range summaries {
+			for _, severity := range severities {
+				if i >= c.maxImages {
+					other[severity] += s.VSummary[severity]
+					continue
+				}
+				ch <- prometheus.MustNewConstMetric(c.perImage, prometheus.GaugeValue, float64(s.VSummary[severity]), project, s.Image, severity)
+			}
+		}
+		if len(summaries) > c.maxImages {
+			for _, severity := range severities {
+				ch <- prometheus.MustNewConstMetric(c.perImage, prometheus.GaugeValue, float64(other[severity]), project, OtherImages, severity)
+			}
+		}
+	}
+}
+
+// moreSevere orders summaries by their counts from CRITICAL down, then by image.
+func moreSevere(a, b types.Summary) bool {
+	for _, severity := range severities {
+		if a.VSummary[severity] != b.VSummary[severity] {
+			return a.VSummary[severity] > b.VSummary[severity]
+		}
+	}
+	return a.Image < b.Image
+}
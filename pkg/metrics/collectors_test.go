@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gocraft/work"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	redisx "github.com/trivy-web-dash/pkg/db/redis"
+	"github.com/trivy-web-dash/pkg/job"
+	"github.com/trivy-web-dash/types"
+)
+
+func TestQueueCollector(t *testing.T) {
+	redis := miniredis.RunT(t)
+	pool, err := redisx.NewPool(redis.Addr(), "", 5, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enqueuer := work.NewEnqueuer("trivy", pool)
+	for _, name := range []string{"scan_artifact", "scan_artifact", "scan_sbom"} {
+		if _, err := enqueuer.Enqueue(name, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := `
+# HELP trivy_web_dash_queue_depth Jobs waiting in the queue.
+# TYPE trivy_web_dash_queue_depth gauge
+trivy_web_dash_queue_depth{job="scan_artifact"} 2
+trivy_web_dash_queue_depth{job="scan_sbom"} 1
+`
+	if err := testutil.CollectAndCompare(NewQueueCollector("trivy", pool), strings.NewReader(want), "trivy_web_dash_queue_depth"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestJobCollector(t *testing.T) {
+	redis := miniredis.RunT(t)
+	pool, err := redisx.NewPool(redis.Addr(), "", 5, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := redisx.NewStore(pool)
+	for i, j := range []job.ScanJob{
+		{Project: "payments", Status: job.Queued},
+		{Project: "payments", Status: job.Done},
+		{Project: "payments", Status: job.Done},
+		{Project: "search", Status: job.ScanFail},
+	} {
+		j.ID = string(rune('a' + i))
+		if err := store.Create(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := `
+# HELP trivy_web_dash_scan_jobs Scan jobs by status.
+# TYPE trivy_web_dash_scan_jobs gauge
+trivy_web_dash_scan_jobs{project="payments",status="Done"} 2
+trivy_web_dash_scan_jobs{project="payments",status="Queued"} 1
+trivy_web_dash_scan_jobs{project="search",status="ScanFail"} 1
+`
+	if err := testutil.CollectAndCompare(NewJobCollector(store, []string{"payments", "search", "idle"}), strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVulnerabilityCollector(t *testing.T) {
+	summaries := func(project string) ([]types.Summary, error) {
+		if project == "broken" {
+			return nil, errors.New("connection refused")
+		}
+		return []types.Summary{
+			{Image: "nginx:1.25", VSummary: map[string]int{"HIGH": 4, "LOW": 1}},
+			{Image: "alpine:3.19", VSummary: map[string]int{"CRITICAL": 1}},
+			{Image: "redis:7", VSummary: map[string]int{"HIGH": 2, "MEDIUM": 3}},
+			{Image: "busybox:1.36", VSummary: map[string]int{"LOW": 2}},
+		}, nil
+	}
+
+	want := `
+# HELP trivy_web_dash_image_vulnerabilities Vulnerabilities in the latest report of an image.
+# TYPE trivy_web_dash_image_vulnerabilities gauge
+trivy_web_dash_image_vulnerabilities{image="_other",project="payments",severity="CRITICAL"} 0
+trivy_web_dash_image_vulnerabilities{image="_other",project="payments",severity="HIGH"} 2
+trivy_web_dash_image_vulnerabilities{image="_other",project="payments",severity="LOW"} 2
+trivy_web_dash_image_vulnerabilities{image="_other",project="payments",severity="MEDIUM"} 3
+trivy_web_dash_image_vulnerabilities{image="alpine:3.19",project="payments",severity="CRITICAL"} 1
+trivy_web_dash_image_vulnerabilities{image="alpine:3.19",project="payments",severity="HIGH"} 0
+trivy_web_dash_image_vulnerabilities{image="alpine:3.19",project="payments",severity="LOW"} 0
+trivy_web_dash_image_vulnerabilities{image="alpine:3.19",project="payments",severity="MEDIUM"} 0
+trivy_web_dash_image_vulnerabilities{image="nginx:1.25",project="payments",severity="CRITICAL"} 0
+trivy_web_dash_image_vulnerabilities{image="nginx:1.25",project="payments",severity="HIGH"} 4
+trivy_web_dash_image_vulnerabilities{image="nginx:1.25",project="payments",severity="LOW"} 1
+trivy_web_dash_image_vulnerabilities{image="nginx:1.25",project="payments",severity="MEDIUM"} 0
+# HELP trivy_web_dash_vulnerabilities Vulnerabilities in the latest reports.
+# TYPE trivy_web_dash_vulnerabilities gauge
+trivy_web_dash_vulnerabilities{project="payments",severity="CRITICAL"} 1
+trivy_web_dash_vulnerabilities{project="payments",severity="HIGH"} 6
+trivy_web_dash_vulnerabilities{project="payments",severity="LOW"} 3
+trivy_web_dash_vulnerabilities{project="payments",severity="MEDIUM"} 3
+`
+	if err := testutil.CollectAndCompare(NewVulnerabilityCollector(summaries, []string{"payments"}, 2), strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+
+	if n := testutil.CollectAndCount(NewVulnerabilityCollector(summaries, []string{"payments"}, 0)); n != 4 {
+		t.Errorf("without per-image series: %d series", n)
+	}
+	if _, err := testutil.CollectAndLint(NewVulnerabilityCollector(summaries, []string{"broken"}, 2)); err == nil {
+		t.Error("collecting from a failing store succeeded")
+	}
+}
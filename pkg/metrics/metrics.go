@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "trivy_web_dash"
+
+var (
+	// ScanDuration is the wall time of scanner runs by subcommand ("image",
+	// "sbom", "grype image", "grype sbom").
+	ScanDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scan_duration_seconds",
+		Help:      "Duration of trivy scans.",
+		Buckets:   []float64{5, 15, 30, 60, 120, 300, 600, 1200, 1800},
+	}, []string{"subcommand"})
+
+	// TrivyExitCodes counts trivy runs by subcommand and exit code. A code of
+	// -1 means trivy could not be started.
+	TrivyExitCodes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "trivy_exit_codes_total",
+		Help:      "Trivy runs by exit code.",
+	}, []string{"subcommand", "code"})
+
+	// WebhookDeliveries counts report deliveries to project webhooks by
+	// outcome ("success", "failure").
+	WebhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Report deliveries to project webhooks.",
+	}, []string{"outcome"})
+)
+
+var registry = prometheus.NewRegistry()
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		ScanDuration,
+		TrivyExitCodes,
+		WebhookDeliveries,
+	)
+}
+
+// Register adds collectors gathered on every scrape, such as the ones
+// reading queue and report state from redis.
+func Register(cs ...prometheus.Collector) error {
+	for _, c := range cs {
+		if err := registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves the registered metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	jobs := NewJobCollector(nil, nil)
+	if err := Register(jobs); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { registry.Unregister(jobs) })
+	if err := Register(NewJobCollector(nil, nil)); err == nil {
+		t.Error("registering a collector twice succeeded")
+	}
+
+	TrivyExitCodes.WithLabelValues("image", "1").Inc()
+	ScanDuration.WithLabelValues("sbom").Observe(42)
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range []string{
+		`trivy_web_dash_trivy_exit_codes_total{code="1",subcommand="image"} 1`,
+		`trivy_web_dash_scan_duration_seconds_bucket{subcommand="sbom",le="60"} 1`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(rec.Body.String(), line) {
+			t.Errorf("/metrics misses %s", line)
+		}
+	}
+}
@@ -12,6 +12,8 @@ var (
 type File interface {
 	Name() string
 	Read([]byte) (int, error)
+	Write([]byte) (int, error)
+	Close() error
 }
 
 type Mgr interface {
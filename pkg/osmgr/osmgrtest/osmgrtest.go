@@ -0,0 +1,243 @@
+// Package osmgrtest provides an osmgr.Mgr that runs no processes, for testing
+// the scanners and everything built on them without trivy or grype installed.
+package osmgrtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trivy-web-dash/pkg/osmgr"
+)
+
+// Run is the scripted outcome of one scanner command.
+type Run struct {
+	// Report is written as JSON to the command's output file, unless it is a
+	// string or []byte, which are written as they are.
+	Report interface{}
+	// Stdout is the combined output the command prints.
+	Stdout string
+	// Err fails the command, as a non-zero exit would.
+	Err error
+	// Delay is how long the command takes.
+	Delay time.Duration
+}
+
+// Mgr answers commands with the Run scripted for their binary and target.
+// Temporary files live in memory. It is safe for concurrent use.
+type Mgr struct {
+	mu       sync.Mutex
+	runs     map[string]Run
+	files    map[string]*File
+	commands [][]string
+	envs     [][]string
+	next     int
+
+	// Version is what `trivy --version` and `grype version` print.
+	Version string
+	// Env is returned by Environ.
+	Env []string
+}
+
+var _ osmgr.Mgr = (*Mgr)(nil)
+
+func New() *Mgr {
+	return &Mgr{
+		runs:  map[string]Run{},
+		files: map[string]*File{},
+	}
+}
+
+// On scripts the commands of binary, "trivy" or "grype", that target the
+// image ref or file target. An empty target matches every other command of
+// binary, such as scans of temporary SBOM files.
+func (m *Mgr) On(binary, target string, r Run) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runs[binary+"|"+target] = r
+}
+
+// Commands returns the arguments of every command run so far, binary first.
+func (m *Mgr) Commands() [][]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([][]string(nil), m.commands...)
+}
+
+// Environments returns the environment of every command run so far, in the
+// order of Commands.
+func (m *Mgr) Environments() [][]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([][]string(nil), m.envs...)
+}
+
+// Files returns the names of temporary files that were not removed.
+func (m *Mgr) Files() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	for name := range m.files {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (m *Mgr) Environ() []string {
+	return m.Env
+}
+
+// LookPath finds every binary in /usr/local/bin.
+func (m *Mgr) LookPath(file string) (string, error) {
+	return filepath.Join("/usr/local/bin", file), nil
+}
+
+func (m *Mgr) TempFile(dir, pattern string) (osmgr.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.next++
+	name := filepath.Join(dir, strings.Replace(pattern, "*", strconv.Itoa(m.next), 1))
+	f := &File{name: name}
+	m.files[name] = f
+	return f, nil
+}
+
+func (m *Mgr) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[name]; !ok {
+		return fmt.Errorf("remove %s: no such file", name)
+	}
+	delete(m.files, name)
+	return nil
+}
+
+// RunCmd plays the Run scripted for cmd. Commands without one fail.
+func (m *Mgr) RunCmd(cmd *exec.Cmd) ([]byte, error) {
+	args := cmd.Args
+	binary := filepath.Base(args[0])
+
+	m.mu.Lock()
+	m.commands = append(m.commands, append([]string(nil), args...))
+	m.envs = append(m.envs, append([]string(nil), cmd.Env...))
+	version := m.Version
+	m.mu.Unlock()
+
+	if flagValue(args, "--version") != "" || (len(args) > 1 && args[1] == "version") {
+		return []byte(version), nil
+	}
+
+	r, ok := m.lookup(binary, args)
+	if !ok {
+		return []byte("no scripted run"), fmt.Errorf("%s: no run scripted for %q", binary, args)
+	}
+	time.Sleep(r.Delay)
+	if r.Err != nil {
+		return []byte(r.Stdout), r.Err
+	}
+
+	if r.Report != nil {
+		if err := m.writeReport(args, r.Report); err != nil {
+			return nil, err
+		}
+	}
+	return []byte(r.Stdout), nil
+}
+
+func (m *Mgr) lookup(binary string, args []string) (Run, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, arg := range args[1:] {
+		if r, ok := m.runs[binary+"|"+arg]; ok {
+			return r, true
+		}
+	}
+	r, ok := m.runs[binary+"|"]
+	return r, ok
+}
+
+// writeReport writes the report to the file given by --file (grype) or
+// --output (trivy).
+func (m *Mgr) writeReport(args []string, report interface{}) error {
+	name := flagValue(args, "--file")
+	if name == "" {
+		name = flagValue(args, "--output")
+	}
+
+	m.mu.Lock()
+	f, ok := m.files[name]
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("output file %q was not created with TempFile", name)
+	}
+
+	var b []byte
+	switch r := report.(type) {
+	case string:
+		b = []byte(r)
+	case []byte:
+		b = r
+	default:
+		var err error
+		if b, err = json.Marshal(r); err != nil {
+			return err
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.buf.Write(b)
+	return nil
+}
+
+func flagValue(args []string, name string) string {
+	for i, arg := range args {
+		if arg == name {
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+			return name
+		}
+	}
+	return ""
+}
+
+// File is an in-memory temporary file. Reads return what was written.
+type File struct {
+	name   string
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (f *File) Name() string {
+	return f.name
+}
+
+func (f *File) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.buf.Read(p)
+}
+
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, errors.New("write to closed file " + f.name)
+	}
+	return f.buf.Write(p)
+}
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
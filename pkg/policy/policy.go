@@ -0,0 +1,246 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trivy-web-dash/types"
+)
+
+const DefaultPolicy = "default"
+
+// Policy is a named set of thresholds a report must stay within. Every rule is
+// optional; a zero Policy passes every report.
+type Policy struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// MaxCounts caps the number of findings per severity, e.g. {"CRITICAL": 0}.
+	MaxCounts map[string]int `json:"maxCounts,omitempty"`
+	// DenyFixable lists severities that may not be present once a fix exists.
+	DenyFixable []string `json:"denyFixable,omitempty"`
+	// MaxAgeDays limits how many days after publication a finding of a given
+	// severity may remain unresolved.
+	MaxAgeDays map[string]int `json:"maxAgeDays,omitempty"`
+}
+
+type Finding struct {
+	Target           string     `json:"target"`
+	VulnerabilityID  string     `json:"vulnerabilityID"`
+	PkgName          string     `json:"pkgName"`
+	InstalledVersion string     `json:"installedVersion"`
+	FixedVersion     string     `json:"fixedVersion,omitempty"`
+	Severity         string     `json:"severity"`
+	PublishedDate    *time.Time `json:"publishedDate,omitempty"`
+}
+
+type Violation struct {
+	Rule     string    `json:"rule"`
+	Message  string    `json:"message"`
+	Findings []Finding `json:"findings"`
+}
+
+type Verdict struct {
+	Policy      string      `json:"policy"`
+	Pass        bool        `json:"pass"`
+	Violations  []Violation `json:"violations"`
+	EvaluatedAt time.Time   `json:"evaluatedAt"`
+}
+
+// Evaluate checks the unsuppressed findings of r against the policy.
+func (p Policy) Evaluate(r types.Report, now time.Time) Verdict {
+	bySeverity := map[string][]Finding{}
+	for _, res := range r.Results {
+		for _, v := range res.Vulnerabilities {
+			if v.Suppressed() {
+				continue
+			}
+			bySeverity[v.Severity] = append(bySeverity[v.Severity], Finding{
+				Target:           res.Target,
+				VulnerabilityID:  v.VulnerabilityID,
+				PkgName:          v.PkgName,
+				InstalledVersion: v.InstalledVersion,
+				FixedVersion:     v.FixedVersion,
+				Severity:         v.Severity,
+				PublishedDate:    v.PublishedDate,
+			})
+		}
+	}
+
+	verdict := Verdict{Policy: p.Name, Violations: []Violation{}, EvaluatedAt: now.UTC()}
+
+	for _, severity := range sortedKeys(p.MaxCounts) {
+		max := p.MaxCounts[severity]
+		if found := bySeverity[severity]; len(found) > max {
+			verdict.Violations = append(verdict.Violations, Violation{
+				Rule:     "maxCounts",
+				Message:  fmt.Sprintf("%d %s findings, at most %d allowed", len(found), severity, max),
+				Findings: found,
+			})
+		}
+	}
+
+	for _, severity := range p.DenyFixable {
+		var fixable []Finding
+		for _, f := range bySeverity[severity] {
+			if f.FixedVersion != "" {
+				fixable = append(fixable, f)
+			}
+		}
+		if len(fixable) > 0 {
+			verdict.Violations = append(verdict.Violations, Violation{
+				Rule:     "denyFixable",
+				Message:  fmt.Sprintf("%d %s findings have a fix available", len(fixable), severity),
+				Findings: fixable,
+			})
+		}
+	}
+
+	for _, severity := range sortedKeys(p.MaxAgeDays) {
+		days := p.MaxAgeDays[severity]
+		cutoff := now.Add(-time.Duration(days) * 24 * time.Hour)
+		var old []Finding
+		for _, f := range bySeverity[severity] {
+			if f.PublishedDate != nil && f.PublishedDate.Before(cutoff) {
+				old = append(old, f)
+			}
+		}
+		if len(old) > 0 {
+			verdict.Violations = append(verdict.Violations, Violation{
+				Rule:     "maxAgeDays",
+				Message:  fmt.Sprintf("%d %s findings were published more than %d days ago", len(old), severity, days),
+				Findings: old,
+			})
+		}
+	}
+
+	verdict.Pass = len(verdict.Violations) == 0
+	return verdict
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return types.SeverityRank(keys[i]) > types.SeverityRank(keys[j])
+	})
+	return keys
+}
+
+var builtin = []Policy{
+	{
+		Name:        DefaultPolicy,
+		Description: "no CRITICAL with fix available",
+		DenyFixable: []string{"CRITICAL"},
+	},
+	{
+		Name:        "no-fixable-high",
+		Description: "no CRITICAL or HIGH with fix available",
+		DenyFixable: []string{"CRITICAL", "HIGH"},
+	},
+	{
+		Name:        "no-old-high",
+		Description: "no CRITICAL or HIGH older than 30 days",
+		MaxAgeDays:  map[string]int{"CRITICAL": 30, "HIGH": 30},
+	},
+	{
+		Name:        "strict",
+		Description: "no CRITICAL or HIGH at all",
+		MaxCounts:   map[string]int{"CRITICAL": 0, "HIGH": 0},
+	},
+}
+
+// Set is the collection of policies verdicts can be requested for.
+type Set struct {
+	mu       sync.RWMutex
+	policies map[string]Policy
+}
+
+// NewSet returns the built-in policies overridden and extended by policies.
+func NewSet(policies ...Policy) *Set {
+	s := &Set{policies: map[string]Policy{}}
+	for _, p := range builtin {
+		s.policies[p.Name] = p
+	}
+	for _, p := range policies {
+		s.policies[p.Name] = normalize(p)
+	}
+	return s
+}
+
+// normalize upper-cases severities so policy files may use any case.
+func normalize(p Policy) Policy {
+	upper := func(m map[string]int) map[string]int {
+		if m == nil {
+			return nil
+		}
+		out := make(map[string]int, len(m))
+		for k, v := range m {
+			out[strings.ToUpper(k)] = v
+		}
+		return out
+	}
+	p.MaxCounts = upper(p.MaxCounts)
+	p.MaxAgeDays = upper(p.MaxAgeDays)
+	fixable := make([]string, 0, len(p.DenyFixable))
+	for _, severity := range p.DenyFixable {
+		fixable = append(fixable, strings.ToUpper(severity))
+	}
+	p.DenyFixable = fixable
+	return p
+}
+
+// Load reads a JSON array of policies from path on top of the built-in ones.
+func Load(path string) (*Set, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var policies []Policy
+	if err := json.Unmarshal(b, &policies); err != nil {
+		return nil, fmt.Errorf("parsing policy file %s: %w", path, err)
+	}
+	for _, p := range policies {
+		if p.Name == "" {
+			return nil, fmt.Errorf("policy file %s: policy without name", path)
+		}
+	}
+	return NewSet(policies...), nil
+}
+
+func (s *Set) Get(name string) (Policy, bool) {
+	if name == "" {
+		name = DefaultPolicy
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.policies[name]
+	return p, ok
+}
+
+func (s *Set) List() []Policy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	policies := make([]Policy, 0, len(s.policies))
+	for _, p := range s.policies {
+		policies = append(policies, p)
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Name < policies[j].Name })
+	return policies
+}
+
+// Replace swaps in the policies of other, as after reloading the policy file.
+func (s *Set) Replace(other *Set) {
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policies = other.policies
+}
@@ -0,0 +1,163 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trivy-web-dash/types"
+)
+
+var now = time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func daysAgo(n int) *time.Time {
+	t := now.Add(-time.Duration(n) * 24 * time.Hour)
+	return &t
+}
+
+func testReport() types.Report {
+	return types.Report{Results: []types.Result{{
+		Target: "app:1.0 (alpine 3.19.1)",
+		Vulnerabilities: []types.Vulnerability{
+			{VulnerabilityID: "CVE-1", PkgName: "openssl", Severity: "CRITICAL", FixedVersion: "3.1.5", PublishedDate: daysAgo(40)},
+			{VulnerabilityID: "CVE-2", PkgName: "busybox", Severity: "HIGH", PublishedDate: daysAgo(10)},
+			{VulnerabilityID: "CVE-3", PkgName: "zlib", Severity: "HIGH", FixedVersion: "1.3.1", PublishedDate: daysAgo(60)},
+			{VulnerabilityID: "CVE-4", PkgName: "curl", Severity: "CRITICAL", FixedVersion: "8.5.0",
+				Suppression: &types.Suppression{Source: "exception", RuleID: "r1"}},
+			{VulnerabilityID: "CVE-5", PkgName: "musl", Severity: "MEDIUM"},
+		},
+	}}}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		rules  []string
+		counts []int
+	}{
+		{"zero policy", Policy{}, nil, nil},
+		{"max counts", Policy{MaxCounts: map[string]int{"CRITICAL": 0, "HIGH": 2, "MEDIUM": 0}}, []string{"maxCounts", "maxCounts"}, []int{1, 1}},
+		{"deny fixable", Policy{DenyFixable: []string{"CRITICAL", "HIGH"}}, []string{"denyFixable", "denyFixable"}, []int{1, 1}},
+		{"max age", Policy{MaxAgeDays: map[string]int{"HIGH": 30, "CRITICAL": 30}}, []string{"maxAgeDays", "maxAgeDays"}, []int{1, 1}},
+		{"max age not reached", Policy{MaxAgeDays: map[string]int{"CRITICAL": 45}}, nil, nil},
+		{"every rule", Policy{MaxCounts: map[string]int{"LOW": 0}, DenyFixable: []string{"MEDIUM"}, MaxAgeDays: map[string]int{"HIGH": 50}}, []string{"maxAgeDays"}, []int{1}},
+	}
+	for _, tt := range tests {
+		tt.policy.Name = tt.name
+		v := tt.policy.Evaluate(testReport(), now)
+		if v.Policy != tt.name || !v.EvaluatedAt.Equal(now) {
+			t.Errorf("%s: verdict header = %s %v", tt.name, v.Policy, v.EvaluatedAt)
+		}
+		if v.Pass != (len(tt.rules) == 0) || len(v.Violations) != len(tt.rules) {
+			t.Errorf("%s: pass %v with %d violations, want %d", tt.name, v.Pass, len(v.Violations), len(tt.rules))
+			continue
+		}
+		for i, violation := range v.Violations {
+			if violation.Rule != tt.rules[i] || len(violation.Findings) != tt.counts[i] {
+				t.Errorf("%s: violation %d = %s with %d findings, want %s with %d", tt.name, i, violation.Rule, len(violation.Findings), tt.rules[i], tt.counts[i])
+			}
+			for _, f := range violation.Findings {
+				if f.VulnerabilityID == "CVE-4" {
+					t.Errorf("%s: suppressed finding counted", tt.name)
+				}
+			}
+		}
+	}
+
+	// violations come most severe first
+	v := Policy{MaxCounts: map[string]int{"MEDIUM": 0, "CRITICAL": 0, "HIGH": 0}}.Evaluate(testReport(), now)
+	var messages []string
+	for _, violation := range v.Violations {
+		messages = append(messages, violation.Message)
+	}
+	want := []string{
+		"1 CRITICAL findings, at most 0 allowed",
+		"2 HIGH findings, at most 0 allowed",
+		"1 MEDIUM findings, at most 0 allowed",
+	}
+	if strings.Join(messages, "|") != strings.Join(want, "|") {
+		t.Errorf("messages = %q, want %q", messages, want)
+	}
+}
+
+func TestBuiltin(t *testing.T) {
+	s := NewSet()
+	tests := []struct {
+		name string
+		pass bool
+	}{
+		{"", false},
+		{DefaultPolicy, false},
+		{"no-fixable-high", false},
+		{"no-old-high", false},
+		{"strict", false},
+	}
+	for _, tt := range tests {
+		p, ok := s.Get(tt.name)
+		if !ok {
+			t.Fatalf("built-in policy %q missing", tt.name)
+		}
+		if got := p.Evaluate(testReport(), now).Pass; got != tt.pass {
+			t.Errorf("%q: pass = %v, want %v", tt.name, got, tt.pass)
+		}
+		if got := p.Evaluate(types.Report{}, now).Pass; !got {
+			t.Errorf("%q fails an empty report", tt.name)
+		}
+	}
+	if _, ok := s.Get("missing"); ok {
+		t.Error("unknown policy found")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	s, err := Load(write("ok.json", `[
+		{"name": "default", "maxCounts": {"critical": 5}},
+		{"name": "team", "denyFixable": ["high"], "maxAgeDays": {"medium": 90}}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	def, _ := s.Get("")
+	if def.MaxCounts["CRITICAL"] != 5 || len(def.DenyFixable) != 0 {
+		t.Errorf("overridden default policy = %+v", def)
+	}
+	team, _ := s.Get("team")
+	if team.DenyFixable[0] != "HIGH" || team.MaxAgeDays["MEDIUM"] != 90 {
+		t.Errorf("severities are not normalized: %+v", team)
+	}
+	if _, ok := s.Get("strict"); !ok {
+		t.Error("built-in policies lost")
+	}
+	if names := len(s.List()); names != 5 {
+		t.Errorf("List() has %d policies, want 5", names)
+	}
+
+	for name, content := range map[string]string{
+		"unnamed.json": `[{"maxCounts": {"CRITICAL": 0}}]`,
+		"broken.json":  `{"name": "x"}`,
+	} {
+		if _, err := Load(write(name, content)); err == nil {
+			t.Errorf("Load(%s) succeeded", name)
+		}
+	}
+	if _, err := Load(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Load of a missing file succeeded")
+	}
+
+	s.Replace(NewSet())
+	if def, _ := s.Get(""); def.MaxCounts != nil {
+		t.Errorf("Replace kept the old default policy %+v", def)
+	}
+}
@@ -0,0 +1,184 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/trivy-web-dash/apikey"
+	"github.com/trivy-web-dash/pkg/auth"
+	"github.com/trivy-web-dash/pkg/rbac"
+	"github.com/trivy-web-dash/types"
+)
+
+const contextKey = "project"
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Project is a tenant owning its own scan jobs, reports and summaries.
+type Project struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Members are rbac subjects such as "group:team-a" allowed to use the
+	// project. A project without members is open to everyone.
+	Members []string `json:"members,omitempty"`
+	// Webhook receives the report of every finished scan of the project.
+	Webhook string `json:"webhook,omitempty"`
+	// MaxActiveScans caps the queued and running scans of the project, zero
+	// is unlimited.
+	MaxActiveScans int `json:"maxActiveScans,omitempty"`
+}
+
+// Admits reports whether principal may use the project. Admins may use every
+// project.
+func (p Project) Admits(principal auth.Principal) bool {
+	if len(p.Members) == 0 || principal.Scope == apikey.ScopeAdmin {
+		return true
+	}
+	for _, m := range p.Members {
+		if rbac.Matches(m, principal) {
+			return true
+		}
+	}
+	return false
+}
+
+type Set struct {
+	mu       sync.RWMutex
+	projects map[string]Project
+}
+
+// NewSet returns projects and the default project, which may be overridden
+// to restrict its members or set its webhook and quota.
+func NewSet(projects ...Project) *Set {
+	s := &Set{projects: map[string]Project{
+		types.DefaultProject: {Name: types.DefaultProject},
+	}}
+	for _, p := range projects {
+		s.projects[p.Name] = p
+	}
+	return s
+}
+
+// Load reads a JSON array of projects.
+func Load(path string) (*Set, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var projects []Project
+	if err := json.Unmarshal(b, &projects); err != nil {
+		return nil, fmt.Errorf("parsing project file %s: %w", path, err)
+	}
+	for _, p := range projects {
+		if !validName.MatchString(p.Name) {
+			return nil, fmt.Errorf("project file %s: invalid project name %q", path, p.Name)
+		}
+		for _, m := range p.Members {
+			if !rbac.ValidSubject(m) {
+				return nil, fmt.Errorf("project file %s: invalid member %q of %s", path, m, p.Name)
+			}
+		}
+		if p.MaxActiveScans < 0 {
+			return nil, fmt.Errorf("project file %s: negative maxActiveScans of %s", path, p.Name)
+		}
+		if p.Webhook != "" {
+			if u, err := url.Parse(p.Webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return nil, fmt.Errorf("project file %s: invalid webhook url of %s", path, p.Name)
+			}
+		}
+	}
+	return NewSet(projects...), nil
+}
+
+func (s *Set) Get(name string) (Project, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.projects[types.ProjectName(name)]
+	return p, ok
+}
+
+func (s *Set) List() []Project {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	projects := make([]Project, 0, len(s.projects))
+	for _, p := range s.projects {
+		projects = append(projects, p)
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Name < projects[j].Name })
+	return projects
+}
+
+// Replace swaps in the members, webhooks and quotas of other. Projects are
+// baked into job keys and metrics, so other must define the same projects.
+func (s *Set) Replace(other *Set) error {
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(other.projects) != len(s.projects) {
+		return fmt.Errorf("projects cannot be added or removed without a restart")
+	}
+	for name := range s.projects {
+		if _, ok := other.projects[name]; !ok {
+			return fmt.Errorf("projects cannot be added or removed without a restart")
+		}
+	}
+	s.projects = other.projects
+	return nil
+}
+
+// Admitted lists the projects principal may use.
+func (s *Set) Admitted(principal auth.Principal) []Project {
+	var projects []Project
+	for _, p := range s.List() {
+		if p.Admits(principal) {
+			projects = append(projects, p)
+		}
+	}
+	return projects
+}
+
+// Select picks the project of a request from the X-Project header or the
+// "project" query or form parameter, the default project when none is given.
+// Unknown projects and projects the caller is no member of answer 404.
+func (s *Set) Select() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.GetHeader("X-Project")
+		if name == "" {
+			name = c.Query("project")
+		}
+		if name == "" {
+			name = c.PostForm("project")
+		}
+
+		p, ok := s.Get(name)
+		if ok {
+			principal, _ := auth.GetPrincipal(c)
+			ok = p.Admits(principal)
+		}
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "unknown project " + name})
+			return
+		}
+		c.Set(contextKey, p)
+		c.Next()
+	}
+}
+
+// Current returns the project selected for the request by Select.
+func Current(c *gin.Context) Project {
+	if p, ok := c.Get(contextKey); ok {
+		return p.(Project)
+	}
+	return Project{Name: types.DefaultProject}
+}
@@ -0,0 +1,152 @@
+package project
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/trivy-web-dash/apikey"
+	"github.com/trivy-web-dash/pkg/auth"
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/types"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	s, err := Load(write("ok.json", `[
+		{"name": "payments", "members": ["group:payments", "key:0123"], "webhook": "https://hooks.example.com/scan", "maxActiveScans": 5},
+		{"name": "default", "maxActiveScans": 20}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, ok := s.Get("payments")
+	if !ok || p.MaxActiveScans != 5 || p.Webhook != "https://hooks.example.com/scan" || len(p.Members) != 2 {
+		t.Errorf("Get(payments) = %+v, %v", p, ok)
+	}
+	// the file may set the quota of the default project
+	if def, _ := s.Get(""); def.MaxActiveScans != 20 {
+		t.Errorf("default project = %+v", def)
+	}
+
+	tests := []struct {
+		content string
+		err     string
+	}{
+		{`[{"name": "Payments"}]`, "invalid project name"},
+		{`[{"name": "payments", "members": ["payments"]}]`, "invalid member"},
+		{`[{"name": "payments", "webhook": "ftp://hooks.example.com"}]`, "invalid webhook url"},
+		{`[{"name": "payments", "maxActiveScans": -1}]`, "negative maxActiveScans"},
+		{`{}`, "parsing project file"},
+	}
+	for _, tt := range tests {
+		if _, err := Load(write("bad.json", tt.content)); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Load(%s) error = %v, want %q", tt.content, err, tt.err)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	s := NewSet(Project{Name: "payments", MaxActiveScans: 3})
+
+	for _, name := range []string{"", types.DefaultProject} {
+		if p, ok := s.Get(name); !ok || p.Name != types.DefaultProject || p.MaxActiveScans != 0 {
+			t.Errorf("Get(%q) = %+v, %v, want the unlimited default project", name, p, ok)
+		}
+	}
+	if p, ok := s.Get("payments"); !ok || p.MaxActiveScans != 3 {
+		t.Errorf("Get(payments) = %+v, %v", p, ok)
+	}
+	if _, ok := s.Get("missing"); ok {
+		t.Error("Get() found an unknown project")
+	}
+	if got := s.List(); len(got) != 2 || got[0].Name != types.DefaultProject || got[1].Name != "payments" {
+		t.Errorf("List() = %+v", got)
+	}
+}
+
+func TestAdmits(t *testing.T) {
+	s := NewSet(Project{Name: "payments", Members: []string{"group:payments", "key:0123"}})
+	tests := []struct {
+		name      string
+		principal auth.Principal
+		want      []string
+	}{
+		{"member group", auth.Principal{Email: "ada@example.com", Groups: []string{"payments"}, Session: true}, []string{"default", "payments"}},
+		{"member key", auth.Principal{Name: "ci", KeyID: "0123", Scope: apikey.ScopeScan}, []string{"default", "payments"}},
+		{"other key", auth.Principal{Name: "ci", KeyID: "4567", Scope: apikey.ScopeScan}, []string{"default"}},
+		{"admin", auth.Principal{Name: "admin", Scope: apikey.ScopeAdmin}, []string{"default", "payments"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, p := range s.Admitted(tt.principal) {
+			got = append(got, p.Name)
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: Admitted() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	s := NewSet(Project{Name: "payments"})
+	if err := s.Replace(NewSet(Project{Name: "payments", MaxActiveScans: 2})); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := s.Get("payments"); p.MaxActiveScans != 2 {
+		t.Errorf("quota after Replace() = %d", p.MaxActiveScans)
+	}
+	for _, other := range []*Set{NewSet(), NewSet(Project{Name: "payments"}, Project{Name: "search"}), NewSet(Project{Name: "search"})} {
+		if err := s.Replace(other); err == nil {
+			t.Errorf("Replace() with projects %+v succeeded", other.List())
+		}
+	}
+}
+
+// Membership is left to TestAdmits, Select runs here without auth.
+func TestSelect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	log := logger.NewAppLogger("fatal")
+	log.InitLogger()
+	s := NewSet(Project{Name: "payments"}, Project{Name: "search"})
+
+	tests := []struct {
+		target  string
+		header  string
+		code    int
+		project string
+	}{
+		{"/", "", http.StatusOK, types.DefaultProject},
+		{"/?project=payments", "", http.StatusOK, "payments"},
+		{"/?project=search", "payments", http.StatusOK, "payments"},
+		{"/?project=missing", "", http.StatusNotFound, ""},
+	}
+	r := gin.New()
+	r.GET("/", auth.New(false, "", nil, log).Require(apikey.ScopeRead), s.Select(), func(c *gin.Context) {
+		c.String(http.StatusOK, Current(c).Name)
+	})
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if tt.header != "" {
+			req.Header.Set("X-Project", tt.header)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != tt.code || (tt.code == http.StatusOK && rec.Body.String() != tt.project) {
+			t.Errorf("GET %s with X-Project %q = %d %s", tt.target, tt.header, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package pushevent
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+type distributionEnvelope struct {
+	Events []distributionEvent `json:"events"`
+}
+
+type distributionEvent struct {
+	Action string `json:"action"`
+	Target struct {
+		MediaType  string `json:"mediaType"`
+		Digest     string `json:"digest"`
+		Repository string `json:"repository"`
+		Tag        string `json:"tag"`
+	} `json:"target"`
+	Request struct {
+		Host string `json:"host"`
+	} `json:"request"`
+}
+
+// distribution handles Docker Registry v2 notifications. The registry is
+// configured with an endpoint header "Authorization: Bearer <token>".
+type distribution struct {
+	name   string
+	token  string
+	header string
+	host   string
+}
+
+// NewDistribution receives Docker Registry v2 notifications. host overrides the
+// request host recorded in events, for registries behind a proxy.
+func NewDistribution(token, host string) Receiver {
+	return &distribution{name: "registry", token: token, header: "Authorization", host: host}
+}
+
+// NewGitLab receives notifications from a GitLab container registry, which
+// uses the Docker Registry v2 format, authenticated with X-Gitlab-Token.
+func NewGitLab(token, host string) Receiver {
+	return &distribution{name: "gitlab", token: token, header: "X-Gitlab-Token", host: host}
+}
+
+func (d *distribution) Name() string {
+	return d.name
+}
+
+func (d *distribution) Verify(r *http.Request, body []byte) error {
+	if !tokenEqual(d.token, bearer(r.Header.Get(d.header))) {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+func (d *distribution) Parse(r *http.Request, body []byte) ([]string, error) {
+	var env distributionEnvelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return nil, err
+	}
+
+	var refs []string
+	for _, e := range env.Events {
+		// blob pushes are reported too, only manifests name a pullable image
+		if e.Action != "push" || !isManifest(e.Target.MediaType) || e.Target.Repository == "" {
+			continue
+		}
+
+		host := d.host
+		if host == "" {
+			host = e.Request.Host
+		}
+		ref := e.Target.Repository
+		if host != "" {
+			ref = host + "/" + ref
+		}
+
+		switch {
+		case e.Target.Tag != "":
+			refs = append(refs, ref+":"+e.Target.Tag)
+		case e.Target.Digest != "":
+			refs = append(refs, ref+"@"+e.Target.Digest)
+		}
+	}
+	return unique(refs), nil
+}
+
+func isManifest(mediaType string) bool {
+	return mediaType == "" || strings.Contains(mediaType, "manifest") || strings.Contains(mediaType, "image.index")
+}
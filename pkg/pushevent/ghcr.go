@@ -0,0 +1,104 @@
+package pushevent
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+type githubPackage struct {
+	Name           string `json:"name"`
+	Namespace      string `json:"namespace"`
+	PackageType    string `json:"package_type"`
+	PackageVersion struct {
+		Version           string `json:"version"`
+		PackageURL        string `json:"package_url"`
+		ContainerMetadata struct {
+			Tag struct {
+				Name   string `json:"name"`
+				Digest string `json:"digest"`
+			} `json:"tag"`
+		} `json:"container_metadata"`
+	} `json:"package_version"`
+}
+
+type githubPackageEvent struct {
+	Action          string         `json:"action"`
+	Package         *githubPackage `json:"package"`
+	RegistryPackage *githubPackage `json:"registry_package"`
+}
+
+// ghcr handles GitHub "package" and "registry_package" webhooks for container
+// packages, signed with the webhook secret in X-Hub-Signature-256.
+type ghcr struct {
+	secret string
+}
+
+func NewGHCR(secret string) Receiver {
+	return &ghcr{secret: secret}
+}
+
+func (g *ghcr) Name() string {
+	return "ghcr"
+}
+
+func (g *ghcr) Verify(r *http.Request, body []byte) error {
+	if g.secret == "" {
+		return ErrUnauthorized
+	}
+
+	sig := strings.TrimPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256=")
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return ErrUnauthorized
+	}
+
+	mac := hmac.New(sha256.New, []byte(g.secret))
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), got) {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+func (g *ghcr) Parse(r *http.Request, body []byte) ([]string, error) {
+	switch r.Header.Get("X-GitHub-Event") {
+	case "package", "registry_package":
+	default:
+		return nil, nil
+	}
+
+	var e githubPackageEvent
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, err
+	}
+	if e.Action != "published" {
+		return nil, nil
+	}
+
+	p := e.Package
+	if p == nil {
+		p = e.RegistryPackage
+	}
+	if p == nil || !strings.EqualFold(p.PackageType, "container") {
+		return nil, nil
+	}
+
+	v := p.PackageVersion
+	// untagged pushes are reported with an empty tag, e.g. "ghcr.io/org/app:"
+	if v.PackageURL != "" && !strings.HasSuffix(v.PackageURL, ":") {
+		return []string{v.PackageURL}, nil
+	}
+
+	repo := "ghcr.io/" + strings.ToLower(p.Namespace+"/"+p.Name)
+	switch {
+	case v.ContainerMetadata.Tag.Name != "":
+		return []string{repo + ":" + v.ContainerMetadata.Tag.Name}, nil
+	case strings.HasPrefix(v.Version, "sha256:"):
+		return []string{repo + "@" + v.Version}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,74 @@
+package pushevent
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+type harborEvent struct {
+	Type      string `json:"type"`
+	EventData struct {
+		Resources []struct {
+			Digest      string `json:"digest"`
+			Tag         string `json:"tag"`
+			ResourceURL string `json:"resource_url"`
+		} `json:"resources"`
+		Repository struct {
+			RepoFullName string `json:"repo_full_name"`
+		} `json:"repository"`
+	} `json:"event_data"`
+}
+
+// harbor handles Harbor webhook policies of type http. Harbor sends the
+// configured "Auth Header" value verbatim in the Authorization header.
+type harbor struct {
+	auth string
+	host string
+}
+
+// NewHarbor receives Harbor PUSH_ARTIFACT events. host is used when a resource
+// carries no resource_url.
+func NewHarbor(auth, host string) Receiver {
+	return &harbor{auth: auth, host: host}
+}
+
+func (h *harbor) Name() string {
+	return "harbor"
+}
+
+func (h *harbor) Verify(r *http.Request, body []byte) error {
+	got := r.Header.Get("Authorization")
+	if !tokenEqual(h.auth, got) && !tokenEqual(h.auth, bearer(got)) {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+func (h *harbor) Parse(r *http.Request, body []byte) ([]string, error) {
+	var e harborEvent
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(e.Type, "PUSH_ARTIFACT") && !strings.EqualFold(e.Type, "pushImage") {
+		return nil, nil
+	}
+
+	var refs []string
+	for _, res := range e.EventData.Resources {
+		if res.ResourceURL != "" {
+			refs = append(refs, res.ResourceURL)
+			continue
+		}
+		if h.host == "" || e.EventData.Repository.RepoFullName == "" {
+			continue
+		}
+		ref := h.host + "/" + e.EventData.Repository.RepoFullName
+		if res.Tag != "" {
+			refs = append(refs, ref+":"+res.Tag)
+		} else if res.Digest != "" {
+			refs = append(refs, ref+"@"+res.Digest)
+		}
+	}
+	return unique(refs), nil
+}
@@ -0,0 +1,47 @@
+package pushevent
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var ErrUnauthorized = errors.New("invalid webhook signature or token")
+
+// Receiver turns a registry push notification into the image references that
+// were pushed. Verify is always called before Parse.
+type Receiver interface {
+	Name() string
+	Verify(r *http.Request, body []byte) error
+	Parse(r *http.Request, body []byte) ([]string, error)
+}
+
+// tokenEqual compares secrets in constant time. An empty expected token never
+// matches so that a misconfigured receiver rejects everything.
+func tokenEqual(expected, got string) bool {
+	if expected == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(got)) == 1
+}
+
+// bearer strips an optional "Bearer " prefix from an Authorization header.
+func bearer(header string) string {
+	if len(header) > 7 && strings.EqualFold(header[:7], "bearer ") {
+		return header[7:]
+	}
+	return header
+}
+
+func unique(refs []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, ref := range refs {
+		if ref != "" && !seen[ref] {
+			seen[ref] = true
+			out = append(out, ref)
+		}
+	}
+	return out
+}
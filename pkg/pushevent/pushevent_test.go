@@ -0,0 +1,147 @@
+package pushevent
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func request(headers map[string]string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/hooks", nil)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	return r
+}
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerify(t *testing.T) {
+	const body = `{"events": []}`
+	tests := []struct {
+		name     string
+		receiver Receiver
+		headers  map[string]string
+		ok       bool
+	}{
+		{"registry bearer", NewDistribution("s3cret", ""), map[string]string{"Authorization": "Bearer s3cret"}, true},
+		{"registry raw token", NewDistribution("s3cret", ""), map[string]string{"Authorization": "s3cret"}, true},
+		{"registry wrong token", NewDistribution("s3cret", ""), map[string]string{"Authorization": "Bearer other"}, false},
+		{"registry no header", NewDistribution("s3cret", ""), nil, false},
+		{"registry unconfigured", NewDistribution("", ""), map[string]string{"Authorization": "Bearer "}, false},
+		{"gitlab token", NewGitLab("s3cret", ""), map[string]string{"X-Gitlab-Token": "s3cret"}, true},
+		{"gitlab authorization ignored", NewGitLab("s3cret", ""), map[string]string{"Authorization": "Bearer s3cret"}, false},
+		{"harbor raw", NewHarbor("Basic abc", ""), map[string]string{"Authorization": "Basic abc"}, true},
+		{"harbor bearer", NewHarbor("abc", ""), map[string]string{"Authorization": "Bearer abc"}, true},
+		{"harbor wrong", NewHarbor("abc", ""), map[string]string{"Authorization": "abd"}, false},
+		{"ghcr signed", NewGHCR("s3cret"), map[string]string{"X-Hub-Signature-256": sign("s3cret", body)}, true},
+		{"ghcr other secret", NewGHCR("s3cret"), map[string]string{"X-Hub-Signature-256": sign("other", body)}, false},
+		{"ghcr other body", NewGHCR("s3cret"), map[string]string{"X-Hub-Signature-256": sign("s3cret", body+" ")}, false},
+		{"ghcr not hex", NewGHCR("s3cret"), map[string]string{"X-Hub-Signature-256": "sha256=zz"}, false},
+		{"ghcr unconfigured", NewGHCR(""), map[string]string{"X-Hub-Signature-256": sign("", body)}, false},
+	}
+	for _, tt := range tests {
+		err := tt.receiver.Verify(request(tt.headers), []byte(body))
+		if tt.ok && err != nil {
+			t.Errorf("%s: Verify() = %v", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("%s: Verify() = %v, want ErrUnauthorized", tt.name, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		receiver Receiver
+		headers  map[string]string
+		body     string
+		want     []string
+	}{
+		{
+			name:     "registry manifests only",
+			receiver: NewDistribution("t", ""),
+			body: `{"events": [
+				{"action": "push", "target": {"mediaType": "application/vnd.oci.image.manifest.v1+json", "repository": "team/app", "tag": "1.0"}, "request": {"host": "registry.example.com"}},
+				{"action": "push", "target": {"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "repository": "team/app", "digest": "sha256:layer"}, "request": {"host": "registry.example.com"}},
+				{"action": "push", "target": {"mediaType": "application/vnd.oci.image.index.v1+json", "repository": "team/app", "digest": "sha256:index"}, "request": {"host": "registry.example.com"}},
+				{"action": "pull", "target": {"repository": "team/app", "tag": "1.0"}, "request": {"host": "registry.example.com"}},
+				{"action": "push", "target": {"repository": "team/app", "tag": "1.0"}, "request": {"host": "registry.example.com"}}
+			]}`,
+			want: []string{"registry.example.com/team/app:1.0", "registry.example.com/team/app@sha256:index"},
+		},
+		{
+			name:     "registry host override",
+			receiver: NewDistribution("t", "registry.example.com"),
+			body:     `{"events": [{"action": "push", "target": {"repository": "team/app", "tag": "1.0"}, "request": {"host": "10.0.0.1:5000"}}]}`,
+			want:     []string{"registry.example.com/team/app:1.0"},
+		},
+		{
+			name:     "harbor resource url",
+			receiver: NewHarbor("a", ""),
+			body:     `{"type": "PUSH_ARTIFACT", "event_data": {"resources": [{"tag": "1.0", "resource_url": "harbor.example.com/team/app:1.0"}]}}`,
+			want:     []string{"harbor.example.com/team/app:1.0"},
+		},
+		{
+			name:     "harbor host fallback",
+			receiver: NewHarbor("a", "harbor.example.com"),
+			body:     `{"type": "PUSH_ARTIFACT", "event_data": {"resources": [{"digest": "sha256:aaaa"}], "repository": {"repo_full_name": "team/app"}}}`,
+			want:     []string{"harbor.example.com/team/app@sha256:aaaa"},
+		},
+		{
+			name:     "harbor other event",
+			receiver: NewHarbor("a", ""),
+			body:     `{"type": "DELETE_ARTIFACT", "event_data": {"resources": [{"resource_url": "harbor.example.com/team/app:1.0"}]}}`,
+		},
+		{
+			name:     "ghcr package url",
+			receiver: NewGHCR("s"),
+			headers:  map[string]string{"X-GitHub-Event": "package"},
+			body:     `{"action": "published", "package": {"name": "app", "namespace": "Org", "package_type": "CONTAINER", "package_version": {"package_url": "ghcr.io/org/app:1.0"}}}`,
+			want:     []string{"ghcr.io/org/app:1.0"},
+		},
+		{
+			name:     "ghcr untagged",
+			receiver: NewGHCR("s"),
+			headers:  map[string]string{"X-GitHub-Event": "registry_package"},
+			body:     `{"action": "published", "registry_package": {"name": "App", "namespace": "Org", "package_type": "container", "package_version": {"version": "sha256:bbbb", "package_url": "ghcr.io/org/app:"}}}`,
+			want:     []string{"ghcr.io/org/app@sha256:bbbb"},
+		},
+		{
+			name:     "ghcr npm package",
+			receiver: NewGHCR("s"),
+			headers:  map[string]string{"X-GitHub-Event": "package"},
+			body:     `{"action": "published", "package": {"name": "lib", "namespace": "org", "package_type": "npm", "package_version": {"version": "1.0.0"}}}`,
+		},
+		{
+			name:     "ghcr other event",
+			receiver: NewGHCR("s"),
+			headers:  map[string]string{"X-GitHub-Event": "push"},
+			body:     `{}`,
+		},
+	}
+	for _, tt := range tests {
+		refs, err := tt.receiver.Parse(request(tt.headers), []byte(tt.body))
+		if err != nil {
+			t.Errorf("%s: Parse() error = %v", tt.name, err)
+			continue
+		}
+		if strings.Join(refs, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: Parse() = %q, want %q", tt.name, refs, tt.want)
+		}
+	}
+
+	if _, err := NewDistribution("t", "").Parse(request(nil), []byte(`{`)); err == nil {
+		t.Error("Parse() of a broken body succeeded")
+	}
+}
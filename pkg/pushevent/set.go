@@ -0,0 +1,40 @@
+package pushevent
+
+import "sync"
+
+// Hook is a receiver bound to the project its scans are queued in.
+type Hook struct {
+	Receiver Receiver
+	Project  string
+}
+
+// Set holds the enabled receivers by name.
+type Set struct {
+	mu    sync.RWMutex
+	hooks map[string]Hook
+}
+
+func NewSet(hooks ...Hook) *Set {
+	s := &Set{}
+	s.Replace(hooks)
+	return s
+}
+
+// Replace swaps in hooks, as after reloading the configuration.
+func (s *Set) Replace(hooks []Hook) {
+	m := map[string]Hook{}
+	for _, h := range hooks {
+		m[h.Receiver.Name()] = h
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = m
+}
+
+// Get returns the receiver called name, if it is enabled.
+func (s *Set) Get(name string) (Hook, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	h, ok := s.hooks[name]
+	return h, ok
+}
@@ -1,56 +1,231 @@
 package queue
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/gocraft/work"
 	"github.com/gomodule/redigo/redis"
 	"github.com/trivy-web-dash/pkg/db"
 	"github.com/trivy-web-dash/pkg/job"
+	"github.com/trivy-web-dash/pkg/project"
+	"github.com/trivy-web-dash/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// Namespace prefixes the redis keys of the job queues.
+const Namespace = "trivy-scanner"
+
 const (
 	scanArtifactJobName = "scan_artifact"
 	scanRequestJobArg   = "scan_request"
+	platformJobArg      = "platform"
+	projectJobArg       = "project"
+	engineJobArg        = "engine"
+	scanSBOMJobName     = "scan_sbom"
+	sbomDigestJobArg    = "sbom_digest"
+	crawlJobName        = "crawl_registry"
+	crawlTargetJobArg   = "registry_target"
+	quotaJobArg         = "quota_token"
 )
 
+// reservationTTL frees the quota slots of jobs that never finish, as when
+// their worker died, after the hour scan jobs are kept for.
+const reservationTTL = time.Hour
+
+// reserveScript takes one of the ARGV[2] scan slots of a project, a sorted set
+// of reservation tokens scored by their expiry. It answers -1 once reserved,
+// else the number of slots in use.
+var reserveScript = redis.NewScript(1, `
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", ARGV[1])
+local active = redis.call("ZCARD", KEYS[1])
+if active >= tonumber(ARGV[2]) then
+	return active
+end
+redis.call("ZADD", KEYS[1], ARGV[3], ARGV[4])
+redis.call("EXPIRE", KEYS[1], ARGV[5])
+return -1
+`)
+
+// Enqueuer queues scans on behalf of a project, an empty project is the
+// default one. Scans run with engine, or the configured one when it is empty.
+// Jobs continue the trace of ctx when they run. A scan beyond the project's
+// quota fails with a *QuotaError.
 type Enqueuer interface {
-	Enqueue(image string) (job.ScanJob, error)
+	Enqueue(ctx context.Context, project, image, engine string) (job.ScanJob, error)
+	// EnqueuePlatform queues a scan of one platform of a multi-platform image.
+	EnqueuePlatform(ctx context.Context, project, image, platform, engine string) (job.ScanJob, error)
+	// EnqueueSBOM queues a scan of an SBOM previously saved with db.Store.SaveSBOM.
+	EnqueueSBOM(ctx context.Context, project, digest, engine string) (job.ScanJob, error)
+	// EnqueueCrawled queues a scan of an image found by a crawl of a registry
+	// target, which pulls it with the credentials of the target.
+	EnqueueCrawled(ctx context.Context, project, image, target string) (job.ScanJob, error)
+	// EnqueueCrawl queues a crawl of a registry target and returns the job ID.
+	// Crawls are not scan jobs and are not tracked in the store.
+	EnqueueCrawl(ctx context.Context, target string) (string, error)
+}
+
+// QuotaError is returned when a scan would exceed the MaxActiveScans of its
+// project.
+type QuotaError struct {
+	Project string
+	Active  int
+	Max     int
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("project %s has %d of %d scans queued", e.Project, e.Active, e.Max)
 }
 
 type enqueuer struct {
 	enqueuer *work.Enqueuer
+	pool     *redis.Pool
 	store    db.Store
+	projects *project.Set
 }
 
-func NewEnqueuer(redisPool *redis.Pool, store db.Store) Enqueuer {
+func NewEnqueuer(redisPool *redis.Pool, store db.Store, projects *project.Set) Enqueuer {
 	return &enqueuer{
-		enqueuer: work.NewEnqueuer("trivy-scanner", redisPool),
+		enqueuer: work.NewEnqueuer(Namespace, redisPool),
+		pool:     redisPool,
 		store:    store,
+		projects: projects,
 	}
 }
 
-func (e *enqueuer) Enqueue(image string) (job.ScanJob, error) {
-	log.Println("Enqueueing scan job")
-	j, err := e.enqueuer.Enqueue(scanArtifactJobName, work.Q{
+func (e *enqueuer) Enqueue(ctx context.Context, project, image, engine string) (job.ScanJob, error) {
+	return e.enqueue(ctx, project, scanArtifactJobName, work.Q{
 		scanRequestJobArg: string(image),
+		engineJobArg:      engine,
+	})
+}
+
+func (e *enqueuer) EnqueuePlatform(ctx context.Context, project, image, platform, engine string) (job.ScanJob, error) {
+	return e.enqueue(ctx, project, scanArtifactJobName, work.Q{
+		scanRequestJobArg: image,
+		platformJobArg:    platform,
+		engineJobArg:      engine,
 	})
+}
+
+func (e *enqueuer) EnqueueCrawled(ctx context.Context, project, image, target string) (job.ScanJob, error) {
+	// the target's name only, its credentials are looked up when the scan runs
+	return e.enqueue(ctx, project, scanArtifactJobName, work.Q{
+		scanRequestJobArg: image,
+		crawlTargetJobArg: target,
+	})
+}
+
+func (e *enqueuer) EnqueueSBOM(ctx context.Context, project, digest, engine string) (job.ScanJob, error) {
+	return e.enqueue(ctx, project, scanSBOMJobName, work.Q{
+		sbomDigestJobArg: digest,
+		engineJobArg:     engine,
+	})
+}
+
+func (e *enqueuer) EnqueueCrawl(ctx context.Context, target string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "enqueue "+crawlJobName, attribute.String("registry.target", target))
+	defer func() { tracing.End(span, err) }()
+
+	args := work.Q{crawlTargetJobArg: target}
+	tracing.InjectArgs(ctx, args)
+	j, err := e.enqueuer.Enqueue(crawlJobName, args)
+	if err != nil {
+		return "", fmt.Errorf("enqueuing crawl registry job: %v", err)
+	}
+	return j.ID, nil
+}
 
+func (e *enqueuer) enqueue(ctx context.Context, projectName, jobName string, args work.Q) (_ job.ScanJob, err error) {
+	ctx, span := tracing.Start(ctx, "enqueue "+jobName, attribute.String("project", projectName))
+	defer func() { tracing.End(span, err) }()
+
+	p, ok := e.projects.Get(projectName)
+	if !ok {
+		return job.ScanJob{}, fmt.Errorf("unknown project %q", projectName)
+	}
+	token, err := e.reserve(ctx, p)
+	if err != nil {
+		return job.ScanJob{}, err
+	}
+	if token != "" {
+		args[quotaJobArg] = token
+	}
+	args[projectJobArg] = p.Name
+	tracing.InjectArgs(ctx, args)
+
+	log.Println("Enqueueing scan job")
+	j, err := e.enqueuer.Enqueue(jobName, args)
 	if err != nil {
+		if token != "" {
+			_ = release(e.pool, p.Name, token)
+		}
 		return job.ScanJob{}, fmt.Errorf("enqueuing scan artifact job: %v", err)
 	}
 
 	log.Println("Successfully enqueued scan job")
+	span.SetAttributes(attribute.String("job.id", j.ID))
+	image, _ := args[scanRequestJobArg].(string)
 	scanJob := job.ScanJob{
 		ID:      j.ID,
+		Project: p.Name,
+		Image:   image,
 		Status:  job.Queued,
+		Webhook: p.Webhook,
 	}
 
-	err = e.store.Create(scanJob)
+	err = e.store.WithContext(ctx).Create(scanJob)
 	if err != nil {
 		return job.ScanJob{}, fmt.Errorf("creating scan job %v", err)
 	}
 
 	return scanJob, nil
 }
+
+// reserve takes a slot of the MaxActiveScans of project p and returns the
+// token the worker releases it with once the job finished. Projects without a
+// quota get no token.
+func (e *enqueuer) reserve(ctx context.Context, p project.Project) (string, error) {
+	if p.MaxActiveScans == 0 {
+		return "", nil
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	conn, err := e.pool.GetContext(ctx)
+	if err != nil {
+		return "", fmt.Errorf("reserving a scan of project %s: %v", p.Name, err)
+	}
+	defer conn.Close()
+
+	now := time.Now()
+	active, err := redis.Int(reserveScript.Do(conn, quotaKey(p.Name), now.Unix(), p.MaxActiveScans,
+		now.Add(reservationTTL).Unix(), token, int(reservationTTL.Seconds())))
+	if err != nil {
+		return "", fmt.Errorf("reserving a scan of project %s: %v", p.Name, err)
+	}
+	if active >= 0 {
+		return "", &QuotaError{Project: p.Name, Active: active, Max: p.MaxActiveScans}
+	}
+	return token, nil
+}
+
+// release frees the quota slot reserved under token.
+func release(pool *redis.Pool, project, token string) error {
+	conn := pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("ZREM", quotaKey(project), token)
+	return err
+}
+
+func quotaKey(project string) string {
+	return Namespace + ":quota:" + project
+}
@@ -0,0 +1,142 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/trivy-web-dash/pkg/db"
+	redisx "github.com/trivy-web-dash/pkg/db/redis"
+	"github.com/trivy-web-dash/pkg/job"
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/pkg/project"
+	scanner "github.com/trivy-web-dash/pkg/trivy/controller"
+)
+
+func newTestEnqueuer(t *testing.T, projects *project.Set) (Enqueuer, db.Store) {
+	t.Helper()
+	e, pool := newTestEnqueuerPool(t, projects)
+	return e, redisx.NewStore(pool)
+}
+
+func newTestEnqueuerPool(t *testing.T, projects *project.Set) (Enqueuer, *redis.Pool) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	pool, err := redisx.NewPool(mr.Addr(), "", 0, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewEnqueuer(pool, redisx.NewStore(pool), projects), pool
+}
+
+func TestEnqueue(t *testing.T) {
+	e, store := newTestEnqueuer(t, project.NewSet(project.Project{Name: "payments", Webhook: "https://ci.example.com/hook"}))
+	ctx := context.Background()
+
+	j, err := e.Enqueue(ctx, "payments", "alpine:3.19", "grype")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.ID == "" || j.Project != "payments" || j.Image != "alpine:3.19" || j.Status != job.Queued || j.Webhook != "https://ci.example.com/hook" {
+		t.Errorf("Enqueue() = %+v", j)
+	}
+	stored, err := store.Get("payments", j.ID)
+	if err != nil || stored == nil || stored.Image != "alpine:3.19" {
+		t.Errorf("stored job = %+v, %v", stored, err)
+	}
+
+	if j, err := e.Enqueue(ctx, "", "alpine:3.19", ""); err != nil || j.Project != "default" {
+		t.Errorf("Enqueue() in the default project = %+v, %v", j, err)
+	}
+	if j, err := e.EnqueueSBOM(ctx, "", "abcd", ""); err != nil || j.Image != "" {
+		t.Errorf("EnqueueSBOM() = %+v, %v", j, err)
+	}
+	if _, err := e.Enqueue(ctx, "missing", "alpine:3.19", ""); err == nil {
+		t.Error("Enqueue() into an unknown project succeeded")
+	}
+}
+
+type doneController struct {
+	scanner.Controller
+}
+
+func (doneController) Scan(ctx context.Context, scanJobID, project, imageRef, platform, engine string) error {
+	return nil
+}
+
+func TestEnqueueQuota(t *testing.T) {
+	e, pool := newTestEnqueuerPool(t, project.NewSet(project.Project{Name: "payments", MaxActiveScans: 2}))
+	ctx := context.Background()
+
+	if _, err := e.Enqueue(ctx, "payments", "alpine:3.19", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.EnqueuePlatform(ctx, "payments", "alpine:3.19", "linux/arm64", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// every kind of scan counts against the quota
+	for name, enqueue := range map[string]func() (job.ScanJob, error){
+		"image": func() (job.ScanJob, error) { return e.Enqueue(ctx, "payments", "nginx:1.25", "") },
+		"platform": func() (job.ScanJob, error) {
+			return e.EnqueuePlatform(ctx, "payments", "nginx:1.25", "linux/amd64", "")
+		},
+		"sbom": func() (job.ScanJob, error) { return e.EnqueueSBOM(ctx, "payments", "abcd", "") },
+	} {
+		_, err := enqueue()
+		var quota *QuotaError
+		if !errors.As(err, &quota) || quota.Project != "payments" || quota.Active != 2 || quota.Max != 2 {
+			t.Errorf("%s beyond the quota: %v", name, err)
+		}
+	}
+
+	// other projects have their own quota
+	if _, err := e.Enqueue(ctx, "", "nginx:1.25", ""); err != nil {
+		t.Errorf("default project limited by payments' quota: %v", err)
+	}
+
+	// finished scans free their slot
+	log := logger.NewAppLogger("fatal")
+	log.InitLogger()
+	w := NewWorker(pool, doneController{}, nil, 1, log)
+	w.Start()
+	defer w.Stop()
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(50 * time.Millisecond) {
+		_, err := e.Enqueue(ctx, "payments", "nginx:1.25", "")
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Enqueue() after the scans finished: %v", err)
+		}
+	}
+}
+
+// Concurrent submissions, as for platforms=all, never go over the quota.
+func TestEnqueueQuotaConcurrent(t *testing.T) {
+	e, _ := newTestEnqueuer(t, project.NewSet(project.Project{Name: "payments", MaxActiveScans: 3}))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	queued := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := e.Enqueue(context.Background(), "payments", "alpine:3.19", ""); err == nil {
+				mu.Lock()
+				queued++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if queued != 3 {
+		t.Errorf("%d concurrent scans queued, quota is 3", queued)
+	}
+}
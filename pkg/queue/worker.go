@@ -1,35 +1,109 @@
 package queue
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/gocraft/work"
 	"github.com/gomodule/redigo/redis"
 	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/pkg/tracing"
+	tc "github.com/trivy-web-dash/pkg/trivy"
 	scanner "github.com/trivy-web-dash/pkg/trivy/controller"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	scanJobDefaultPriority = 1 // The highest
 	scanJobMaxFailures     = 1
+	crawlJobMaxFailures    = 1
 )
 
+// Crawler lists registries and enqueues scans for their images.
+type Crawler interface {
+	Crawl(ctx context.Context, target string) error
+	// Schedules maps target names to the cron spec they are crawled on.
+	Schedules() map[string]string
+	// Credentials returns the credentials the images of target are pulled with.
+	Credentials(target string) (tc.Credentials, bool)
+}
+
 type Worker interface {
 	Start()
 	Stop()
+	// Alive fails when the worker pool of this process stopped sending
+	// heartbeats.
+	Alive(ctx context.Context) error
+	// Reload restarts the pool to apply changed crawl schedules. Running jobs
+	// finish first, the worker stays alive meanwhile.
+	Reload()
 }
 
+// heartbeatTimeout is how long a running worker pool may go without a
+// heartbeat, gocraft sends one every 5 seconds.
+const heartbeatTimeout = 30 * time.Second
+
 type worker struct {
+	redisPool   *redis.Pool
+	controller  scanner.Controller
+	crawler     Crawler
+	concurrency uint
+	client      *work.Client
+	log         logger.Logger
+
+	mu         sync.Mutex
 	workerPool *work.WorkerPool
-	log        logger.Logger
+	// reloading is set while Reload swaps pools, so the gap between the old
+	// pool's last heartbeat and the new one's first is not taken for a crash.
+	reloading atomic.Bool
 }
 
-func NewWorker(redisPool *redis.Pool, controller scanner.Controller, l logger.Logger) Worker {
-	workerPool := work.NewWorkerPool(workerContext{}, uint(5), "trivy-scanner", redisPool)
+// NewWorker runs scan jobs, at most concurrency at a time, and, when crawler
+// is not nil, registry crawls.
+func NewWorker(redisPool *redis.Pool, controller scanner.Controller, crawler Crawler, concurrency uint, l logger.Logger) Worker {
+	w := &worker{
+		redisPool:   redisPool,
+		controller:  controller,
+		crawler:     crawler,
+		concurrency: concurrency,
+		client:      work.NewClient(Namespace, redisPool),
+		log:         l,
+	}
+	w.workerPool = w.newPool()
+	return w
+}
+
+func (w *worker) newPool() *work.WorkerPool {
+	controller, crawler := w.controller, w.crawler
+	workerPool := work.NewWorkerPool(workerContext{}, w.concurrency, Namespace, w.redisPool)
 
 	// Note: For each scan job a new instance of the workerContext struct is created.
 	// Therefore, the only way to do a proper dependency injection is to use such closure
 	// and the following middleware as the first step in the processing chain.
-	workerPool.Middleware(func(ctx *workerContext, job *work.Job, next work.NextMiddlewareFunc) error {
+	workerPool.Middleware(func(ctx *workerContext, job *work.Job, next work.NextMiddlewareFunc) (err error) {
 		ctx.controller = controller
+		ctx.crawler = crawler
+
+		// finished jobs free their quota slot, failed ones included
+		if token := job.ArgString(quotaJobArg); token != "" {
+			defer func() {
+				if err := release(w.redisPool, job.ArgString(projectJobArg), token); err != nil {
+					w.log.Errorf("releasing quota of job %s : %v", job.ID, err)
+				}
+			}()
+		}
+
+		// continue the trace of the request that queued the job
+		var span trace.Span
+		ctx.ctx, span = tracing.Start(tracing.ExtractArgs(context.Background(), job.Args), "job "+job.Name,
+			attribute.String("job.id", job.ID))
+		defer func() { tracing.End(span, err) }()
 		return next()
 	})
 
@@ -39,29 +113,131 @@ func NewWorker(redisPool *redis.Pool, controller scanner.Controller, l logger.Lo
 			MaxFails: scanJobMaxFailures,
 		}, (*workerContext).ScanArtifact)
 
-	return &worker{
-		workerPool: workerPool,
-		log:        l,
+	workerPool.JobWithOptions(scanSBOMJobName,
+		work.JobOptions{
+			Priority: scanJobDefaultPriority,
+			MaxFails: scanJobMaxFailures,
+		}, (*workerContext).ScanSBOM)
+
+	if crawler != nil {
+		workerPool.JobWithOptions(crawlJobName,
+			work.JobOptions{MaxFails: crawlJobMaxFailures}, (*workerContext).CrawlRegistry)
+
+		// periodic jobs carry no arguments, so every scheduled target gets its
+		// own job name
+		for target, spec := range crawler.Schedules() {
+			target := target
+			jobName := crawlJobName + "_" + target
+			workerPool.JobWithOptions(jobName,
+				work.JobOptions{MaxFails: crawlJobMaxFailures},
+				func(ctx *workerContext, job *work.Job) error {
+					return ctx.crawler.Crawl(ctx.ctx, target)
+				})
+			workerPool.PeriodicallyEnqueue(spec, jobName)
+		}
 	}
+
+	return workerPool
 }
 
 func (w *worker) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	w.log.Info("starting worker")
 	w.workerPool.Start()
 }
 
 func (w *worker) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	w.log.Info("stopping worker")
 	w.workerPool.Stop()
 	w.log.Info("stopped worker")
 }
 
+func (w *worker) Reload() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.log.Info("restarting worker")
+	w.reloading.Store(true)
+	defer w.reloading.Store(false)
+	w.workerPool.Stop()
+	w.workerPool = w.newPool()
+	w.workerPool.Start()
+}
+
+// Alive looks for the heartbeat of the pool by host and pid. Heartbeats are
+// kept in redis, whose outages are left to the redis check.
+func (w *worker) Alive(ctx context.Context) error {
+	if w.reloading.Load() {
+		return nil
+	}
+	heartbeats, err := w.client.WorkerPoolHeartbeats()
+	if err != nil {
+		w.log.Errorf("unable to read worker heartbeats : %v", err)
+		return nil
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	for _, hb := range heartbeats {
+		if hb.Host != host || hb.Pid != os.Getpid() {
+			continue
+		}
+		if age := time.Since(time.Unix(hb.HeartbeatAt, 0)); age > heartbeatTimeout {
+			return fmt.Errorf("last worker heartbeat %s ago", age.Round(time.Second))
+		}
+		return nil
+	}
+	return errors.New("worker pool is not running")
+}
+
+// Backlog fails when a job queue holds more than maxDepth jobs or its oldest
+// job waited longer than maxLatency. Zero limits are not checked.
+func Backlog(redisPool *redis.Pool, maxDepth int64, maxLatency time.Duration) func(context.Context) error {
+	client := work.NewClient(Namespace, redisPool)
+	return func(ctx context.Context) error {
+		queues, err := client.Queues()
+		if err != nil {
+			return err
+		}
+		for _, q := range queues {
+			if maxDepth > 0 && q.Count > maxDepth {
+				return fmt.Errorf("%s: %d jobs queued, limit %d", q.JobName, q.Count, maxDepth)
+			}
+			if latency := time.Duration(q.Latency) * time.Second; maxLatency > 0 && latency > maxLatency {
+				return fmt.Errorf("%s: oldest job queued %s ago, limit %s", q.JobName, latency, maxLatency)
+			}
+		}
+		return nil
+	}
+}
+
 // workerContext is a context for running scan jobs.
 type workerContext struct {
+	ctx        context.Context
 	controller scanner.Controller
+	crawler    Crawler
 }
 
 func (s *workerContext) ScanArtifact(job *work.Job) (err error) {
+	ctx := s.ctx
+	if target := job.ArgString(crawlTargetJobArg); target != "" && s.crawler != nil {
+		if creds, ok := s.crawler.Credentials(target); ok {
+			ctx = tc.WithCredentials(ctx, creds)
+		}
+	}
 	// "scan_request"
-	return s.controller.Scan(job.ID, job.ArgString(scanRequestJobArg))
+	return s.controller.Scan(ctx, job.ID, job.ArgString(projectJobArg), job.ArgString(scanRequestJobArg),
+		job.ArgString(platformJobArg), job.ArgString(engineJobArg))
+}
+
+func (s *workerContext) ScanSBOM(job *work.Job) (err error) {
+	return s.controller.ScanSBOM(s.ctx, job.ID, job.ArgString(projectJobArg), job.ArgString(sbomDigestJobArg), job.ArgString(engineJobArg))
+}
+
+func (s *workerContext) CrawlRegistry(job *work.Job) error {
+	return s.crawler.Crawl(s.ctx, job.ArgString(crawlTargetJobArg))
 }
@@ -0,0 +1,255 @@
+package queue
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	redisx "github.com/trivy-web-dash/pkg/db/redis"
+	"github.com/trivy-web-dash/pkg/job"
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/pkg/project"
+	"github.com/trivy-web-dash/pkg/tracing"
+	tc "github.com/trivy-web-dash/pkg/trivy"
+	scanner "github.com/trivy-web-dash/pkg/trivy/controller"
+	"github.com/trivy-web-dash/regopolicy"
+	"github.com/trivy-web-dash/report"
+	"github.com/trivy-web-dash/summary"
+	"github.com/trivy-web-dash/types"
+)
+
+type fakeScanner struct {
+	tc.Scanner
+}
+
+func (fakeScanner) Scan(ctx context.Context, imageRef, platform string) (*types.Report, error) {
+	return &types.Report{ArtifactName: imageRef}, nil
+}
+
+func TestTracePropagation(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	shutdown := tracing.Install(exp, "test")
+	t.Cleanup(func() { shutdown(context.Background()) })
+
+	redis := miniredis.RunT(t)
+	log := logger.NewAppLogger("fatal")
+	log.InitLogger()
+	if err := report.NewReportClient(redis.Addr(), "", 1, false, false, time.Hour, log); err != nil {
+		t.Fatal(err)
+	}
+	if err := summary.NewSummaryClient(redis.Addr(), "", 2, false, false, time.Hour, log); err != nil {
+		t.Fatal(err)
+	}
+	if err := regopolicy.NewRegoClient(redis.Addr(), "", 6, false, false, log); err != nil {
+		t.Fatal(err)
+	}
+	pool, err := redisx.NewPool(redis.Addr(), "", 5, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := redisx.NewStore(pool)
+	e := NewEnqueuer(pool, store, project.NewSet())
+
+	var id string
+	r := gin.New()
+	r.Use(tracing.Middleware())
+	r.POST("/scan/image", func(c *gin.Context) {
+		j, err := e.Enqueue(c.Request.Context(), "", c.PostForm("image"), "")
+		if err != nil {
+			c.AbortWithStatus(http.StatusBadGateway)
+			return
+		}
+		id = j.ID
+	})
+	req := httptest.NewRequest(http.MethodPost, "/scan/image", strings.NewReader("image=alpine:3.19"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("scan request: %d", rec.Code)
+	}
+
+	c := scanner.NewController(store, map[string]tc.Scanner{types.EngineTrivy: fakeScanner{}}, types.EngineTrivy, log)
+	w := NewWorker(pool, c, nil, 1, log)
+	w.Start()
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(50 * time.Millisecond) {
+		j, err := store.Get("", id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if j.Status == job.Done {
+			break
+		}
+		if j.Status == job.ScanFail || time.Now().After(deadline) {
+			w.Stop()
+			t.Fatalf("scan job %+v", j)
+		}
+	}
+	w.Stop()
+	// the exporter forgets its spans on shutdown
+	if err := otel.GetTracerProvider().(*sdktrace.TracerProvider).ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots := exp.GetSpans().Snapshots()
+	names := map[trace.SpanID]string{}
+	for _, s := range snapshots {
+		names[s.SpanContext().SpanID()] = s.Name()
+	}
+
+	// the request, the job it queued and the redis commands of both are one trace
+	parents := map[string]string{}
+	for _, s := range snapshots {
+		if s.SpanContext().TraceID() != snapshots[0].SpanContext().TraceID() {
+			t.Errorf("span %q is in trace %s, want %s", s.Name(), s.SpanContext().TraceID(), snapshots[0].SpanContext().TraceID())
+		}
+		parent := names[s.Parent().SpanID()]
+		if strings.HasPrefix(s.Name(), "redis ") {
+			parents["redis under "+parent] = parent
+			continue
+		}
+		parents[s.Name()] = parent
+	}
+	want := map[string]string{
+		"POST /scan/image":                  "",
+		"enqueue scan_artifact":             "POST /scan/image",
+		"job scan_artifact":                 "enqueue scan_artifact",
+		"scan":                              "job scan_artifact",
+		"save report":                       "scan",
+		"redis under save report":           "save report",
+		"redis under enqueue scan_artifact": "enqueue scan_artifact",
+		"redis under job scan_artifact":     "job scan_artifact",
+	}
+	for name, parent := range want {
+		if got, ok := parents[name]; !ok || got != parent {
+			t.Errorf("span %q has parent %q, want %q", name, got, parent)
+		}
+	}
+	if len(parents) != len(want) {
+		t.Errorf("unexpected spans %q", parents)
+	}
+}
+
+type blockingController struct {
+	scanner.Controller
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c blockingController) Scan(ctx context.Context, scanJobID, project, imageRef, platform, engine string) error {
+	close(c.started)
+	<-c.release
+	return nil
+}
+
+// A reload waits for running scans without the worker looking dead.
+func TestReloadAlive(t *testing.T) {
+	e, pool := newTestEnqueuerPool(t, project.NewSet())
+	log := logger.NewAppLogger("fatal")
+	log.InitLogger()
+	c := blockingController{started: make(chan struct{}), release: make(chan struct{})}
+	w := NewWorker(pool, c, nil, 1, log)
+	w.Start()
+	defer w.Stop()
+
+	if _, err := e.Enqueue(context.Background(), "", "alpine:3.19", ""); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-c.started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("scan did not start")
+	}
+
+	reloaded := make(chan struct{})
+	go func() {
+		w.Reload()
+		close(reloaded)
+	}()
+	for i := 0; i < 5; i++ {
+		time.Sleep(20 * time.Millisecond)
+		if err := w.Alive(context.Background()); err != nil {
+			t.Errorf("Alive() during reload = %v", err)
+		}
+	}
+	select {
+	case <-reloaded:
+		t.Error("Reload() returned before the running scan finished")
+	default:
+	}
+
+	close(c.release)
+	<-reloaded
+	for deadline := time.Now().Add(10 * time.Second); w.Alive(context.Background()) != nil; time.Sleep(20 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Alive() after reload = %v", w.Alive(context.Background()))
+		}
+	}
+}
+
+type credentialsCrawler struct {
+	Crawler
+}
+
+func (credentialsCrawler) Schedules() map[string]string { return nil }
+
+func (credentialsCrawler) Credentials(target string) (tc.Credentials, bool) {
+	return tc.Credentials{Username: "robot", Password: "s3cret"}, target == "internal"
+}
+
+type credentialsController struct {
+	scanner.Controller
+	creds chan tc.Credentials
+}
+
+func (c credentialsController) Scan(ctx context.Context, scanJobID, project, imageRef, platform, engine string) error {
+	creds, _ := tc.CredentialsFrom(ctx)
+	c.creds <- creds
+	return nil
+}
+
+// Scans of crawled images pull with the credentials of their target.
+func TestScanCrawledCredentials(t *testing.T) {
+	e, pool := newTestEnqueuerPool(t, project.NewSet())
+	log := logger.NewAppLogger("fatal")
+	log.InitLogger()
+	c := credentialsController{creds: make(chan tc.Credentials, 3)}
+	w := NewWorker(pool, c, credentialsCrawler{}, 1, log)
+	w.Start()
+	defer w.Stop()
+
+	ctx := context.Background()
+	if _, err := e.EnqueueCrawled(ctx, "", "registry.example.com/team/app:1.0", "internal"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.EnqueueCrawled(ctx, "", "registry.example.com/team/app:1.0", "removed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Enqueue(ctx, "", "alpine:3.19", ""); err != nil {
+		t.Fatal(err)
+	}
+	var users []string
+	for i := 0; i < 3; i++ {
+		select {
+		case creds := <-c.creds:
+			users = append(users, creds.Username)
+		case <-time.After(10 * time.Second):
+			t.Fatal("scan did not run")
+		}
+	}
+	sort.Strings(users)
+	if strings.Join(users, ",") != ",,robot" {
+		t.Errorf("scans ran as %q", users)
+	}
+}
@@ -0,0 +1,166 @@
+package rbac
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/trivy-web-dash/apikey"
+	"github.com/trivy-web-dash/pkg/auth"
+	"github.com/trivy-web-dash/types"
+	"github.com/trivy-web-dash/util"
+)
+
+const (
+	Viewer  = "viewer"
+	Scanner = "scanner"
+	Admin   = "admin"
+)
+
+// roleScope maps roles onto the ranked API key scopes.
+var roleScope = map[string]string{
+	Viewer:  apikey.ScopeRead,
+	Scanner: apikey.ScopeScan,
+	Admin:   apikey.ScopeAdmin,
+}
+
+// Grant gives the viewer or scanner role on images matching any of Images to
+// a subject, which is "group:<sso group>", "user:<email or name>" or
+// "key:<api key id>".
+type Grant struct {
+	Subject string   `json:"subject"`
+	Role    string   `json:"role"`
+	Images  []string `json:"images"`
+}
+
+// Policy holds the grants. A nil policy allows everything, which is the
+// behaviour without an RBAC file.
+type Policy struct {
+	grants []Grant
+}
+
+func NewPolicy(grants ...Grant) *Policy {
+	return &Policy{grants: grants}
+}
+
+// Load reads a JSON array of grants.
+func Load(path string) (*Policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var grants []Grant
+	if err := json.Unmarshal(b, &grants); err != nil {
+		return nil, fmt.Errorf("parsing rbac file %s: %w", path, err)
+	}
+	for _, g := range grants {
+		switch {
+		case !ValidSubject(g.Subject):
+			return nil, fmt.Errorf("rbac file %s: invalid subject %q", path, g.Subject)
+		case roleScope[g.Role] == "" || g.Role == Admin:
+			// admin is the global scope, a grant on some images could not take effect
+			return nil, fmt.Errorf("rbac file %s: invalid role %q for %s", path, g.Role, g.Subject)
+		case len(g.Images) == 0:
+			return nil, fmt.Errorf("rbac file %s: grant for %s has no images", path, g.Subject)
+		}
+	}
+	return NewPolicy(grants...), nil
+}
+
+// Allowed reports whether p holds role on image. Principals with the global
+// admin scope are allowed everything, everyone else needs a matching grant.
+// The principal's scope still caps what a grant can give.
+func (p *Policy) Allowed(principal auth.Principal, role, image string) bool {
+	if !apikey.Allows(principal.Scope, roleScope[role]) {
+		return false
+	}
+	if p == nil || principal.Scope == apikey.ScopeAdmin {
+		return true
+	}
+
+	for _, g := range p.grants {
+		if !apikey.Allows(roleScope[g.Role], roleScope[role]) || !Matches(g.Subject, principal) {
+			continue
+		}
+		for _, pattern := range g.Images {
+			if util.MatchImage(pattern, image) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidSubject reports whether subject is "group:", "user:" or "key:" followed
+// by a name.
+func ValidSubject(subject string) bool {
+	kind, name, _ := strings.Cut(subject, ":")
+	return name != "" && (kind == "group" || kind == "user" || kind == "key")
+}
+
+// Matches reports whether subject names the principal or one of its groups.
+func Matches(subject string, p auth.Principal) bool {
+	kind, name, _ := strings.Cut(subject, ":")
+	switch kind {
+	case "key":
+		return p.KeyID != "" && name == p.KeyID
+	case "user":
+		return p.KeyID == "" && (name == p.Email || name == p.Name)
+	case "group":
+		for _, g := range p.Groups {
+			if g == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var (
+	mu     sync.RWMutex
+	policy *Policy
+)
+
+// SetPolicy installs the policy used by Can. nil allows everything.
+func SetPolicy(p *Policy) {
+	mu.Lock()
+	defer mu.Unlock()
+	policy = p
+}
+
+func GetPolicy() *Policy {
+	mu.RLock()
+	defer mu.RUnlock()
+	return policy
+}
+
+// Can reports whether the principal of the request holds role on image.
+func Can(c *gin.Context, role, image string) bool {
+	p, ok := auth.GetPrincipal(c)
+	if !ok {
+		return false
+	}
+	return GetPolicy().Allowed(p, role, image)
+}
+
+// CanView reports whether the request may view report r, looked up as image.
+// Reports looked up by bare digest are checked against the image they belong to.
+func CanView(c *gin.Context, image string, r types.Report) bool {
+	image = strings.TrimPrefix(image, "/")
+	if strings.HasPrefix(image, "sha256:") {
+		image = r.ArtifactKey()
+	}
+	return Can(c, Viewer, image)
+}
+
+// Visible returns a filter of the images the request may view.
+func Visible(c *gin.Context) func(image string) bool {
+	return func(image string) bool {
+		return Can(c, Viewer, image)
+	}
+}
@@ -0,0 +1,114 @@
+package rbac
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/trivy-web-dash/apikey"
+	"github.com/trivy-web-dash/pkg/auth"
+)
+
+func TestAllowed(t *testing.T) {
+	p := NewPolicy(
+		Grant{Subject: "group:payments", Role: Scanner, Images: []string{"registry.example.com/payments/*"}},
+		Grant{Subject: "group:eng", Role: Viewer, Images: []string{"registry.example.com/*"}},
+		Grant{Subject: "user:ada@example.com", Role: Scanner, Images: []string{"registry.example.com/ada/*"}},
+		Grant{Subject: "key:k1", Role: Scanner, Images: []string{"alpine:*", "busybox:*"}},
+	)
+
+	payments := auth.Principal{Name: "Bob", Scope: apikey.ScopeScan, Groups: []string{"eng", "payments"}}
+	ada := auth.Principal{Name: "Ada", Email: "ada@example.com", Scope: apikey.ScopeScan}
+	key := auth.Principal{Name: "ci", Scope: apikey.ScopeScan, KeyID: "k1"}
+	readKey := auth.Principal{Name: "ci", Scope: apikey.ScopeRead, KeyID: "k1"}
+	admin := auth.Principal{Name: "admin", Scope: apikey.ScopeAdmin}
+
+	tests := []struct {
+		name      string
+		principal auth.Principal
+		role      string
+		image     string
+		want      bool
+	}{
+		{"group grant", payments, Scanner, "registry.example.com/payments/api:1.0", true},
+		{"group grant outside its images", payments, Scanner, "registry.example.com/search/api:1.0", false},
+		{"lower role of another group", payments, Viewer, "registry.example.com/search/api:1.0", true},
+		{"role above the grant", payments, Admin, "registry.example.com/payments/api:1.0", false},
+		{"admin role needs the admin scope", ada, Admin, "registry.example.com/ada/app:1.0", false},
+		{"user grant within scope", ada, Scanner, "registry.example.com/ada/app:1.0", true},
+		{"key grant", key, Scanner, "busybox:1.36", true},
+		{"key grant other image", key, Scanner, "nginx:1.25", false},
+		{"key grant capped by scope", readKey, Scanner, "alpine:3.19", false},
+		{"key grant viewer within scope", readKey, Viewer, "alpine:3.19", true},
+		{"admin scope", admin, Admin, "anything:1", true},
+		{"unknown principal", auth.Principal{Name: "x", Scope: apikey.ScopeScan}, Viewer, "alpine:3.19", false},
+	}
+	for _, tt := range tests {
+		if got := p.Allowed(tt.principal, tt.role, tt.image); got != tt.want {
+			t.Errorf("%s: Allowed() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	var none *Policy
+	if !none.Allowed(payments, Scanner, "nginx:1.25") || none.Allowed(readKey, Scanner, "nginx:1.25") {
+		t.Error("nil policy must allow everything within the principal's scope")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	sso := auth.Principal{Name: "Ada", Email: "ada@example.com", Groups: []string{"eng"}}
+	key := auth.Principal{Name: "ada@example.com", KeyID: "k1"}
+
+	tests := []struct {
+		subject   string
+		principal auth.Principal
+		want      bool
+	}{
+		{"user:ada@example.com", sso, true},
+		{"user:Ada", sso, true},
+		{"group:eng", sso, true},
+		{"group:ops", sso, false},
+		{"key:k1", key, true},
+		{"key:k2", key, false},
+		// a key named like a user is not that user
+		{"user:ada@example.com", key, false},
+		{"key:", sso, false},
+		{"team:eng", sso, false},
+	}
+	for _, tt := range tests {
+		if got := Matches(tt.subject, tt.principal); got != tt.want {
+			t.Errorf("Matches(%q, %+v) = %v, want %v", tt.subject, tt.principal, got, tt.want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	p, err := Load(write("ok.json", `[{"subject": "group:eng", "role": "viewer", "images": ["*"]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Allowed(auth.Principal{Scope: apikey.ScopeRead, Groups: []string{"eng"}}, Viewer, "alpine:3.19") {
+		t.Error("loaded grant not applied")
+	}
+
+	for name, content := range map[string]string{
+		"subject.json": `[{"subject": "eng", "role": "viewer", "images": ["*"]}]`,
+		"role.json":    `[{"subject": "group:eng", "role": "owner", "images": ["*"]}]`,
+		"admin.json":   `[{"subject": "group:eng", "role": "admin", "images": ["registry.example.com/*"]}]`,
+		"images.json":  `[{"subject": "group:eng", "role": "viewer"}]`,
+		"broken.json":  `{`,
+	} {
+		if _, err := Load(write(name, content)); err == nil {
+			t.Errorf("Load(%s) succeeded", name)
+		}
+	}
+}
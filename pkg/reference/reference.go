@@ -0,0 +1,87 @@
+package reference
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	defaultRegistry = "docker.io"
+	defaultTag      = "latest"
+)
+
+// Reference is a parsed image reference such as
+// "registry.example.com/team/app:1.2@sha256:...".
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// Parse splits an image reference into its parts, normalising Docker Hub names
+// so that "alpine" and "docker.io/library/alpine:latest" compare equal.
+func Parse(ref string) (Reference, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return Reference{}, errors.New("empty image reference")
+	}
+
+	var r Reference
+	if i := strings.Index(ref, "@"); i >= 0 {
+		r.Digest = ref[i+1:]
+		ref = ref[:i]
+	}
+
+	if i := strings.LastIndex(ref, ":"); i >= 0 && !strings.Contains(ref[i+1:], "/") {
+		r.Tag = ref[i+1:]
+		ref = ref[:i]
+	}
+
+	r.Registry = defaultRegistry
+	if i := strings.Index(ref, "/"); i >= 0 {
+		first := ref[:i]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			r.Registry = first
+			ref = ref[i+1:]
+		}
+	}
+	r.Registry = NormalizeRegistry(r.Registry)
+	if r.Registry == defaultRegistry && !strings.Contains(ref, "/") {
+		ref = "library/" + ref
+	}
+	r.Repository = ref
+
+	if r.Repository == "" {
+		return Reference{}, errors.New("image reference has no repository")
+	}
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = defaultTag
+	}
+	return r, nil
+}
+
+// NormalizeRegistry maps the aliases of Docker Hub to docker.io.
+func NormalizeRegistry(registry string) string {
+	switch registry {
+	case "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return defaultRegistry
+	}
+	return registry
+}
+
+// Name is the registry and repository without tag or digest.
+func (r Reference) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+func (r Reference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
@@ -0,0 +1,260 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	pageSize      = 100
+	jsonMediaType = "application/json"
+	// requestTimeout bounds each registry request, including reading its body,
+	// so a registry that stops answering cannot stall a crawl or a scan request.
+	requestTimeout = 30 * time.Second
+)
+
+// httpClient is shared by all registry clients to reuse their connections.
+var httpClient = &http.Client{Timeout: requestTimeout}
+
+// Client talks to the catalog and tag list endpoints of an OCI Distribution
+// registry. Credentials are sent as basic auth, or exchanged for a bearer token
+// when the registry answers with a token challenge.
+type Client struct {
+	baseURL  string
+	username string
+	password string
+	http     *http.Client
+
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+// NewClient returns a client for host, e.g. "registry.example.com:5000". Plain
+// http is only used when insecure is set.
+func NewClient(host, username, password string, insecure bool) *Client {
+	scheme := "https"
+	if insecure {
+		scheme = "http"
+	}
+	return &Client{
+		baseURL:  scheme + "://" + host,
+		username: username,
+		password: password,
+		http:     httpClient,
+		tokens:   map[string]string{},
+	}
+}
+
+// Catalog lists every repository of the registry.
+func (c *Client) Catalog(ctx context.Context) ([]string, error) {
+	var repos []string
+	next := fmt.Sprintf("/v2/_catalog?n=%d", pageSize)
+	for next != "" {
+		var page struct {
+			Repositories []string `json:"repositories"`
+		}
+		var err error
+		if next, err = c.getJSON(ctx, next, jsonMediaType, &page); err != nil {
+			return nil, xerrors.Errorf("listing catalog: %w", err)
+		}
+		repos = append(repos, page.Repositories...)
+	}
+	return repos, nil
+}
+
+// Tags lists the tags of repository.
+func (c *Client) Tags(ctx context.Context, repository string) ([]string, error) {
+	var tags []string
+	next := fmt.Sprintf("/v2/%s/tags/list?n=%d", repository, pageSize)
+	for next != "" {
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		var err error
+		if next, err = c.getJSON(ctx, next, jsonMediaType, &page); err != nil {
+			return nil, xerrors.Errorf("listing tags of %s: %w", repository, err)
+		}
+		tags = append(tags, page.Tags...)
+	}
+	return tags, nil
+}
+
+// getJSON decodes the response of path into v and returns the path of the next
+// page from the Link header, if any.
+func (c *Client) getJSON(ctx context.Context, path, accept string, v interface{}) (string, error) {
+	resp, err := c.get(ctx, path, accept, "")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+			return "", xerrors.Errorf("unauthorized: %s", resp.Status)
+		}
+		resp.Body.Close()
+		if resp, err = c.authorized(ctx, path, accept, challenge); err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", xerrors.Errorf("GET %s: %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", xerrors.Errorf("decoding %s: %w", path, err)
+	}
+	return nextLink(resp.Header.Get("Link")), nil
+}
+
+// authorized repeats a request with a token for challenge. A cached token the
+// registry turns down, as once it expired, is replaced by a fresh one.
+func (c *Client) authorized(ctx context.Context, path, accept, challenge string) (*http.Response, error) {
+	for fresh := false; ; fresh = true {
+		token, cached, err := c.token(ctx, challenge, fresh)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.get(ctx, path, accept, token)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized || !cached {
+			return resp, err
+		}
+		resp.Body.Close()
+	}
+}
+
+func (c *Client) get(ctx context.Context, path, accept, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	switch {
+	case token != "":
+		req.Header.Set("Authorization", "Bearer "+token)
+	case c.username != "":
+		req.SetBasicAuth(c.username, c.password)
+	}
+	return c.http.Do(req)
+}
+
+// token answers a bearer challenge such as
+// Bearer realm="https://auth.example.com/token",service="registry",scope="registry:catalog:*"
+// and caches the token per scope. It reports whether the token came from the
+// cache, which fresh bypasses.
+func (c *Client) token(ctx context.Context, challenge string, fresh bool) (string, bool, error) {
+	params := parseChallenge(challenge[len("bearer "):])
+	realm := params["realm"]
+	if realm == "" {
+		return "", false, xerrors.Errorf("bearer challenge without realm: %s", challenge)
+	}
+
+	c.mu.Lock()
+	token, ok := c.tokens[params["scope"]]
+	c.mu.Unlock()
+	if ok && !fresh {
+		return token, true, nil
+	}
+
+	u, err := url.Parse(realm)
+	if err != nil {
+		return "", false, xerrors.Errorf("invalid token realm %s: %w", realm, err)
+	}
+	q := u.Query()
+	for _, k := range []string{"service", "scope"} {
+		if params[k] != "" {
+			q.Set(k, params[k])
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", false, err
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", false, xerrors.Errorf("requesting token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", false, xerrors.Errorf("requesting token: %s", resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", false, xerrors.Errorf("decoding token: %w", err)
+	}
+	token = body.Token
+	if token == "" {
+		token = body.AccessToken
+	}
+
+	c.mu.Lock()
+	c.tokens[params["scope"]] = token
+	c.mu.Unlock()
+	return token, false, nil
+}
+
+func parseChallenge(s string) map[string]string {
+	params := map[string]string{}
+	for s != "" {
+		eq := strings.Index(s, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.Index(s[1:], `"`)
+			if end < 0 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+		} else if comma := strings.Index(s, ","); comma >= 0 {
+			value, s = s[:comma], s[comma:]
+		} else {
+			value, s = s, ""
+		}
+		params[key] = value
+		s = strings.TrimLeft(s, ", ")
+	}
+	return params
+}
+
+// nextLink extracts the target of a Link header such as
+// </v2/_catalog?last=b&n=100>; rel="next".
+func nextLink(header string) string {
+	if !strings.Contains(header, `rel="next"`) {
+		return ""
+	}
+	start, end := strings.Index(header, "<"), strings.Index(header, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	link := header[start+1 : end]
+	// some registries return absolute URLs
+	if u, err := url.Parse(link); err == nil && u.IsAbs() {
+		return u.RequestURI()
+	}
+	return link
+}
@@ -0,0 +1,191 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testRegistry serves a paged catalog, tags and an image index behind a bearer
+// token challenge, like Docker Hub and Harbor do.
+func testRegistry(t *testing.T) (string, *int) {
+	t.Helper()
+	tokens := 0
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if user != "robot" || pass != "s3cret" || r.URL.Query().Get("service") != "registry" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		tokens++
+		fmt.Fprintf(w, `{"token": "tok-%s"}`, r.URL.Query().Get("scope"))
+	})
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		scope := "registry:catalog:*"
+		if r.URL.Path != "/v2/_catalog" {
+			scope = "repository:team/app:pull"
+		}
+		if r.Header.Get("Authorization") != "Bearer tok-"+scope {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="%s"`, srv.URL, scope))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v2/_catalog":
+			if r.URL.Query().Get("last") == "" {
+				w.Header().Set("Link", `</v2/_catalog?last=team%2Fapp&n=100>; rel="next"`)
+				fmt.Fprint(w, `{"repositories": ["library/alpine", "team/app"]}`)
+				return
+			}
+			fmt.Fprint(w, `{"repositories": ["team/worker"]}`)
+		case "/v2/team/app/tags/list":
+			fmt.Fprint(w, `{"name": "team/app", "tags": ["1.0", "1.1"]}`)
+		case "/v2/team/app/manifests/1.1":
+			if !strings.Contains(r.Header.Get("Accept"), "image.index") {
+				w.WriteHeader(http.StatusNotAcceptable)
+				return
+			}
+			fmt.Fprint(w, `{"manifests": [
+				{"platform": {"os": "linux", "architecture": "arm64", "variant": "v8"}},
+				{"platform": {"os": "linux", "architecture": "amd64"}},
+				{"platform": {"os": "unknown", "architecture": "unknown"}},
+				{"platform": {"os": "linux", "architecture": "amd64"}}
+			]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://"), &tokens
+}
+
+func TestClient(t *testing.T) {
+	host, tokens := testRegistry(t)
+	c := NewClient(host, "robot", "s3cret", true)
+	ctx := context.Background()
+
+	repos, err := c.Catalog(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(repos, " ") != "library/alpine team/app team/worker" {
+		t.Errorf("Catalog() = %q", repos)
+	}
+
+	tags, err := c.Tags(ctx, "team/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(tags, " ") != "1.0 1.1" {
+		t.Errorf("Tags() = %q", tags)
+	}
+
+	platforms, err := c.Platforms(ctx, "team/app", "1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(platforms, " ") != "linux/amd64 linux/arm64/v8" {
+		t.Errorf("Platforms() = %q", platforms)
+	}
+	if *tokens != 2 {
+		t.Errorf("requested %d tokens, want one per scope", *tokens)
+	}
+
+	if _, err := c.Tags(ctx, "team/missing"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Tags() of a missing repository = %v", err)
+	}
+	if _, err := NewClient(host, "robot", "wrong", true).Catalog(ctx); err == nil {
+		t.Error("Catalog() with wrong credentials succeeded")
+	}
+}
+
+// A registry that stops accepting a cached token, as once it expired, gets a
+// fresh one.
+func TestClientTokenExpiry(t *testing.T) {
+	issued, valid := 0, ""
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		issued++
+		valid = fmt.Sprintf("tok-%d", issued)
+		fmt.Fprintf(w, `{"token": "%s"}`, valid)
+	})
+	mux.HandleFunc("/v2/team/app/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+valid {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:team/app:pull"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"name": "team/app", "tags": ["1.0"]}`)
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	c := NewClient(strings.TrimPrefix(srv.URL, "http://"), "", "", true)
+	for i := 0; i < 2; i++ {
+		if _, err := c.Tags(context.Background(), "team/app"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if issued != 1 {
+		t.Errorf("requested %d tokens for one scope", issued)
+	}
+
+	valid = "rotated"
+	if _, err := c.Tags(context.Background(), "team/app"); err != nil {
+		t.Errorf("Tags() with an expired token = %v", err)
+	}
+	if issued != 2 {
+		t.Errorf("requested %d tokens, want a fresh one after expiry", issued)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	c := NewClient("registry.example.com", "", "", false)
+	if c.http == http.DefaultClient || c.http.Timeout != requestTimeout {
+		t.Errorf("registry requests are not bounded: %+v", c.http)
+	}
+	if !strings.HasPrefix(c.baseURL, "https://") {
+		t.Errorf("baseURL = %s, want https", c.baseURL)
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	got := parseChallenge(`realm="https://auth.example.com/token",service="registry.example.com",scope="repository:team/app:pull,push"`)
+	want := map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry.example.com",
+		"scope":   "repository:team/app:pull,push",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{`</v2/_catalog?last=b&n=100>; rel="next"`, "/v2/_catalog?last=b&n=100"},
+		{`<https://registry.example.com/v2/_catalog?last=b&n=100>; rel="next"`, "/v2/_catalog?last=b&n=100"},
+		{`</v2/_catalog?last=b&n=100>; rel="prev"`, ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := nextLink(tt.header); got != tt.want {
+			t.Errorf("nextLink(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/robfig/cron"
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/pkg/queue"
+	tc "github.com/trivy-web-dash/pkg/trivy"
+	"github.com/trivy-web-dash/util"
+)
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// Target is a registry to onboard. Include and Exclude are image patterns
+// matched against repository names, an empty Include selects every repository.
+// LatestTags keeps the N highest tags of each repository, 0 keeps all.
+// Schedule is an optional cron spec with a seconds field, e.g. "0 0 2 * * *".
+// Scans are queued in Project, the default project when empty.
+type Target struct {
+	Name       string   `json:"name"`
+	Host       string   `json:"host"`
+	Username   string   `json:"username,omitempty"`
+	Password   string   `json:"password,omitempty"`
+	Insecure   bool     `json:"insecure,omitempty"`
+	Include    []string `json:"include,omitempty"`
+	Exclude    []string `json:"exclude,omitempty"`
+	LatestTags int      `json:"latestTags,omitempty"`
+	Schedule   string   `json:"schedule,omitempty"`
+	Project    string   `json:"project,omitempty"`
+}
+
+// Load reads a JSON array of targets.
+func Load(path string) ([]Target, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []Target
+	if err := json.Unmarshal(b, &targets); err != nil {
+		return nil, fmt.Errorf("parsing registry file %s: %w", path, err)
+	}
+
+	seen := map[string]bool{}
+	for _, t := range targets {
+		switch {
+		case !validName.MatchString(t.Name):
+			return nil, fmt.Errorf("registry file %s: invalid target name %q", path, t.Name)
+		case seen[t.Name]:
+			return nil, fmt.Errorf("registry file %s: duplicate target %s", path, t.Name)
+		case t.Host == "":
+			return nil, fmt.Errorf("registry file %s: target %s without host", path, t.Name)
+		}
+		if t.Schedule != "" {
+			if _, err := cron.Parse(t.Schedule); err != nil {
+				return nil, fmt.Errorf("registry file %s: target %s: invalid schedule: %w", path, t.Name, err)
+			}
+		}
+		seen[t.Name] = true
+	}
+	return targets, nil
+}
+
+// Selects reports whether repository passes the include and exclude patterns.
+func (t Target) Selects(repository string) bool {
+	for _, p := range t.Exclude {
+		if util.MatchImage(p, repository) {
+			return false
+		}
+	}
+	if len(t.Include) == 0 {
+		return true
+	}
+	for _, p := range t.Include {
+		if util.MatchImage(p, repository) {
+			return true
+		}
+	}
+	return false
+}
+
+// Crawler enqueues scans for the images of configured registries.
+type Crawler struct {
+	mu       sync.RWMutex
+	targets  map[string]Target
+	enqueuer queue.Enqueuer
+	log      logger.Logger
+}
+
+func NewCrawler(targets []Target, e queue.Enqueuer, l logger.Logger) *Crawler {
+	c := &Crawler{enqueuer: e, log: l}
+	c.Replace(targets)
+	return c
+}
+
+// Replace swaps in targets, as after reloading the registry file. New
+// schedules only apply once the worker is reloaded.
+func (c *Crawler) Replace(targets []Target) {
+	m := map[string]Target{}
+	for _, t := range targets {
+		m[t.Name] = t
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.targets = m
+}
+
+func (c *Crawler) Targets() []Target {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	targets := make([]Target, 0, len(c.targets))
+	for _, t := range c.targets {
+		targets = append(targets, t)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Name < targets[j].Name })
+	return targets
+}
+
+func (c *Crawler) Schedules() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	schedules := map[string]string{}
+	for name, t := range c.targets {
+		if t.Schedule != "" {
+			schedules[name] = t.Schedule
+		}
+	}
+	return schedules
+}
+
+// Credentials returns the credentials of the named target, with which the
+// scans its crawls queue pull their images.
+func (c *Crawler) Credentials(name string) (tc.Credentials, bool) {
+	t, ok := c.Target(name)
+	if !ok || t.Username == "" {
+		return tc.Credentials{}, false
+	}
+	return tc.Credentials{Username: t.Username, Password: t.Password}, true
+}
+
+func (c *Crawler) Target(name string) (Target, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	t, ok := c.targets[name]
+	return t, ok
+}
+
+// Crawl lists the repositories and tags of the named target and queues a scan
+// for every selected image. A repository whose tags cannot be listed is
+// skipped rather than failing the crawl, and a crawl that fills the quota of
+// its project stops queueing without failing.
+func (c *Crawler) Crawl(ctx context.Context, name string) error {
+	t, ok := c.Target(name)
+	if !ok {
+		return fmt.Errorf("unknown registry target %s", name)
+	}
+
+	client := NewClient(t.Host, t.Username, t.Password, t.Insecure)
+	repos, err := client.Catalog(ctx)
+	if err != nil {
+		return err
+	}
+
+	queued := 0
+	for _, repo := range repos {
+		if !t.Selects(repo) {
+			continue
+		}
+
+		tags, err := client.Tags(ctx, repo)
+		if err != nil {
+			c.log.Errorf("crawling %s : %v", t.Name, err)
+			continue
+		}
+
+		for _, tag := range Latest(tags, t.LatestTags) {
+			image := t.Host + "/" + repo + ":" + tag
+			_, err := c.enqueuer.EnqueueCrawled(ctx, t.Project, image, t.Name)
+			var quota *queue.QuotaError
+			if errors.As(err, &quota) {
+				c.log.Infof("crawl of registry %s stopped after %d scans : %v", t.Name, queued, err)
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("queueing %s: %w", image, err)
+			}
+			queued++
+		}
+	}
+
+	c.log.Infof("crawled registry %s : %d repositories, %d scans queued", t.Name, len(repos), queued)
+	return nil
+}
+
+// Latest returns the n highest tags, comparing runs of digits numerically so
+// that "1.10" sorts above "1.9". The registry API carries no push dates, so
+// this is the best available notion of latest without fetching every manifest.
+func Latest(tags []string, n int) []string {
+	sorted := append([]string(nil), tags...)
+	sort.SliceStable(sorted, func(i, j int) bool { return compareVersions(sorted[i], sorted[j]) > 0 })
+	if n > 0 && len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+var chunk = regexp.MustCompile(`\d+|\D+`)
+
+func compareVersions(a, b string) int {
+	ca, cb := chunk.FindAllString(a, -1), chunk.FindAllString(b, -1)
+	for i := 0; i < len(ca) && i < len(cb); i++ {
+		na, errA := strconv.ParseUint(ca[i], 10, 64)
+		nb, errB := strconv.ParseUint(cb[i], 10, 64)
+		switch {
+		case errA == nil && errB == nil:
+			if na != nb {
+				if na < nb {
+					return -1
+				}
+				return 1
+			}
+		case ca[i] != cb[i]:
+			return strings.Compare(ca[i], cb[i])
+		}
+	}
+	return len(ca) - len(cb)
+}
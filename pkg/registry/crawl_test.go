@@ -0,0 +1,154 @@
+package registry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+
+	redisx "github.com/trivy-web-dash/pkg/db/redis"
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/pkg/project"
+	"github.com/trivy-web-dash/pkg/queue"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	targets, err := Load(write("ok.json", `[
+		{"name": "internal", "host": "registry.example.com", "include": ["team/*"], "schedule": "0 0 2 * * *", "project": "payments"},
+		{"name": "hub", "host": "registry-1.docker.io", "latestTags": 3}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 || targets[0].Project != "payments" || targets[1].LatestTags != 3 {
+		t.Errorf("Load() = %+v", targets)
+	}
+
+	tests := []struct {
+		content string
+		err     string
+	}{
+		{`[{"name": "bad name", "host": "r.example.com"}]`, "invalid target name"},
+		{`[{"name": "a", "host": "r.example.com"}, {"name": "a", "host": "s.example.com"}]`, "duplicate target a"},
+		{`[{"name": "a"}]`, "without host"},
+		{`[{"name": "a", "host": "r.example.com", "schedule": "every day"}]`, "invalid schedule"},
+		{`{}`, "parsing registry file"},
+	}
+	for _, tt := range tests {
+		if _, err := Load(write("bad.json", tt.content)); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Load(%s) error = %v, want %q", tt.content, err, tt.err)
+		}
+	}
+}
+
+func TestSelects(t *testing.T) {
+	target := Target{Include: []string{"team/*", "library/alpine"}, Exclude: []string{"team/legacy-*"}}
+	tests := []struct {
+		repo string
+		want bool
+	}{
+		{"team/app", true},
+		{"team/sub/app", true},
+		{"team/legacy-app", false},
+		{"library/alpine", true},
+		{"library/nginx", false},
+	}
+	for _, tt := range tests {
+		if got := target.Selects(tt.repo); got != tt.want {
+			t.Errorf("Selects(%q) = %v, want %v", tt.repo, got, tt.want)
+		}
+	}
+	if !(Target{Exclude: []string{"tmp/*"}}).Selects("library/alpine") {
+		t.Error("an empty include must select every repository")
+	}
+}
+
+func TestLatest(t *testing.T) {
+	tags := []string{"1.9", "1.10", "latest", "1.9.1", "2.0-rc1", "2.0", "1.2"}
+	if got := strings.Join(Latest(tags, 3), " "); got != "latest 2.0-rc1 2.0" {
+		t.Errorf("Latest(3) = %s", got)
+	}
+	if got := Latest(tags, 0); len(got) != len(tags) {
+		t.Errorf("Latest(0) dropped tags: %q", got)
+	}
+	if got := strings.Join(Latest([]string{"v1.9", "v1.10", "v1.2"}, 2), " "); got != "v1.10 v1.9" {
+		t.Errorf("Latest() compares numbers as text: %s", got)
+	}
+}
+
+func TestCrawl(t *testing.T) {
+	host, _ := testRegistry(t)
+	redis := miniredis.RunT(t)
+	pool, err := redisx.NewPool(redis.Addr(), "", 0, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := redisx.NewStore(pool)
+	log := logger.NewAppLogger("fatal")
+	log.InitLogger()
+
+	projects := project.NewSet(project.Project{Name: "payments"}, project.Project{Name: "small", MaxActiveScans: 1})
+	target := Target{Name: "internal", Host: host, Username: "robot", Password: "s3cret", Insecure: true, Include: []string{"team/*"}, Project: "payments"}
+	quota := target
+	quota.Name, quota.Project = "quota", "small"
+	c := NewCrawler([]Target{target, quota}, queue.NewEnqueuer(pool, store, projects), log)
+	ctx := context.Background()
+
+	// team/worker has no tags listed and is skipped
+	if err := c.Crawl(ctx, "internal"); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := store.GetAllJobStatus("payments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var images []string
+	for _, j := range jobs {
+		images = append(images, j.Image)
+	}
+	sort.Strings(images)
+	if strings.Join(images, " ") != host+"/team/app:1.0 "+host+"/team/app:1.1" {
+		t.Errorf("crawl queued %q", images)
+	}
+
+	// crawls are held to the project's quota like every other scan, filling it
+	// stops the crawl without failing it
+	if err := c.Crawl(ctx, "quota"); err != nil {
+		t.Errorf("crawl beyond the quota: %v", err)
+	}
+	if jobs, _ := store.GetAllJobStatus("small"); len(jobs) != 1 {
+		t.Errorf("crawl queued %d scans beyond a quota of 1", len(jobs))
+	}
+
+	// scans pull with the target's credentials, which the jobs only name
+	if creds, ok := c.Credentials("internal"); !ok || creds.Username != "robot" || creds.Password != "s3cret" {
+		t.Errorf("Credentials(internal) = %+v, %v", creds, ok)
+	}
+	if _, ok := (NewCrawler([]Target{{Name: "hub", Host: "registry-1.docker.io"}}, nil, log)).Credentials("hub"); ok {
+		t.Error("a target without a username has credentials")
+	}
+	queued, err := redis.List(queue.Namespace + ":jobs:scan_artifact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args := strings.Join(queued, " "); !strings.Contains(args, `"registry_target":"internal"`) || strings.Contains(args, "s3cret") {
+		t.Errorf("queued %q", queued)
+	}
+
+	if err := c.Crawl(ctx, "missing"); err == nil {
+		t.Error("crawl of an unknown target succeeded")
+	}
+}
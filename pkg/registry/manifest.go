@@ -0,0 +1,87 @@
+package registry
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/trivy-web-dash/pkg/reference"
+)
+
+var manifestMediaTypes = strings.Join([]string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}, ", ")
+
+type platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+func (p platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// Platforms lists the platforms of a manifest list or image index, sorted. A
+// single-platform manifest yields no platforms.
+func (c *Client) Platforms(ctx context.Context, repository, ref string) ([]string, error) {
+	var manifest struct {
+		Manifests []struct {
+			Platform *platform `json:"platform"`
+		} `json:"manifests"`
+	}
+	if _, err := c.getJSON(ctx, "/v2/"+repository+"/manifests/"+ref, manifestMediaTypes, &manifest); err != nil {
+		return nil, xerrors.Errorf("getting manifest of %s:%s: %w", repository, ref, err)
+	}
+
+	seen := map[string]bool{}
+	var platforms []string
+	for _, m := range manifest.Manifests {
+		// attestation manifests are listed as unknown/unknown
+		if m.Platform == nil || m.Platform.OS == "unknown" || m.Platform.OS == "" {
+			continue
+		}
+		p := m.Platform.String()
+		if !seen[p] {
+			seen[p] = true
+			platforms = append(platforms, p)
+		}
+	}
+	sort.Strings(platforms)
+	return platforms, nil
+}
+
+// resolveTimeout bounds ResolvePlatforms, which runs while an API request waits.
+const resolveTimeout = 5 * time.Second
+
+// ResolvePlatforms looks up the platforms of a public image. Docker Hub
+// images are resolved through registry-1.docker.io.
+func ResolvePlatforms(ctx context.Context, image string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+
+	ref, err := reference.Parse(image)
+	if err != nil {
+		return nil, err
+	}
+
+	host := ref.Registry
+	if host == "docker.io" {
+		host = "registry-1.docker.io"
+	}
+	tag := ref.Digest
+	if tag == "" {
+		tag = ref.Tag
+	}
+	return NewClient(host, "", "", false).Platforms(ctx, ref.Repository, tag)
+}
@@ -0,0 +1,104 @@
+package sbom
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+type Format string
+
+const (
+	CycloneDX Format = "cyclonedx"
+	SPDX      Format = "spdx"
+)
+
+var ErrUnknownFormat = errors.New("unsupported sbom format, expected CycloneDX or SPDX JSON")
+
+// Document is the subset of an uploaded SBOM needed to enqueue and key a scan.
+type Document struct {
+	Format  Format
+	Name    string
+	Version string
+}
+
+// ArtifactName is the key the scan result is stored under, analogous to an
+// image reference: "name:version", or just "name" when no version is given.
+func (d Document) ArtifactName() string {
+	if d.Version == "" {
+		return d.Name
+	}
+	return d.Name + ":" + d.Version
+}
+
+type cycloneDX struct {
+	BOMFormat string `json:"bomFormat"`
+	Metadata  struct {
+		Component struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"component"`
+	} `json:"metadata"`
+}
+
+type spdx struct {
+	SPDXVersion       string   `json:"spdxVersion"`
+	Name              string   `json:"name"`
+	DocumentDescribes []string `json:"documentDescribes"`
+	Packages          []struct {
+		SPDXID      string `json:"SPDXID"`
+		Name        string `json:"name"`
+		VersionInfo string `json:"versionInfo"`
+	} `json:"packages"`
+	Relationships []struct {
+		SPDXElementID      string `json:"spdxElementId"`
+		RelationshipType   string `json:"relationshipType"`
+		RelatedSPDXElement string `json:"relatedSpdxElement"`
+	} `json:"relationships"`
+}
+
+// Parse detects the format of a JSON SBOM and extracts the name and version of
+// the component it describes.
+func Parse(b []byte) (*Document, error) {
+	var c cycloneDX
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, ErrUnknownFormat
+	}
+	if c.BOMFormat == "CycloneDX" {
+		if c.Metadata.Component.Name == "" {
+			return nil, errors.New("cyclonedx sbom has no metadata.component.name")
+		}
+		return &Document{
+			Format:  CycloneDX,
+			Name:    c.Metadata.Component.Name,
+			Version: c.Metadata.Component.Version,
+		}, nil
+	}
+
+	var s spdx
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, ErrUnknownFormat
+	}
+	if !strings.HasPrefix(s.SPDXVersion, "SPDX-") {
+		return nil, ErrUnknownFormat
+	}
+
+	described := s.DocumentDescribes
+	for _, r := range s.Relationships {
+		if r.SPDXElementID == "SPDXRef-DOCUMENT" && r.RelationshipType == "DESCRIBES" {
+			described = append(described, r.RelatedSPDXElement)
+		}
+	}
+	for _, id := range described {
+		for _, p := range s.Packages {
+			if p.SPDXID == id && p.Name != "" {
+				return &Document{Format: SPDX, Name: p.Name, Version: p.VersionInfo}, nil
+			}
+		}
+	}
+
+	if s.Name == "" {
+		return nil, errors.New("spdx sbom has no described package or document name")
+	}
+	return &Document{Format: SPDX, Name: s.Name}, nil
+}
@@ -0,0 +1,83 @@
+package sbom
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		sbom     string
+		want     Document
+		artifact string
+	}{
+		{
+			"cyclonedx",
+			`{"bomFormat": "CycloneDX", "specVersion": "1.5", "metadata": {"component": {"name": "registry.example.com/app", "version": "1.2.0"}}}`,
+			Document{CycloneDX, "registry.example.com/app", "1.2.0"},
+			"registry.example.com/app:1.2.0",
+		},
+		{
+			"cyclonedx without version",
+			`{"bomFormat": "CycloneDX", "metadata": {"component": {"name": "app"}}}`,
+			Document{CycloneDX, "app", ""},
+			"app",
+		},
+		{
+			"spdx documentDescribes",
+			`{"spdxVersion": "SPDX-2.3", "name": "doc", "documentDescribes": ["SPDXRef-app"],
+			  "packages": [{"SPDXID": "SPDXRef-lib", "name": "lib", "versionInfo": "0.1"}, {"SPDXID": "SPDXRef-app", "name": "app", "versionInfo": "2.0"}]}`,
+			Document{SPDX, "app", "2.0"},
+			"app:2.0",
+		},
+		{
+			"spdx relationship",
+			`{"spdxVersion": "SPDX-2.3", "name": "doc",
+			  "packages": [{"SPDXID": "SPDXRef-app", "name": "app", "versionInfo": "3.0"}],
+			  "relationships": [{"spdxElementId": "SPDXRef-DOCUMENT", "relationshipType": "DESCRIBES", "relatedSpdxElement": "SPDXRef-app"}]}`,
+			Document{SPDX, "app", "3.0"},
+			"app:3.0",
+		},
+		{
+			"spdx document name",
+			`{"spdxVersion": "SPDX-2.2", "name": "alpine-3.19", "packages": [{"SPDXID": "SPDXRef-musl", "name": "musl"}]}`,
+			Document{SPDX, "alpine-3.19", ""},
+			"alpine-3.19",
+		},
+	}
+	for _, tt := range tests {
+		d, err := Parse([]byte(tt.sbom))
+		if err != nil {
+			t.Errorf("%s: Parse() = %v", tt.name, err)
+			continue
+		}
+		if *d != tt.want || d.ArtifactName() != tt.artifact {
+			t.Errorf("%s: Parse() = %+v, %s", tt.name, *d, d.ArtifactName())
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		sbom string
+		err  string
+	}{
+		{"not json", `<bom/>`, ErrUnknownFormat.Error()},
+		{"other json", `{"packages": []}`, ErrUnknownFormat.Error()},
+		{"array", `[]`, ErrUnknownFormat.Error()},
+		{"cyclonedx without component", `{"bomFormat": "CycloneDX"}`, "no metadata.component.name"},
+		{"spdx without name", `{"spdxVersion": "SPDX-2.3"}`, "no described package"},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.sbom))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: Parse() error = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+	if _, err := Parse([]byte(`{`)); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Parse() of broken json = %v", err)
+	}
+}
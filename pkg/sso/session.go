@@ -0,0 +1,115 @@
+package sso
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	SessionCookie = "twd_session"
+	stateCookie   = "twd_oidc"
+)
+
+var ErrNoSession = errors.New("no valid session")
+
+// Session is the signed-in user, kept client side in an HMAC signed cookie.
+type Session struct {
+	Subject   string    `json:"sub"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email,omitempty"`
+	Groups    []string  `json:"groups,omitempty"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"exp"`
+}
+
+// authState survives the redirect to the provider and back.
+type authState struct {
+	State     string    `json:"state"`
+	Nonce     string    `json:"nonce"`
+	Verifier  string    `json:"verifier"`
+	Next      string    `json:"next"`
+	ExpiresAt time.Time `json:"exp"`
+}
+
+// signer encodes values as base64(json) "." base64(hmac).
+type signer struct {
+	key []byte
+}
+
+func (s signer) encode(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.mac(payload)), nil
+}
+
+func (s signer) decode(value string, v interface{}) error {
+	payload, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return ErrNoSession
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, s.mac(payload)) {
+		return ErrNoSession
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return ErrNoSession
+	}
+	return json.Unmarshal(b, v)
+}
+
+func (s signer) mac(payload string) []byte {
+	m := hmac.New(sha256.New, s.key)
+	m.Write([]byte(payload))
+	return m.Sum(nil)
+}
+
+// Session returns the valid, unexpired session of r.
+func (p *Provider) Session(r *http.Request) (Session, error) {
+	c, err := r.Cookie(SessionCookie)
+	if err != nil {
+		return Session{}, ErrNoSession
+	}
+
+	var s Session
+	if err := p.signer.decode(c.Value, &s); err != nil {
+		return Session{}, ErrNoSession
+	}
+	if !time.Now().Before(s.ExpiresAt) {
+		return Session{}, ErrNoSession
+	}
+	return s, nil
+}
+
+func (p *Provider) setCookie(w http.ResponseWriter, name, value string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   p.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (p *Provider) clearCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   p.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,268 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"golang.org/x/xerrors"
+
+	"github.com/trivy-web-dash/apikey"
+)
+
+const stateTTL = 10 * time.Minute
+
+var ErrNoRole = errors.New("none of your groups grants access")
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the public URL of /auth/callback.
+	RedirectURL string
+	// GroupsClaim names the ID token claim holding the user's groups.
+	GroupsClaim string
+	// RoleMapping grants a role (read, scan or admin) to members of a group.
+	RoleMapping map[string]string
+	// DefaultRole is given to users without a mapped group, empty denies them.
+	DefaultRole   string
+	SessionSecret string
+	SessionTTL    time.Duration
+}
+
+// ParseRoleMapping parses "group=role,group=role".
+func ParseRoleMapping(s string) (map[string]string, error) {
+	mapping := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		group, role, ok := strings.Cut(pair, "=")
+		if !ok || !apikey.Allows(role, apikey.ScopeRead) {
+			return nil, fmt.Errorf("invalid role mapping %q, expected group=read|scan|admin", pair)
+		}
+		mapping[group] = role
+	}
+	return mapping, nil
+}
+
+// Provider signs users in with OpenID Connect's authorization code flow.
+type Provider struct {
+	config     Config
+	oauth2     oauth2.Config
+	verifier   *oidc.IDTokenVerifier
+	endSession string
+	signer     signer
+	secure     bool
+}
+
+// NewProvider discovers the issuer's endpoints. ctx may carry an
+// oauth2.HTTPClient, which is used for discovery and token requests.
+func NewProvider(ctx context.Context, cfg Config) (*Provider, error) {
+	if cfg.SessionSecret == "" {
+		return nil, errors.New("a session secret is required")
+	}
+	if cfg.DefaultRole != "" && !apikey.Allows(cfg.DefaultRole, apikey.ScopeRead) {
+		return nil, fmt.Errorf("invalid default role %q", cfg.DefaultRole)
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	if cfg.SessionTTL == 0 {
+		cfg.SessionTTL = 8 * time.Hour
+	}
+
+	provider, err := oidc.NewProvider(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, xerrors.Errorf("discovering oidc provider %s: %w", cfg.Issuer, err)
+	}
+
+	var metadata struct {
+		EndSessionEndpoint string `json:"end_session_endpoint"`
+	}
+	if err := provider.Claims(&metadata); err != nil {
+		return nil, xerrors.Errorf("reading oidc provider metadata: %w", err)
+	}
+
+	return &Provider{
+		config: cfg,
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "profile", "email", "groups"},
+		},
+		verifier:   provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		endSession: metadata.EndSessionEndpoint,
+		signer:     signer{key: []byte(cfg.SessionSecret)},
+		secure:     strings.HasPrefix(cfg.RedirectURL, "https://"),
+	}, nil
+}
+
+// Login redirects to the provider, returning to next once signed in.
+func (p *Provider) Login(w http.ResponseWriter, r *http.Request, next string) error {
+	st := authState{
+		State:     randomString(),
+		Nonce:     randomString(),
+		Verifier:  oauth2.GenerateVerifier(),
+		Next:      safeNext(next),
+		ExpiresAt: time.Now().Add(stateTTL),
+	}
+	value, err := p.signer.encode(st)
+	if err != nil {
+		return err
+	}
+	p.setCookie(w, stateCookie, value, st.ExpiresAt)
+
+	authURL := p.oauth2.AuthCodeURL(st.State, oidc.Nonce(st.Nonce), oauth2.S256ChallengeOption(st.Verifier))
+	http.Redirect(w, r, authURL, http.StatusFound)
+	return nil
+}
+
+// Callback completes a login and returns the path to go back to.
+func (p *Provider) Callback(ctx context.Context, w http.ResponseWriter, r *http.Request) (string, error) {
+	c, err := r.Cookie(stateCookie)
+	if err != nil {
+		return "", errors.New("login state missing, start over")
+	}
+	p.clearCookie(w, stateCookie)
+
+	var st authState
+	if err := p.signer.decode(c.Value, &st); err != nil || !time.Now().Before(st.ExpiresAt) {
+		return "", errors.New("login state invalid or expired, start over")
+	}
+	if r.URL.Query().Get("state") != st.State {
+		return "", errors.New("login state mismatch")
+	}
+	if e := r.URL.Query().Get("error"); e != "" {
+		return "", fmt.Errorf("provider returned %s: %s", e, r.URL.Query().Get("error_description"))
+	}
+
+	token, err := p.oauth2.Exchange(ctx, r.URL.Query().Get("code"), oauth2.VerifierOption(st.Verifier))
+	if err != nil {
+		return "", xerrors.Errorf("exchanging code: %w", err)
+	}
+	rawID, ok := token.Extra("id_token").(string)
+	if !ok {
+		return "", errors.New("token response has no id_token")
+	}
+	idToken, err := p.verifier.Verify(ctx, rawID)
+	if err != nil {
+		return "", xerrors.Errorf("verifying id token: %w", err)
+	}
+	if idToken.Nonce != st.Nonce {
+		return "", errors.New("id token nonce mismatch")
+	}
+
+	s, err := p.newSession(idToken)
+	if err != nil {
+		return "", err
+	}
+	value, err := p.signer.encode(s)
+	if err != nil {
+		return "", err
+	}
+	p.setCookie(w, SessionCookie, value, s.ExpiresAt)
+	return st.Next, nil
+}
+
+// Logout ends the local session and returns where to send the browser: the
+// provider's end session endpoint if it has one.
+func (p *Provider) Logout(w http.ResponseWriter, r *http.Request) string {
+	p.clearCookie(w, SessionCookie)
+	if p.endSession == "" {
+		return "/"
+	}
+
+	u, err := url.Parse(p.endSession)
+	if err != nil {
+		return "/"
+	}
+	q := u.Query()
+	q.Set("client_id", p.config.ClientID)
+	if base, err := url.Parse(p.config.RedirectURL); err == nil {
+		q.Set("post_logout_redirect_uri", base.Scheme+"://"+base.Host+"/")
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func (p *Provider) newSession(idToken *oidc.IDToken) (Session, error) {
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return Session{}, xerrors.Errorf("reading id token claims: %w", err)
+	}
+
+	s := Session{
+		Subject:   idToken.Subject,
+		Name:      stringClaim(claims, "name"),
+		Email:     stringClaim(claims, "email"),
+		Groups:    stringsClaim(claims, p.config.GroupsClaim),
+		ExpiresAt: time.Now().Add(p.config.SessionTTL),
+	}
+	if s.Name == "" {
+		s.Name = s.Email
+	}
+	if s.Name == "" {
+		s.Name = s.Subject
+	}
+
+	s.Role = p.config.DefaultRole
+	for _, g := range s.Groups {
+		if role, ok := p.config.RoleMapping[g]; ok && (s.Role == "" || apikey.Allows(role, s.Role)) {
+			s.Role = role
+		}
+	}
+	if s.Role == "" {
+		return Session{}, ErrNoRole
+	}
+	return s, nil
+}
+
+func stringClaim(claims map[string]interface{}, name string) string {
+	s, _ := claims[name].(string)
+	return s
+}
+
+// stringsClaim accepts a list of strings or a single string.
+func stringsClaim(claims map[string]interface{}, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var out []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// safeNext only allows local paths so the login cannot redirect elsewhere.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,456 @@
+package sso
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	clientID     = "dash"
+	clientSecret = "client-secret"
+	redirectURL  = "https://dash.example.com/auth/callback"
+)
+
+// issuer is a minimal OpenID provider: codes are registered by the test along
+// with the PKCE challenge and the ID token claims they are exchanged for.
+type issuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]grant
+}
+
+type grant struct {
+	challenge string
+	claims    map[string]interface{}
+}
+
+func newIssuer(t *testing.T, endSession bool) *issuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss := &issuer{key: key, codes: map[string]grant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		metadata := map[string]interface{}{
+			"issuer":                                iss.URL,
+			"authorization_endpoint":                iss.URL + "/authorize",
+			"token_endpoint":                        iss.URL + "/token",
+			"jwks_uri":                              iss.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		}
+		if endSession {
+			metadata["end_session_endpoint"] = iss.URL + "/logout?ui=1"
+		}
+		json.NewEncoder(w).Encode(metadata)
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", iss.token)
+
+	iss.Server = httptest.NewServer(mux)
+	t.Cleanup(iss.Close)
+	return iss
+}
+
+func (iss *issuer) grant(code, challenge string, claims map[string]interface{}) {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	iss.codes[code] = grant{challenge: challenge, claims: claims}
+}
+
+func (iss *issuer) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.FormValue("client_id"), r.FormValue("client_secret")
+	}
+	if id != clientID || secret != clientSecret {
+		http.Error(w, `{"error": "invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	iss.mu.Lock()
+	g, ok := iss.codes[r.FormValue("code")]
+	delete(iss.codes, r.FormValue("code"))
+	iss.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if !ok || r.FormValue("redirect_uri") != redirectURL || base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "invalid_grant"}`))
+		return
+	}
+
+	claims := map[string]interface{}{
+		"iss": iss.URL,
+		"aud": clientID,
+		"sub": "user-1",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range g.claims {
+		claims[k] = v
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "at",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     iss.sign(claims),
+	})
+}
+
+func (iss *issuer) sign(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, iss.key, crypto.SHA256, sum[:])
+	if err != nil {
+		panic(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func newTestProvider(t *testing.T, iss *issuer, defaultRole string) *Provider {
+	t.Helper()
+	p, err := NewProvider(context.Background(), Config{
+		Issuer:        iss.URL,
+		ClientID:      clientID,
+		ClientSecret:  clientSecret,
+		RedirectURL:   redirectURL,
+		RoleMapping:   map[string]string{"appsec": "admin", "platform": "scan", "eng": "read"},
+		DefaultRole:   defaultRole,
+		SessionSecret: "session-secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func cookie(t *testing.T, rec *httptest.ResponseRecorder, name string) *http.Cookie {
+	t.Helper()
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("response sets no %s cookie", name)
+	return nil
+}
+
+// login starts a login and returns the authorization request the browser is
+// sent to along with the state cookie.
+func login(t *testing.T, p *Provider, next string) (url.Values, *http.Cookie) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	if err := p.Login(rec, httptest.NewRequest(http.MethodGet, "/auth/login", nil), next); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusFound {
+		t.Fatalf("Login() answered %d, want a redirect", rec.Code)
+	}
+	u, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Query(), cookie(t, rec, stateCookie)
+}
+
+func callback(p *Provider, query url.Values, state *http.Cookie) (*httptest.ResponseRecorder, string, error) {
+	req := httptest.NewRequest(http.MethodGet, "/auth/callback?"+query.Encode(), nil)
+	if state != nil {
+		req.AddCookie(state)
+	}
+	rec := httptest.NewRecorder()
+	next, err := p.Callback(context.Background(), rec, req)
+	return rec, next, err
+}
+
+func TestLogin(t *testing.T) {
+	iss := newIssuer(t, false)
+	p := newTestProvider(t, iss, "")
+
+	auth, state := login(t, p, "/report/alpine:3.19?project=payments")
+	for k, want := range map[string]string{
+		"client_id":             clientID,
+		"redirect_uri":          redirectURL,
+		"response_type":         "code",
+		"code_challenge_method": "S256",
+	} {
+		if auth.Get(k) != want {
+			t.Errorf("authorization request %s = %q, want %q", k, auth.Get(k), want)
+		}
+	}
+	if auth.Get("state") == "" || auth.Get("nonce") == "" || auth.Get("code_challenge") == "" {
+		t.Fatalf("authorization request lacks state, nonce or PKCE challenge: %v", auth)
+	}
+	if !strings.Contains(auth.Get("scope"), "openid") {
+		t.Errorf("scope = %q", auth.Get("scope"))
+	}
+	if !state.HttpOnly || !state.Secure || state.SameSite != http.SameSiteLaxMode {
+		t.Errorf("state cookie is not locked down: %+v", state)
+	}
+
+	iss.grant("code-1", auth.Get("code_challenge"), map[string]interface{}{
+		"nonce":  auth.Get("nonce"),
+		"name":   "Ada",
+		"email":  "ada@example.com",
+		"groups": []string{"eng", "appsec"},
+	})
+	rec, next, err := callback(p, url.Values{"state": {auth.Get("state")}, "code": {"code-1"}}, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != "/report/alpine:3.19?project=payments" {
+		t.Errorf("Callback() next = %q", next)
+	}
+	if c := cookie(t, rec, stateCookie); c.MaxAge >= 0 {
+		t.Error("state cookie is not cleared after the callback")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie(t, rec, SessionCookie))
+	s, err := p.Session(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Subject != "user-1" || s.Name != "Ada" || s.Email != "ada@example.com" || s.Role != "admin" {
+		t.Errorf("session = %+v", s)
+	}
+
+	// next is restricted to local paths
+	for _, next := range []string{"https://evil.example.com/", "//evil.example.com", "/\\evil.example.com", ""} {
+		if auth, state := login(t, p, next); auth.Get("state") != "" {
+			iss.grant("code-next", auth.Get("code_challenge"), map[string]interface{}{"nonce": auth.Get("nonce"), "groups": "eng"})
+			if _, got, err := callback(p, url.Values{"state": {auth.Get("state")}, "code": {"code-next"}}, state); err != nil || got != "/" {
+				t.Errorf("login with next %q returns to %q, %v", next, got, err)
+			}
+		}
+	}
+}
+
+func TestCallback(t *testing.T) {
+	iss := newIssuer(t, false)
+	p := newTestProvider(t, iss, "")
+
+	tests := []struct {
+		name string
+		// edit changes the callback request or the grant before the callback.
+		edit func(auth url.Values, q url.Values, g *grant) *http.Cookie
+		err  string
+	}{
+		{
+			name: "no state cookie",
+			edit: func(url.Values, url.Values, *grant) *http.Cookie { return &http.Cookie{Name: "other"} },
+			err:  "login state missing",
+		},
+		{
+			name: "forged state cookie",
+			edit: func(url.Values, url.Values, *grant) *http.Cookie {
+				forged, _ := signer{key: []byte("other")}.encode(authState{State: "s", ExpiresAt: time.Now().Add(time.Minute)})
+				return &http.Cookie{Name: stateCookie, Value: forged}
+			},
+			err: "login state invalid",
+		},
+		{
+			name: "state mismatch",
+			edit: func(auth, q url.Values, g *grant) *http.Cookie { q.Set("state", "other"); return nil },
+			err:  "login state mismatch",
+		},
+		{
+			name: "provider error",
+			edit: func(auth, q url.Values, g *grant) *http.Cookie { q.Set("error", "access_denied"); return nil },
+			err:  "provider returned access_denied",
+		},
+		{
+			name: "wrong PKCE verifier",
+			edit: func(auth, q url.Values, g *grant) *http.Cookie {
+				sum := sha256.Sum256([]byte("another verifier"))
+				g.challenge = base64.RawURLEncoding.EncodeToString(sum[:])
+				return nil
+			},
+			err: "exchanging code",
+		},
+		{
+			name: "nonce mismatch",
+			edit: func(auth, q url.Values, g *grant) *http.Cookie { g.claims["nonce"] = "replayed"; return nil },
+			err:  "nonce mismatch",
+		},
+		{
+			name: "other audience",
+			edit: func(auth, q url.Values, g *grant) *http.Cookie { g.claims["aud"] = "other-client"; return nil },
+			err:  "verifying id token",
+		},
+		{
+			name: "no mapped group",
+			edit: func(auth, q url.Values, g *grant) *http.Cookie { g.claims["groups"] = []string{"sales"}; return nil },
+			err:  ErrNoRole.Error(),
+		},
+	}
+	for i, tt := range tests {
+		auth, state := login(t, p, "/")
+		code := "code-" + string(rune('a'+i))
+		g := grant{challenge: auth.Get("code_challenge"), claims: map[string]interface{}{"nonce": auth.Get("nonce"), "groups": []string{"eng"}}}
+		q := url.Values{"state": {auth.Get("state")}, "code": {code}}
+		if c := tt.edit(auth, q, &g); c != nil {
+			state = c
+		}
+		iss.grant(code, g.challenge, g.claims)
+
+		rec, _, err := callback(p, q, state)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: Callback() error = %v, want %q", tt.name, err, tt.err)
+		}
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == SessionCookie {
+				t.Errorf("%s: failed callback set a session", tt.name)
+			}
+		}
+	}
+}
+
+func TestRoleMapping(t *testing.T) {
+	tests := []struct {
+		groups      interface{}
+		defaultRole string
+		role        string
+	}{
+		{[]string{"eng"}, "", "read"},
+		{[]string{"eng", "platform"}, "", "scan"},
+		{[]string{"appsec", "eng"}, "", "admin"},
+		{"platform", "", "scan"},
+		{[]string{"sales"}, "read", "read"},
+		{[]string{"sales"}, "", ""},
+		{nil, "", ""},
+		{[]string{"eng"}, "scan", "scan"},
+	}
+	iss := newIssuer(t, false)
+	for i, tt := range tests {
+		p := newTestProvider(t, iss, tt.defaultRole)
+		auth, state := login(t, p, "/")
+		code := "code-" + string(rune('a'+i))
+		iss.grant(code, auth.Get("code_challenge"), map[string]interface{}{"nonce": auth.Get("nonce"), "groups": tt.groups})
+
+		rec, _, err := callback(p, url.Values{"state": {auth.Get("state")}, "code": {code}}, state)
+		if tt.role == "" {
+			if !errors.Is(err, ErrNoRole) {
+				t.Errorf("groups %v: Callback() = %v, want ErrNoRole", tt.groups, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("groups %v: Callback() = %v", tt.groups, err)
+			continue
+		}
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie(t, rec, SessionCookie))
+		if s, _ := p.Session(req); s.Role != tt.role {
+			t.Errorf("groups %v with default %q: role = %q, want %q", tt.groups, tt.defaultRole, s.Role, tt.role)
+		}
+	}
+}
+
+func TestParseRoleMapping(t *testing.T) {
+	m, err := ParseRoleMapping(" appsec=admin, eng=read ,")
+	if err != nil || len(m) != 2 || m["appsec"] != "admin" || m["eng"] != "read" {
+		t.Errorf("ParseRoleMapping() = %v, %v", m, err)
+	}
+	for _, s := range []string{"appsec", "appsec=owner"} {
+		if _, err := ParseRoleMapping(s); err == nil {
+			t.Errorf("ParseRoleMapping(%q) succeeded", s)
+		}
+	}
+}
+
+func TestSession(t *testing.T) {
+	iss := newIssuer(t, false)
+	p := newTestProvider(t, iss, "")
+
+	sessionCookie := func(signer signer, s Session) *http.Request {
+		value, _ := signer.encode(s)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: SessionCookie, Value: value})
+		return req
+	}
+	valid := Session{Subject: "user-1", Role: "read", ExpiresAt: time.Now().Add(time.Hour)}
+	if _, err := p.Session(sessionCookie(p.signer, valid)); err != nil {
+		t.Errorf("valid session rejected: %v", err)
+	}
+
+	expired := valid
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	forged := sessionCookie(signer{key: []byte("other")}, Session{Subject: "user-1", Role: "admin", ExpiresAt: valid.ExpiresAt})
+	tampered := sessionCookie(p.signer, valid)
+	c, _ := tampered.Cookie(SessionCookie)
+	payload, _ := base64.RawURLEncoding.DecodeString(strings.Split(c.Value, ".")[0])
+	admin := strings.Replace(string(payload), `"role":"read"`, `"role":"admin"`, 1)
+	tampered = httptest.NewRequest(http.MethodGet, "/", nil)
+	tampered.AddCookie(&http.Cookie{Name: SessionCookie, Value: base64.RawURLEncoding.EncodeToString([]byte(admin)) + "." + strings.Split(c.Value, ".")[1]})
+
+	for name, req := range map[string]*http.Request{
+		"expired":   sessionCookie(p.signer, expired),
+		"forged":    forged,
+		"tampered":  tampered,
+		"no cookie": httptest.NewRequest(http.MethodGet, "/", nil),
+	} {
+		if _, err := p.Session(req); !errors.Is(err, ErrNoSession) {
+			t.Errorf("%s session: %v, want ErrNoSession", name, err)
+		}
+	}
+}
+
+func TestLogout(t *testing.T) {
+	for _, endSession := range []bool{false, true} {
+		p := newTestProvider(t, newIssuer(t, endSession), "")
+		rec := httptest.NewRecorder()
+		target := p.Logout(rec, httptest.NewRequest(http.MethodPost, "/auth/logout", nil))
+
+		if c := cookie(t, rec, SessionCookie); c.MaxAge >= 0 || c.Value != "" {
+			t.Errorf("session cookie not cleared: %+v", c)
+		}
+		if !endSession {
+			if target != "/" {
+				t.Errorf("Logout() without end session endpoint = %q, want /", target)
+			}
+			continue
+		}
+
+		u, err := url.Parse(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := u.Query()
+		if u.Path != "/logout" || q.Get("ui") != "1" || q.Get("client_id") != clientID || q.Get("post_logout_redirect_uri") != "https://dash.example.com/" {
+			t.Errorf("Logout() = %s", target)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gocraft/work"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func install(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exp := tracetest.NewInMemoryExporter()
+	shutdown := Install(exp, "test")
+	t.Cleanup(func() { shutdown(context.Background()) })
+	return exp
+}
+
+func flush(t *testing.T) {
+	t.Helper()
+	if err := otel.GetTracerProvider().(*sdktrace.TracerProvider).ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArgs(t *testing.T) {
+	install(t)
+	ctx, span := Start(context.Background(), "enqueue")
+	defer span.End()
+
+	args := work.Q{"scan_request": "alpine:3.19"}
+	InjectArgs(ctx, args)
+	if args["scan_request"] != "alpine:3.19" || args["otel.traceparent"] == nil {
+		t.Fatalf("InjectArgs() = %v", args)
+	}
+
+	// jobs reach the worker as JSON, as gocraft stores them
+	b, err := json.Marshal(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored map[string]interface{}
+	if err := json.Unmarshal(b, &stored); err != nil {
+		t.Fatal(err)
+	}
+	stored["otel.attempts"] = 1
+
+	got := trace.SpanContextFromContext(ExtractArgs(context.Background(), stored))
+	want := span.SpanContext()
+	if !got.IsRemote() || got.TraceID() != want.TraceID() || got.SpanID() != want.SpanID() {
+		t.Errorf("ExtractArgs() = %v, want the span context of %v", got, want)
+	}
+
+	if sc := trace.SpanContextFromContext(ExtractArgs(context.Background(), work.Q{"scan_request": "alpine:3.19"})); sc.IsValid() {
+		t.Errorf("ExtractArgs() of args without trace context = %v", sc)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	exp := install(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/api/v1/images/:name", func(c *gin.Context) {
+		_, span := Start(c.Request.Context(), "lookup")
+		span.End()
+		c.Status(http.StatusInternalServerError)
+	})
+
+	caller := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/images/alpine", nil)
+	req.Header.Set("traceparent", caller)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	flush(t)
+
+	spans := exp.GetSpans().Snapshots()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans", len(spans))
+	}
+	lookup, server := spans[0], spans[1]
+	if server.Name() != "GET /api/v1/images/:name" || server.SpanKind() != trace.SpanKindServer || server.Status().Code != codes.Error {
+		t.Errorf("server span %s %v %v", server.Name(), server.SpanKind(), server.Status())
+	}
+	if server.Parent().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("server span continues %v, want the caller's trace", server.Parent())
+	}
+	if lookup.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Errorf("span of the handler has parent %v", lookup.Parent())
+	}
+}
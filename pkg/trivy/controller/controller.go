@@ -2,41 +2,123 @@ package scanner
 
 import (
 	"context"
+	"fmt"
 	"log"
 
 	"github.com/trivy-web-dash/pkg/db"
 	"github.com/trivy-web-dash/pkg/job"
 	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/pkg/metrics"
+	"github.com/trivy-web-dash/pkg/sbom"
+	"github.com/trivy-web-dash/pkg/tracing"
 	tc "github.com/trivy-web-dash/pkg/trivy"
+	"github.com/trivy-web-dash/pkg/webhook"
+	"github.com/trivy-web-dash/regopolicy"
 	"github.com/trivy-web-dash/report"
 	"github.com/trivy-web-dash/summary"
+	"github.com/trivy-web-dash/types"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/xerrors"
 )
 
 type Controller interface {
-	Scan(scanJobID string, image string) error
+	// Scan scans image for project, or only its platform variant when platform
+	// is set. An empty engine scans with the default engine.
+	Scan(ctx context.Context, scanJobID string, project string, image string, platform string, engine string) error
+	ScanSBOM(ctx context.Context, scanJobID string, project string, digest string, engine string) error
 }
 
 type controller struct {
-	store       db.Store
-	trivyClient *tc.TC
-	log         logger.Logger
+	store   db.Store
+	engines map[string]tc.Scanner
+	engine  string
+	log     logger.Logger
 }
 
-func NewController(store db.Store, tc *tc.TC, l logger.Logger) Controller {
+// NewController scans with the named engines, trivy and grype. engine is the
+// default one, or types.EngineBoth to compare them.
+func NewController(store db.Store, engines map[string]tc.Scanner, engine string, l logger.Logger) Controller {
 	return &controller{
-		store:       store,
-		trivyClient: tc,
-		log:         l,
+		store:   store,
+		engines: engines,
+		engine:  engine,
+		log:     l,
 	}
 }
 
-func (c *controller) Scan(scanJobID string, image string) error {
-	ctx := context.Background()
+func (c *controller) Scan(ctx context.Context, scanJobID string, project string, image string, platform string, engine string) error {
 	c.log.Infof("starting scan : %s", scanJobID)
-	err := c.scan(ctx, scanJobID, image)
+	if err := c.start(ctx, project, scanJobID); err != nil {
+		return err
+	}
+	return c.fail(ctx, project, scanJobID, c.scan(ctx, scanJobID, project, image, platform, engine))
+}
+
+func (c *controller) ScanSBOM(ctx context.Context, scanJobID string, project string, digest string, engine string) error {
+	c.log.Infof("starting sbom scan : %s", scanJobID)
+	if err := c.start(ctx, project, scanJobID); err != nil {
+		return err
+	}
+	return c.fail(ctx, project, scanJobID, c.scanSBOM(ctx, scanJobID, project, digest, engine))
+}
+
+// run scans with engine. With types.EngineBoth grype runs alongside trivy and
+// only adds a comparison to trivy's report, its failures are logged.
+func (c *controller) run(engine string, scan func(tc.Scanner) (*types.Report, error)) (*types.Report, error) {
+	if engine == "" {
+		engine = c.engine
+	}
+	if engine != types.EngineBoth {
+		s, ok := c.engines[engine]
+		if !ok {
+			return nil, xerrors.Errorf("unknown engine %q", engine)
+		}
+		return scan(s)
+	}
+
+	trivy, hasTrivy := c.engines[types.EngineTrivy]
+	grype, hasGrype := c.engines[types.EngineGrype]
+	if !hasTrivy || !hasGrype {
+		return nil, xerrors.Errorf("engine %s needs both %s and %s", types.EngineBoth, types.EngineTrivy, types.EngineGrype)
+	}
+
+	var other *types.Report
+	var otherErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// a panic here would escape the recover of the scan
+		defer func() {
+			if r := recover(); r != nil {
+				otherErr = fmt.Errorf("%s panicked: %v", types.EngineGrype, r)
+			}
+		}()
+		other, otherErr = scan(grype)
+	}()
+	r, err := scan(trivy)
+	<-done
+	if err != nil {
+		return nil, err
+	}
+	if otherErr != nil {
+		c.log.Errorf("second opinion scan with %s failed : %v", types.EngineGrype, otherErr)
+		return r, nil
+	}
+	r.Comparison = types.Compare(*r, *other)
+	return r, nil
+}
+
+// start marks the job as running.
+func (c *controller) start(ctx context.Context, project, scanJobID string) error {
+	if err := c.store.WithContext(ctx).UpdateStatus(project, scanJobID, job.Pending); err != nil {
+		return xerrors.Errorf("updating scan job status: %v", err)
+	}
+	return nil
+}
+
+func (c *controller) fail(ctx context.Context, project, scanJobID string, err error) error {
 	if err != nil {
-		err = c.store.UpdateStatus(scanJobID, job.ScanFail, err.Error())
+		err = c.store.WithContext(ctx).UpdateStatus(project, scanJobID, job.ScanFail, err.Error())
 		if err != nil {
 			return xerrors.Errorf("updating scan job as failed: %v", err)
 		}
@@ -44,33 +126,95 @@ func (c *controller) Scan(scanJobID string, image string) error {
 	return nil
 }
 
-func (c *controller) scan(ctx context.Context, scanJobID string, image string) (err error) {
+func (c *controller) scan(ctx context.Context, scanJobID string, project string, image string, platform string, engine string) (err error) {
+	ctx, span := tracing.Start(ctx, "scan", attribute.String("job.id", scanJobID),
+		attribute.String("project", project), attribute.String("image", image), attribute.String("platform", platform), attribute.String("engine", engine))
 	defer func() {
 		if r := recover(); r != nil {
-			err = r.(error)
+			err = fmt.Errorf("scan panicked: %v", r)
 		}
+		tracing.End(span, err)
 	}()
 
-	scanReport, err := c.trivyClient.Scan(image)
+	scanReport, err := c.run(engine, func(s tc.Scanner) (*types.Report, error) {
+		return s.Scan(ctx, image, platform)
+	})
 	if err != nil {
-		c.store.UpdateStatus(scanJobID, job.ScanFail)
-		return xerrors.Errorf("running trivy wrapper: %v", err)
+		return xerrors.Errorf("running scanner: %w", err)
+	}
+
+	if platform != "" {
+		scanReport.ArtifactName = types.PlatformKey(image, platform)
 	}
+	return c.save(ctx, scanJobID, project, scanReport)
+}
 
+func (c *controller) scanSBOM(ctx context.Context, scanJobID string, project string, digest string, engine string) (err error) {
+	ctx, span := tracing.Start(ctx, "scan sbom", attribute.String("job.id", scanJobID),
+		attribute.String("project", project), attribute.String("sbom.digest", digest), attribute.String("engine", engine))
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("scan panicked: %v", r)
+		}
+		tracing.End(span, err)
+	}()
+
+	store := c.store.WithContext(ctx)
+	b, err := store.GetSBOM(digest)
+	if err != nil {
+		return xerrors.Errorf("loading sbom: %v", err)
+	}
+	if b == nil {
+		return xerrors.Errorf("sbom %s not found or expired", digest)
+	}
+
+	doc, err := sbom.Parse(b)
+	if err != nil {
+		return xerrors.Errorf("parsing sbom: %v", err)
+	}
+
+	scanReport, err := c.run(engine, func(s tc.Scanner) (*types.Report, error) {
+		return s.ScanSBOM(ctx, b)
+	})
+	if err != nil {
+		return xerrors.Errorf("running scanner: %w", err)
+	}
+	// scanners name the artifact after the temp file, store it like an image instead
+	scanReport.ArtifactName = doc.ArtifactName()
+
+	return c.save(ctx, scanJobID, project, scanReport)
+}
+
+func (c *controller) save(ctx context.Context, scanJobID string, project string, scanReport *types.Report) (err error) {
 	c.log.Infof("job : %s  - status :%s. Updating vulnerability report in db...", scanJobID, job.Scanned)
 
-	err = c.store.UpdateReport(scanJobID, *scanReport)
+	ctx, span := tracing.Start(ctx, "save report", attribute.String("job.id", scanJobID))
+	defer func() { tracing.End(span, err) }()
+	store := c.store.WithContext(ctx)
+
+	scanReport.ResolveDigest()
+	if types.ProjectName(project) != types.DefaultProject {
+		scanReport.Project = project
+	}
+
+	// rego policies are advisory, a broken policy must not fail the scan
+	scanReport.PolicyResults, err = regopolicy.GetRegoClient().Evaluate(ctx, scanReport.ArtifactKey(), *scanReport)
+	if err != nil {
+		c.log.Errorf("Error evaluating rego policies: %v", err)
+	}
+
+	err = store.UpdateReport(project, scanJobID, *scanReport)
 	if err != nil {
 		c.log.Errorf("Error UpdateReport: %v", err)
 		return xerrors.Errorf("saving scan report: %v", err)
 	}
 
-	if err := c.store.UpdateStatus(scanJobID, job.Scanned); err != nil {
+	if err := store.UpdateStatus(project, scanJobID, job.Scanned); err != nil {
 		return err
 	}
 	c.log.Info("report updated")
 
-	_, err = c.store.Get(scanJobID)
+	scanJob, err := store.Get(project, scanJobID)
 	if err != nil {
 		return err
 	}
@@ -80,15 +224,51 @@ func (c *controller) scan(ctx context.Context, scanJobID string, image string) (
 		log.Fatalf("GetReportClient REPORT SET %v", err)
 	}
 
+	if image, platform := types.SplitPlatformKey(scanReport.ArtifactKey()); platform != "" {
+		// platform reports only show up through the combined report of their
+		// image, which is rebuilt as each platform finishes
+		combined, err := report.GetReportClient().Combine(ctx, project, image)
+		if err != nil {
+			return xerrors.Errorf("combining platform reports of %s: %v", image, err)
+		}
+		if err := report.GetReportClient().Set(ctx, combined); err != nil {
+			return xerrors.Errorf("saving combined report of %s: %v", image, err)
+		}
+		scanReport = &combined
+	}
+
 	err = summary.GetSummaryClient().Set(ctx, *scanReport)
 	if err != nil {
 		log.Fatalf("GetSummaryClient REDIS SET %v", err)
 	}
 
-	err = c.store.UpdateStatus(scanJobID, job.Done)
+	status := job.Done
+	if scanJob != nil && scanJob.Webhook != "" && !c.notify(scanJob.Webhook, *scanReport) {
+		status = job.WebhookFail
+	}
+
+	err = store.UpdateStatus(project, scanJobID, status)
 	if err != nil {
 		return xerrors.Errorf("updating scan job status: %v", err)
 	}
 
 	return nil
 }
+
+// notify posts the report to the webhook of the scan's project. A failed
+// delivery is recorded on the job, the scan itself succeeded.
+func (c *controller) notify(url string, scanReport types.Report) bool {
+	status, err := webhook.Do(url, scanReport)
+	if err != nil {
+		c.log.Errorf("posting report of %s to webhook : %v", scanReport.ArtifactKey(), err)
+		metrics.WebhookDeliveries.WithLabelValues("failure").Inc()
+		return false
+	}
+	if *status < 200 || *status > 299 {
+		c.log.Errorf("posting report of %s to webhook : status %d", scanReport.ArtifactKey(), *status)
+		metrics.WebhookDeliveries.WithLabelValues("failure").Inc()
+		return false
+	}
+	metrics.WebhookDeliveries.WithLabelValues("success").Inc()
+	return true
+}
@@ -0,0 +1,85 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/trivy-web-dash/pkg/logger"
+	tc "github.com/trivy-web-dash/pkg/trivy"
+	"github.com/trivy-web-dash/types"
+)
+
+type fakeScanner struct {
+	tc.Scanner
+	engine string
+	err    error
+	panic  interface{}
+}
+
+func (s fakeScanner) Scan(ctx context.Context, imageRef, platform string) (*types.Report, error) {
+	if s.panic != nil {
+		panic(s.panic)
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &types.Report{ArtifactName: imageRef, Engine: s.engine}, nil
+}
+
+func newTestController(engine string, engines ...fakeScanner) *controller {
+	log := logger.NewAppLogger("fatal")
+	log.InitLogger()
+	m := map[string]tc.Scanner{}
+	for _, s := range engines {
+		m[s.engine] = s
+	}
+	return NewController(nil, m, engine, log).(*controller)
+}
+
+func scanWith(c *controller, engine string) (*types.Report, error) {
+	return c.run(engine, func(s tc.Scanner) (*types.Report, error) {
+		return s.Scan(context.Background(), "alpine:3.19", "")
+	})
+}
+
+func TestRun(t *testing.T) {
+	trivy := fakeScanner{engine: types.EngineTrivy}
+	grype := fakeScanner{engine: types.EngineGrype}
+
+	tests := []struct {
+		name       string
+		c          *controller
+		engine     string
+		wantEngine string
+		compared   bool
+		err        string
+	}{
+		{"default engine", newTestController(types.EngineTrivy, trivy, grype), "", types.EngineTrivy, false, ""},
+		{"requested engine", newTestController(types.EngineTrivy, trivy, grype), types.EngineGrype, types.EngineGrype, false, ""},
+		{"unknown engine", newTestController(types.EngineTrivy, trivy), types.EngineGrype, "", false, `unknown engine "grype"`},
+		{"both", newTestController(types.EngineBoth, trivy, grype), "", types.EngineTrivy, true, ""},
+		{"both without grype", newTestController(types.EngineBoth, trivy), "", "", false, "needs both trivy and grype"},
+		{"both without trivy", newTestController(types.EngineTrivy, grype), types.EngineBoth, "", false, "needs both trivy and grype"},
+		{"both with grype failing", newTestController(types.EngineBoth, trivy, fakeScanner{engine: types.EngineGrype, err: errors.New("exit status 1")}), "", types.EngineTrivy, false, ""},
+		{"both with grype panicking", newTestController(types.EngineBoth, trivy, fakeScanner{engine: types.EngineGrype, panic: "index out of range"}), "", types.EngineTrivy, false, ""},
+		{"both with trivy failing", newTestController(types.EngineBoth, fakeScanner{engine: types.EngineTrivy, err: errors.New("exit status 1")}, grype), "", "", false, "exit status 1"},
+	}
+	for _, tt := range tests {
+		r, err := scanWith(tt.c, tt.engine)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: run() error = %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: run() = %v", tt.name, err)
+			continue
+		}
+		if r.Engine != tt.wantEngine || (r.Comparison != nil) != tt.compared {
+			t.Errorf("%s: run() = engine %q, comparison %v", tt.name, r.Engine, r.Comparison)
+		}
+	}
+}
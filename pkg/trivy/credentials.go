@@ -0,0 +1,22 @@
+package tyivy
+
+import "context"
+
+// Credentials log scans in to a private registry.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+type credentialsKey struct{}
+
+// WithCredentials makes the scans run with ctx pull their image with creds.
+func WithCredentials(ctx context.Context, creds Credentials) context.Context {
+	return context.WithValue(ctx, credentialsKey{}, creds)
+}
+
+// CredentialsFrom returns the credentials set with WithCredentials, if any.
+func CredentialsFrom(ctx context.Context) (Credentials, bool) {
+	creds, ok := ctx.Value(credentialsKey{}).(Credentials)
+	return creds, ok && creds.Username != ""
+}
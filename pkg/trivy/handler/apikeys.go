@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trivy-web-dash/apikey"
+	"github.com/trivy-web-dash/audit"
+)
+
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	keys, err := apikey.GetAPIKeyClient().List(c.Request.Context())
+	if err != nil {
+		h.logger.Errorf("unable to list api keys : %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"status": "error listing api keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// CreateAPIKey issues a key. The token is only part of this response.
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var k apikey.Key
+	if err := c.ShouldBindJSON(&k); err != nil {
+		h.logger.Errorf("unable to parse request : %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"status": "error parsing request"})
+		return
+	}
+
+	k, token, err := apikey.GetAPIKeyClient().Create(c.Request.Context(), k)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.logger.Infof("api key %s (%s) with scope %s created", k.ID, k.Name, k.Scope)
+	k.Hash = ""
+	h.record(c, audit.APIKeyCreate, "", k.ID, nil, k)
+	c.JSON(http.StatusCreated, gin.H{"key": k, "token": token})
+}
+
+func (h *Handler) DeleteAPIKey(c *gin.Context) {
+	k, err := apikey.GetAPIKeyClient().Get(c.Request.Context(), c.Param("id"))
+	if err == nil {
+		err = apikey.GetAPIKeyClient().Delete(c.Request.Context(), c.Param("id"))
+	}
+	if errors.Is(err, apikey.ErrNotFound) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Errorf("unable to delete api key : %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"status": "error deleting api key"})
+		return
+	}
+
+	h.logger.Infof("api key %s revoked", c.Param("id"))
+	k.Hash = ""
+	h.record(c, audit.APIKeyDelete, "", k.ID, k, nil)
+	c.Status(http.StatusNoContent)
+}
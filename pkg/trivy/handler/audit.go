@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trivy-web-dash/audit"
+	"github.com/trivy-web-dash/pkg/auth"
+)
+
+// record appends an action of the caller to the audit log, with the project
+// of scans. The action has already happened, so a failure to record it is
+// only logged.
+func (h *Handler) record(c *gin.Context, action, project, target string, before, after interface{}) {
+	principal, _ := auth.GetPrincipal(c)
+	actor := principal.Name
+	if principal.Email != "" {
+		actor = principal.Email
+	}
+
+	e := audit.Entry{
+		Actor:    actor,
+		KeyID:    principal.KeyID,
+		SourceIP: c.ClientIP(),
+		Action:   action,
+		Project:  project,
+		Target:   target,
+		Before:   audit.Value(before),
+		After:    audit.Value(after),
+	}
+	if err := audit.GetAuditClient().Record(c, e); err != nil {
+		h.logger.Errorf("unable to record %s of %s by %s in audit log : %v", action, target, actor, err)
+	}
+}
+
+// ListAudit serves the audit log filtered by the actor, action, target,
+// project, since, until and limit query parameters. format=jsonl exports one
+// entry per line.
+func (h *Handler) ListAudit(c *gin.Context) {
+	f := audit.Filter{
+		Actor:   c.Query("actor"),
+		Action:  c.Query("action"),
+		Target:  c.Query("target"),
+		Project: c.Query("project"),
+	}
+
+	var err error
+	for param, t := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if v := c.Query(param); v != "" {
+			if *t, err = time.Parse(time.RFC3339, v); err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": param + " must be an RFC 3339 time"})
+				return
+			}
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+			return
+		}
+	}
+
+	entries, err := audit.GetAuditClient().Query(c, f)
+	if err != nil {
+		h.logger.Errorf("unable to query audit log : %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"status": "error querying audit log"})
+		return
+	}
+
+	if c.Query("format") != "jsonl" {
+		c.JSON(http.StatusOK, entries)
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", `attachment; filename="audit.jsonl"`)
+	c.Status(http.StatusOK)
+	enc := json.NewEncoder(c.Writer)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			h.logger.Errorf("unable to export audit log : %v", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+
+	"github.com/trivy-web-dash/apikey"
+	"github.com/trivy-web-dash/audit"
+	"github.com/trivy-web-dash/pkg/auth"
+)
+
+func TestAudit(t *testing.T) {
+	redis := miniredis.RunT(t)
+	log := newTestLogger(t, redis)
+	h := NewHandler(log, nil, nil, nil, nil, nil)
+	adminID, adminToken := newKey(t, "ops", apikey.ScopeAdmin)
+
+	admin := auth.New(true, "", nil, log).Require(apikey.ScopeAdmin)
+	r := gin.New()
+	r.POST("/api/v1/keys", admin, h.CreateAPIKey)
+	r.DELETE("/api/v1/keys/:id", admin, h.DeleteAPIKey)
+	r.GET("/api/v1/audit", admin, h.ListAudit)
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", adminToken)
+		req.RemoteAddr = "192.0.2.10:51234"
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "/api/v1/keys", `{"name": "ci", "scope": "scan"}`)
+	var created struct {
+		Key apikey.Key `json:"key"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("create key: %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(http.MethodDelete, "/api/v1/keys/"+created.Key.ID, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete key: %d %s", rec.Code, rec.Body)
+	}
+	// failed changes are not recorded
+	serve(http.MethodDelete, "/api/v1/keys/"+created.Key.ID, "")
+
+	rec = serve(http.MethodGet, "/api/v1/audit?actor=ops", "")
+	var entries []audit.Entry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("audit log = %s", rec.Body)
+	}
+	create, del := entries[0], entries[1]
+	if create.Action != audit.APIKeyCreate || create.KeyID != adminID || create.SourceIP != "192.0.2.10" || create.Target != created.Key.ID ||
+		create.Before != nil || !strings.Contains(string(create.After), `"scope":"scan"`) || strings.Contains(string(create.After), "hash") {
+		t.Errorf("create entry = %+v", create)
+	}
+	if del.Action != audit.APIKeyDelete || del.Before == nil || del.After != nil {
+		t.Errorf("delete entry = %+v", del)
+	}
+
+	rec = serve(http.MethodGet, "/api/v1/audit?action=apikey.delete&format=jsonl", "")
+	if rec.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("jsonl export content type %q", rec.Header().Get("Content-Type"))
+	}
+	lines := 0
+	for s := bufio.NewScanner(rec.Body); s.Scan(); lines++ {
+		var e audit.Entry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil || e.Action != audit.APIKeyDelete {
+			t.Errorf("jsonl line %q: %v", s.Text(), err)
+		}
+	}
+	if lines != 1 {
+		t.Errorf("jsonl export has %d lines", lines)
+	}
+
+	for _, query := range []string{"since=yesterday", "until=2024-05-01", "limit=-1", "limit=ten"} {
+		if rec := serve(http.MethodGet, "/api/v1/audit?"+query, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("audit?%s: %d", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trivy-web-dash/audit"
+	"github.com/trivy-web-dash/exception"
+	"github.com/trivy-web-dash/pkg/project"
+	"github.com/trivy-web-dash/pkg/rbac"
+)
+
+func (h *Handler) ListExceptions(c *gin.Context) {
+	rules, err := exception.GetExceptionClient().List(c.Request.Context(), project.Current(c).Name)
+	if err != nil {
+		h.logger.Errorf("unable to list exceptions : %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"status": "error listing exceptions"})
+		return
+	}
+
+	// exceptions name the findings they suppress, so only show those whose
+	// pattern the caller can view
+	visible := rules[:0]
+	for _, rule := range rules {
+		if rbac.Can(c, rbac.Viewer, rule.ImagePattern) {
+			visible = append(visible, rule)
+		}
+	}
+	c.JSON(http.StatusOK, visible)
+}
+
+func (h *Handler) CreateException(c *gin.Context) {
+	var rule exception.Rule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		h.logger.Errorf("unable to parse request : %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"status": "error parsing request"})
+		return
+	}
+
+	p := project.Current(c)
+	rule, err := exception.GetExceptionClient().Create(c.Request.Context(), p.Name, rule)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.logger.Infof("exception %s for %s on %s created by %s", rule.ID, rule.VulnerabilityID, rule.ImagePattern, rule.Owner)
+	h.record(c, audit.ExceptionCreate, p.Name, rule.ID, nil, rule)
+	c.JSON(http.StatusCreated, rule)
+}
+
+func (h *Handler) DeleteException(c *gin.Context) {
+	// looked up first so the audit log shows what was deleted
+	p := project.Current(c)
+	rule, err := exception.GetExceptionClient().Get(c.Request.Context(), p.Name, c.Param("id"))
+	if err == nil {
+		err = exception.GetExceptionClient().Delete(c.Request.Context(), p.Name, c.Param("id"))
+	}
+	if errors.Is(err, exception.ErrNotFound) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "exception not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Errorf("unable to delete exception : %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"status": "error deleting exception"})
+		return
+	}
+
+	h.record(c, audit.ExceptionDelete, p.Name, rule.ID, rule, nil)
+	c.Status(http.StatusNoContent)
+}
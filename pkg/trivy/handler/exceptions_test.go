@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/trivy-web-dash/apikey"
+	"github.com/trivy-web-dash/exception"
+	"github.com/trivy-web-dash/pkg/auth"
+	"github.com/trivy-web-dash/pkg/project"
+	"github.com/trivy-web-dash/pkg/tracing"
+	"github.com/trivy-web-dash/vex"
+)
+
+// The redis commands of the key check, the exceptions and the VEX documents
+// of a request are traced under its span.
+func TestExceptionsTraced(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	shutdown := tracing.Install(exp, "test")
+	t.Cleanup(func() { shutdown(context.Background()) })
+
+	redis := miniredis.RunT(t)
+	log := newTestLogger(t, redis)
+	if err := exception.NewExceptionClient(redis.Addr(), "", 3, false, false, log); err != nil {
+		t.Fatal(err)
+	}
+	if err := vex.NewVEXClient(redis.Addr(), "", 4, false, false, log); err != nil {
+		t.Fatal(err)
+	}
+	_, token := newKey(t, "ci", apikey.ScopeRead)
+	projects := project.NewSet()
+	h := NewHandler(log, nil, nil, nil, nil, projects)
+
+	r := gin.New()
+	r.Use(tracing.Middleware())
+	read := auth.New(true, "", nil, log).Require(apikey.ScopeRead)
+	r.GET("/api/v1/exceptions", read, projects.Select(), h.ListExceptions)
+	r.GET("/api/v1/vex", read, h.ListVEX)
+	for _, path := range []string{"/api/v1/exceptions", "/api/v1/vex"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: %d %s", path, rec.Code, rec.Body)
+		}
+	}
+	if err := otel.GetTracerProvider().(*sdktrace.TracerProvider).ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exp.GetSpans().Snapshots()
+	names := map[string]string{}
+	for _, s := range spans {
+		names[s.SpanContext().SpanID().String()] = s.Name()
+	}
+	// GET reads the key, SET records its first use, KEYS lists the documents
+	commands := map[string]map[string]bool{}
+	for _, s := range spans {
+		parent := names[s.Parent().SpanID().String()]
+		if commands[parent] == nil {
+			commands[parent] = map[string]bool{}
+		}
+		commands[parent][s.Name()] = true
+	}
+	for route, want := range map[string][]string{
+		"GET /api/v1/exceptions": {"redis GET", "redis SET", "redis KEYS"},
+		"GET /api/v1/vex":        {"redis GET", "redis KEYS"},
+	} {
+		for _, cmd := range want {
+			if !commands[route][cmd] {
+				t.Errorf("%s is not traced under %s: %v", cmd, route, commands)
+			}
+		}
+	}
+}
@@ -1,29 +1,55 @@
 package handler
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/trivy-web-dash/audit"
 	"github.com/trivy-web-dash/pkg/db"
+	"github.com/trivy-web-dash/pkg/job"
 	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/pkg/policy"
+	"github.com/trivy-web-dash/pkg/project"
 	"github.com/trivy-web-dash/pkg/queue"
+	"github.com/trivy-web-dash/pkg/rbac"
+	"github.com/trivy-web-dash/pkg/registry"
+	"github.com/trivy-web-dash/pkg/sbom"
+	"github.com/trivy-web-dash/report"
+	"github.com/trivy-web-dash/types"
 )
 
 type Handler struct {
 	logger   logger.Logger
 	enqueuer queue.Enqueuer
 	store    db.Store
+	policies *policy.Set
+	crawler  *registry.Crawler
+	projects *project.Set
 }
 
 type ScanRequest struct {
 	Image string `form:"image"`
+	// Platforms scans each listed platform of a multi-platform image as a
+	// separate job, e.g. "linux/amd64,linux/arm64", or every platform with "all".
+	Platforms string `form:"platforms"`
+	// Engine overrides the configured scan engine: trivy, grype or both.
+	Engine string `form:"engine"`
 }
 
-func NewHandler(l logger.Logger, e queue.Enqueuer, s db.Store) *Handler {
+func NewHandler(l logger.Logger, e queue.Enqueuer, s db.Store, p *policy.Set, r *registry.Crawler, projects *project.Set) *Handler {
 	return &Handler{
 		enqueuer: e,
 		logger:   l,
 		store:    s,
+		policies: p,
+		crawler:  r,
+		projects: projects,
 	}
 }
 func (h *Handler) AcceptScanRequest(c *gin.Context) {
@@ -39,23 +65,151 @@ func (h *Handler) AcceptScanRequest(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid image name"})
 		return
 	}
+	if !types.ValidEngine(req.Engine) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "unknown engine " + req.Engine})
+		return
+	}
+
+	if !rbac.Can(c, rbac.Scanner, req.Image) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not allowed to scan " + req.Image})
+		return
+	}
 
 	// validate image format and webhook url format
 	h.logger.Infof("scan request for %s recieved result endpoint", req.Image)
+	if req.Platforms != "" {
+		h.acceptPlatformScanRequest(c, req)
+		return
+	}
+
+	p := project.Current(c)
+
 	// add to queue
-	j, err := h.enqueuer.Enqueue(req.Image)
+	j, err := h.enqueuer.Enqueue(c.Request.Context(), p.Name, req.Image, req.Engine)
 	if err != nil {
-		h.logger.Errorf("unable to queue request : %s", err.Error())
-		c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"status": "error adding to queue"})
+		h.abortEnqueue(c, err, nil)
 		return
 	}
 
+	h.record(c, audit.ScanSubmit, p.Name, req.Image, nil, gin.H{"job": j.ID})
+
 	// return id for job and 200 ok
 	c.JSON(http.StatusOK, gin.H{"ID": j.ID})
 }
 
+// maxSBOMSize bounds uploads so a single request cannot exhaust redis memory.
+const maxSBOMSize = 32 << 20
+
+func (h *Handler) acceptPlatformScanRequest(c *gin.Context, req ScanRequest) {
+	var platforms []string
+	if req.Platforms == "all" {
+		var err error
+		platforms, err = registry.ResolvePlatforms(c.Request.Context(), req.Image)
+		if err != nil {
+			h.logger.Errorf("unable to resolve platforms of %s : %v", req.Image, err)
+			c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": "unable to resolve platforms, list them explicitly"})
+			return
+		}
+		if len(platforms) == 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": req.Image + " is not a multi-platform image"})
+			return
+		}
+	} else {
+		for _, p := range strings.Split(req.Platforms, ",") {
+			p = strings.TrimSpace(p)
+			if !strings.Contains(p, "/") {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid platform " + p + ", expected os/arch"})
+				return
+			}
+			platforms = append(platforms, p)
+		}
+	}
+
+	proj := project.Current(c)
+	jobs := map[string]string{}
+	for _, p := range platforms {
+		j, err := h.enqueuer.EnqueuePlatform(c.Request.Context(), proj.Name, req.Image, p, req.Engine)
+		if err != nil {
+			if len(jobs) > 0 {
+				h.record(c, audit.ScanSubmit, proj.Name, req.Image, nil, gin.H{"jobs": jobs})
+			}
+			h.abortEnqueue(c, err, jobs)
+			return
+		}
+		jobs[p] = j.ID
+	}
+
+	h.record(c, audit.ScanSubmit, proj.Name, req.Image, nil, gin.H{"jobs": jobs})
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+func (h *Handler) AcceptSBOMScanRequest(c *gin.Context) {
+	engine := c.PostForm("engine")
+	if !types.ValidEngine(engine) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "unknown engine " + engine})
+		return
+	}
+
+	fh, err := c.FormFile("sbom")
+	if err != nil {
+		h.logger.Errorf("unable to parse sbom upload : %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "missing sbom file"})
+		return
+	}
+
+	if fh.Size > maxSBOMSize {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "sbom too large"})
+		return
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		h.logger.Errorf("unable to open sbom upload : %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "unable to read sbom"})
+		return
+	}
+	defer f.Close()
+
+	b, err := io.ReadAll(io.LimitReader(f, maxSBOMSize))
+	if err != nil {
+		h.logger.Errorf("unable to read sbom upload : %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "unable to read sbom"})
+		return
+	}
+
+	doc, err := sbom.Parse(b)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !rbac.Can(c, rbac.Scanner, doc.ArtifactName()) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not allowed to scan " + doc.ArtifactName()})
+		return
+	}
+
+	p := project.Current(c)
+	h.logger.Infof("sbom scan request for %s (%s) recieved", doc.ArtifactName(), doc.Format)
+	sum := sha256.Sum256(b)
+	digest := hex.EncodeToString(sum[:])
+	if err := h.store.WithContext(c.Request.Context()).SaveSBOM(digest, b); err != nil {
+		h.logger.Errorf("unable to save sbom : %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"status": "error saving sbom"})
+		return
+	}
+
+	j, err := h.enqueuer.EnqueueSBOM(c.Request.Context(), p.Name, digest, engine)
+	if err != nil {
+		h.abortEnqueue(c, err, nil)
+		return
+	}
+
+	h.record(c, audit.SBOMSubmit, p.Name, doc.ArtifactName(), nil, gin.H{"job": j.ID, "sbom": digest, "format": doc.Format})
+	c.JSON(http.StatusOK, gin.H{"ID": j.ID, "artifact": doc.ArtifactName()})
+}
+
 func (h *Handler) GetScanStatus(c *gin.Context) {
-	jobs, err := h.store.GetAllJobStatus()
+	jobs, err := h.store.WithContext(c.Request.Context()).GetAllJobStatus(project.Current(c).Name)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError,
 			gin.H{"status": "error getting scan status"},
@@ -72,17 +226,73 @@ func (h *Handler) GetScanStatus(c *gin.Context) {
 }
 
 func (h *Handler) GetScanStatusForJob(c *gin.Context) {
-	job, err := h.store.Get(c.Param("id"))
+	p, ok := h.policies.Get(c.Query("policy"))
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "unknown policy " + c.Query("policy")})
+		return
+	}
+
+	j, err := h.store.WithContext(c.Request.Context()).Get(project.Current(c).Name, c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError,
 			gin.H{"status": "error getting scan status"},
 		)
 		return
 	}
+	if j == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "scan job not found"})
+		return
+	}
+	image := j.Image
+	if image == "" {
+		// SBOM jobs only know their artifact once scanned
+		image = j.Report.ArtifactKey()
+	}
+	if !rbac.Can(c, rbac.Viewer, image) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "scan job not found"})
+		return
+	}
+
+	resp := gin.H{
+		"id":     j.ID,
+		"status": j.Status.String(),
+	}
+	if j.Error != "" {
+		resp["error"] = j.Error
+	}
+
+	if j.Status == job.Scanned || j.Status == job.WebhookFail || j.Status == job.Done {
+		r := j.Report
+		image := r.ArtifactKey()
+		if !rbac.Can(c, rbac.Viewer, image) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "scan job not found"})
+			return
+		}
+		if err := report.Annotate(c.Request.Context(), project.Current(c).Name, &r); err != nil {
+			h.logger.Errorf("unable to apply exceptions and vex for %s : %v", image, err)
+		}
+		counts := r.CountSeverities()
+		resp["image"] = image
+		resp["vulnerabilities_found"] = counts.Critical + counts.High + counts.Medium + counts.Low
+		resp["verdict"] = p.Evaluate(r, time.Now())
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// abortEnqueue answers a failed enqueue, with 429 when the project's quota is
+// used up. jobs lists the scans of the request that were queued before.
+func (h *Handler) abortEnqueue(c *gin.Context, err error, jobs map[string]string) {
+	var quota *queue.QuotaError
+	if errors.As(err, &quota) {
+		resp := gin.H{"error": quota.Error()}
+		if len(jobs) > 0 {
+			resp["jobs"] = jobs
+		}
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, resp)
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"id":                    job.ID,
-		"status":                job.Status.String(),
-		"vulnerabilities_found": job.Report.TotalSeverities.Critical + job.Report.TotalSeverities.High + job.Report.TotalSeverities.Low + job.Report.TotalSeverities.Medium,
-	})
+	h.logger.Errorf("unable to queue request : %s", err.Error())
+	c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"status": "error adding to queue"})
 }
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+
+	"github.com/trivy-web-dash/apikey"
+	"github.com/trivy-web-dash/exception"
+	"github.com/trivy-web-dash/pkg/auth"
+	redisx "github.com/trivy-web-dash/pkg/db/redis"
+	"github.com/trivy-web-dash/pkg/job"
+	"github.com/trivy-web-dash/pkg/policy"
+	"github.com/trivy-web-dash/pkg/project"
+	"github.com/trivy-web-dash/pkg/queue"
+	"github.com/trivy-web-dash/pkg/rbac"
+	"github.com/trivy-web-dash/types"
+	"github.com/trivy-web-dash/vex"
+)
+
+func TestAcceptScanRequestQuota(t *testing.T) {
+	redis := miniredis.RunT(t)
+	log := newTestLogger(t, redis)
+	pool, err := redisx.NewPool(redis.Addr(), "", 5, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := redisx.NewStore(pool)
+	projects := project.NewSet(project.Project{Name: "small", MaxActiveScans: 2})
+	h := NewHandler(log, queue.NewEnqueuer(pool, store, projects), store, nil, nil, projects)
+
+	r := gin.New()
+	r.POST("/scan/image", auth.New(false, "", nil, log).Require(apikey.ScopeScan), projects.Select(), h.AcceptScanRequest)
+	scan := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/scan/image", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Project", "small")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := scan(url.Values{"image": {"alpine:3.19"}}); rec.Code != http.StatusOK {
+		t.Fatalf("first scan: %d %s", rec.Code, rec.Body)
+	}
+
+	// the second platform fills the quota, the third is refused
+	rec := scan(url.Values{"image": {"nginx:1.25"}, "platforms": {"linux/amd64,linux/arm64,linux/s390x"}})
+	var resp struct {
+		Error string            `json:"error"`
+		Jobs  map[string]string `json:"jobs"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusTooManyRequests || resp.Error != "project small has 2 of 2 scans queued" || len(resp.Jobs) != 1 || resp.Jobs["linux/amd64"] == "" {
+		t.Errorf("scan beyond the quota: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := scan(url.Values{"image": {"busybox:1.36"}}); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("scan with a full quota: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestGetScanStatusForJob(t *testing.T) {
+	redis := miniredis.RunT(t)
+	log := newTestLogger(t, redis)
+	if err := exception.NewExceptionClient(redis.Addr(), "", 3, false, false, log); err != nil {
+		t.Fatal(err)
+	}
+	if err := vex.NewVEXClient(redis.Addr(), "", 4, false, false, log); err != nil {
+		t.Fatal(err)
+	}
+	pool, err := redisx.NewPool(redis.Addr(), "", 5, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := redisx.NewStore(pool)
+	projects := project.NewSet()
+	h := NewHandler(log, nil, store, policy.NewSet(), nil, projects)
+
+	scanned := types.Report{
+		ArtifactName: "registry.example.com/team/app:1.0",
+		Results: []types.Result{{Vulnerabilities: []types.Vulnerability{
+			{VulnerabilityID: "CVE-2024-0727", Severity: "HIGH"},
+			{VulnerabilityID: "CVE-2024-2511", Severity: "LOW"},
+		}}},
+	}
+	for _, j := range []job.ScanJob{
+		{ID: "webhook-failed", Image: "registry.example.com/team/app:1.0", Status: job.WebhookFail, Report: scanned},
+		{ID: "queued", Image: "registry.example.com/team/app:2.0", Status: job.Queued},
+		{ID: "failed", Image: "registry.example.com/other/app:1.0", Status: job.ScanFail, Error: "manifest unknown"},
+		{ID: "sbom", Status: job.Pending},
+	} {
+		if err := store.Create(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	team, teamToken := newKey(t, "team", apikey.ScopeRead)
+	_, adminToken := newKey(t, "admin", apikey.ScopeAdmin)
+	rbac.SetPolicy(rbac.NewPolicy(rbac.Grant{Subject: "key:" + team, Role: rbac.Viewer, Images: []string{"registry.example.com/team/*"}}))
+	t.Cleanup(func() { rbac.SetPolicy(nil) })
+
+	r := gin.New()
+	r.GET("/scan/status/:id", auth.New(true, "", nil, log).Require(apikey.ScopeRead), projects.Select(), h.GetScanStatusForJob)
+	status := func(token, id string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(http.MethodGet, "/scan/status/"+id, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		var resp map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, resp
+	}
+
+	// a failed webhook delivery still produced a report
+	code, resp := status(teamToken, "webhook-failed")
+	if code != http.StatusOK || resp["status"] != "WebhookFail" || resp["image"] != "registry.example.com/team/app:1.0" ||
+		resp["vulnerabilities_found"] != float64(2) || resp["verdict"] == nil {
+		t.Errorf("webhook-failed: %d %v", code, resp)
+	}
+	if code, resp := status(teamToken, "queued"); code != http.StatusOK || resp["status"] != "Queued" || resp["image"] != nil {
+		t.Errorf("queued: %d %v", code, resp)
+	}
+
+	// jobs of images the caller cannot view are hidden whatever their status
+	for _, id := range []string{"failed", "sbom", "missing"} {
+		if code, resp := status(teamToken, id); code != http.StatusNotFound || resp["error"] != "scan job not found" {
+			t.Errorf("%s: %d %v", id, code, resp)
+		}
+	}
+	if code, resp := status(adminToken, "failed"); code != http.StatusOK || resp["error"] != "manifest unknown" {
+		t.Errorf("failed as admin: %d %v", code, resp)
+	}
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trivy-web-dash/audit"
+	"github.com/trivy-web-dash/pkg/pushevent"
+)
+
+const maxPushEventSize = 1 << 20
+
+// PushEvent returns a handler that verifies a push notification for the
+// receiver named by the :receiver parameter and enqueues a scan for every image
+// it reports. The scans go to the project the receiver is bound to, never to
+// one named by the request. hooks is read per request, so reloaded secrets
+// apply right away.
+func (h *Handler) PushEvent(hooks *pushevent.Set) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hook, ok := hooks.Get(c.Param("receiver"))
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "unknown push receiver " + c.Param("receiver")})
+			return
+		}
+		receiver := hook.Receiver
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPushEventSize))
+		if err != nil {
+			h.logger.Errorf("unable to read %s push event : %s", receiver.Name(), err.Error())
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"status": "error reading request"})
+			return
+		}
+
+		if err := receiver.Verify(c.Request, body); err != nil {
+			h.logger.Warnf("rejected %s push event from %s : %v", receiver.Name(), c.ClientIP(), err)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		images, err := receiver.Parse(c.Request, body)
+		if err != nil {
+			h.logger.Errorf("unable to parse %s push event : %v", receiver.Name(), err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"status": "error parsing request"})
+			return
+		}
+
+		p, ok := h.projects.Get(hook.Project)
+		if !ok {
+			h.logger.Errorf("%s push receiver is configured for unknown project %s", receiver.Name(), hook.Project)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"status": "receiver project not found"})
+			return
+		}
+
+		jobs := map[string]string{}
+		for _, image := range images {
+			h.logger.Infof("%s push event for %s recieved", receiver.Name(), image)
+			j, err := h.enqueuer.Enqueue(c.Request.Context(), p.Name, image, "")
+			if err != nil {
+				h.abortEnqueue(c, err, jobs)
+				return
+			}
+			jobs[image] = j.ID
+			e := audit.Entry{
+				Actor:    "hook:" + receiver.Name(),
+				SourceIP: c.ClientIP(),
+				Action:   audit.PushEvent,
+				Project:  p.Name,
+				Target:   image,
+				After:    audit.Value(gin.H{"job": j.ID}),
+			}
+			if err := audit.GetAuditClient().Record(c, e); err != nil {
+				h.logger.Errorf("unable to record %s push event in audit log : %v", receiver.Name(), err)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+	}
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+
+	"github.com/trivy-web-dash/pkg/job"
+	"github.com/trivy-web-dash/pkg/project"
+	"github.com/trivy-web-dash/pkg/pushevent"
+	"github.com/trivy-web-dash/pkg/queue"
+)
+
+type pushEnqueuer struct {
+	queue.Enqueuer
+	scans []string
+}
+
+func (e *pushEnqueuer) Enqueue(ctx context.Context, project, image, engine string) (job.ScanJob, error) {
+	e.scans = append(e.scans, project+" "+image)
+	return job.ScanJob{ID: fmt.Sprint(len(e.scans)), Project: project, Image: image}, nil
+}
+
+func TestPushEvent(t *testing.T) {
+	redis := miniredis.RunT(t)
+	log := newTestLogger(t, redis)
+	e := &pushEnqueuer{}
+	projects := project.NewSet(project.Project{Name: "payments"})
+	h := NewHandler(log, e, nil, nil, nil, projects)
+
+	hooks := pushevent.NewSet(pushevent.Hook{Receiver: pushevent.NewDistribution("s3cret", "registry.example.com"), Project: "payments"})
+	r := gin.New()
+	r.POST("/hooks/:receiver", h.PushEvent(hooks))
+	push := func(receiver, token string) int {
+		body := `{"events": [{"action": "push", "target": {"repository": "team/app", "tag": "1.0"}}]}`
+		req := httptest.NewRequest(http.MethodPost, "/hooks/"+receiver, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := push("registry", "s3cret"); code != http.StatusOK {
+		t.Errorf("push: %d", code)
+	}
+	if code := push("registry", "other"); code != http.StatusUnauthorized {
+		t.Errorf("push with a wrong token: %d", code)
+	}
+	if code := push("harbor", "s3cret"); code != http.StatusNotFound {
+		t.Errorf("push to a disabled receiver: %d", code)
+	}
+
+	// reloaded secrets and projects apply to the next request
+	hooks.Replace([]pushevent.Hook{{Receiver: pushevent.NewDistribution("rotated", "registry.example.com")}})
+	if code := push("registry", "s3cret"); code != http.StatusUnauthorized {
+		t.Errorf("push with the replaced token: %d", code)
+	}
+	if code := push("registry", "rotated"); code != http.StatusOK {
+		t.Errorf("push with the new token: %d", code)
+	}
+
+	want := []string{"payments registry.example.com/team/app:1.0", "default registry.example.com/team/app:1.0"}
+	if strings.Join(e.scans, ",") != strings.Join(want, ",") {
+		t.Errorf("queued %q, want %q", e.scans, want)
+	}
+}
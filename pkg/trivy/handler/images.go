@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trivy-web-dash/pkg/export"
+	"github.com/trivy-web-dash/pkg/project"
+	"github.com/trivy-web-dash/pkg/rbac"
+	"github.com/trivy-web-dash/report"
+	"github.com/trivy-web-dash/types"
+	"github.com/trivy-web-dash/util"
+)
+
+// GetImageResource serves /api/v1/images/<image>/<resource>. Image references
+// contain slashes, so the resource is split off the end of the wildcard path.
+func (h *Handler) GetImageResource(c *gin.Context) {
+	path := strings.TrimPrefix(c.Param("path"), "/")
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "expected /api/v1/images/<image>/<resource>"})
+		return
+	}
+
+	image, resource := path[:i], path[i+1:]
+	switch resource {
+	case "report":
+		h.exportReport(c, image)
+	case "verdict":
+		h.getVerdict(c, image)
+	case "compare":
+		h.getComparison(c, image)
+	default:
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "unknown resource " + resource})
+	}
+}
+
+func (h *Handler) getVerdict(c *gin.Context, image string) {
+	p, ok := h.policies.Get(c.Query("policy"))
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "unknown policy " + c.Query("policy")})
+		return
+	}
+
+	r, ok := h.getAnnotatedReport(c, image)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, p.Evaluate(r, time.Now()))
+}
+
+// getComparison serves the findings trivy and grype disagree on, for reports
+// scanned with both engines.
+func (h *Handler) getComparison(c *gin.Context, image string) {
+	r, ok := h.getAnnotatedReport(c, image)
+	if !ok {
+		return
+	}
+	if r.Comparison == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "no comparison for " + image + ", scan it with engine=both"})
+		return
+	}
+
+	c.JSON(http.StatusOK, r.Comparison)
+}
+
+// getAnnotatedReport loads the stored report of image with exceptions and VEX
+// applied, writing the error response itself when it fails.
+func (h *Handler) getAnnotatedReport(c *gin.Context, image string) (types.Report, bool) {
+	r, ttl, err := report.GetReportClient().Get(c.Request.Context(), project.Current(c).Name, image)
+	if err == nil && !rbac.CanView(c, image, r) {
+		err = report.ErrNotFound
+	}
+	if errors.Is(err, report.ErrNotFound) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "no report found for " + image})
+		return types.Report{}, false
+	}
+	if err != nil {
+		h.logger.Errorf("unable to get report for %s : %v", image, err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"status": "error getting report"})
+		return types.Report{}, false
+	}
+
+	if err := report.Annotate(c.Request.Context(), project.Current(c).Name, &r); err != nil {
+		h.logger.Errorf("unable to apply exceptions and vex for %s : %v", image, err)
+	}
+
+	r.TotalSeverities = r.CountSeverities()
+	r.TotalSuppressed = r.CountSuppressed()
+	r.LastScanAt = util.ConvertToHumanReadable(report.GetReportClient().Age(ttl))
+	return r, true
+}
+
+func (h *Handler) exportReport(c *gin.Context, image string) {
+	format, err := export.ParseFormat(c.Query("format"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	threshold, err := export.ParseThreshold(c.Query("threshold"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	r, ok := h.getAnnotatedReport(c, image)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := export.Write(&buf, format, r, export.Options{Threshold: threshold}); err != nil {
+		h.logger.Errorf("unable to export report for %s as %s : %v", image, format, err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"status": "error exporting report"})
+		return
+	}
+
+	c.Data(http.StatusOK, format.ContentType(), buf.Bytes())
+}
+
+func (h *Handler) ListPolicies(c *gin.Context) {
+	c.JSON(http.StatusOK, h.policies.List())
+}
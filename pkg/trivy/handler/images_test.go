@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+
+	"github.com/trivy-web-dash/apikey"
+	"github.com/trivy-web-dash/exception"
+	"github.com/trivy-web-dash/pkg/auth"
+	"github.com/trivy-web-dash/pkg/policy"
+	"github.com/trivy-web-dash/pkg/project"
+	"github.com/trivy-web-dash/report"
+	"github.com/trivy-web-dash/types"
+	"github.com/trivy-web-dash/vex"
+)
+
+func TestGetVerdictByDigest(t *testing.T) {
+	redis := miniredis.RunT(t)
+	log := newTestLogger(t, redis)
+	if err := report.NewReportClient(redis.Addr(), "", 1, false, false, time.Hour, log); err != nil {
+		t.Fatal(err)
+	}
+	if err := exception.NewExceptionClient(redis.Addr(), "", 3, false, false, log); err != nil {
+		t.Fatal(err)
+	}
+	if err := vex.NewVEXClient(redis.Addr(), "", 4, false, false, log); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	r := types.Report{
+		ArtifactName: "registry.example.com/app:1.0",
+		Metadata:     &types.ImageMetadata{RepoDigests: []string{"registry.example.com/app@sha256:aaaa"}},
+		Results: []types.Result{{Vulnerabilities: []types.Vulnerability{
+			{VulnerabilityID: "CVE-2024-0727", PkgName: "openssl", Severity: "HIGH"},
+		}}},
+	}
+	r.ResolveDigest()
+	if err := report.GetReportClient().Set(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	_, err := exception.GetExceptionClient().Create(ctx, "", exception.Rule{
+		VulnerabilityID: "CVE-2024-0727",
+		ImagePattern:    "registry.example.com/app:*",
+		Owner:           "security",
+		Justification:   "not reachable",
+		ExpiresAt:       time.Now().Add(24 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	projects := project.NewSet()
+	h := NewHandler(log, nil, nil, policy.NewSet(), nil, projects)
+	router := gin.New()
+	router.GET("/api/v1/images/*path", auth.New(false, "", nil, log).Require(apikey.ScopeRead), projects.Select(), h.GetImageResource)
+
+	// the exception names the tag, it applies however the report is looked up
+	for _, image := range []string{"registry.example.com/app:1.0", "registry.example.com/app@sha256:aaaa", "sha256:aaaa"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/images/"+image+"/verdict?policy=strict", nil))
+		var v policy.Verdict
+		if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusOK || !v.Pass {
+			t.Errorf("verdict of %s: %d %s", image, rec.Code, rec.Body)
+		}
+	}
+}
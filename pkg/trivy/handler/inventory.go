@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trivy-web-dash/inventory"
+	"github.com/trivy-web-dash/pkg/rbac"
+)
+
+func (h *Handler) ListInventory(c *gin.Context) {
+	inventories, err := inventory.GetInventoryClient().List(c)
+	if err != nil {
+		h.logger.Errorf("unable to list kubernetes inventory : %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"status": "error listing inventory"})
+		return
+	}
+	visible := rbac.Visible(c)
+	for _, inv := range inventories {
+		for image := range inv.Images {
+			if !visible(image) {
+				delete(inv.Images, image)
+			}
+		}
+	}
+	c.JSON(http.StatusOK, inventories)
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trivy-web-dash/pkg/auth"
+)
+
+// ListProjects lists the projects the caller may use. Webhook URLs may embed
+// credentials and stay out of the response.
+func (h *Handler) ListProjects(c *gin.Context) {
+	principal, _ := auth.GetPrincipal(c)
+	projects := h.projects.Admitted(principal)
+	resp := make([]gin.H, 0, len(projects))
+	for _, p := range projects {
+		resp = append(resp, gin.H{
+			"name":           p.Name,
+			"description":    p.Description,
+			"maxActiveScans": p.MaxActiveScans,
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
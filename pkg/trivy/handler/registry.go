@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trivy-web-dash/audit"
+	"github.com/trivy-web-dash/pkg/auth"
+	"github.com/trivy-web-dash/pkg/rbac"
+)
+
+func (h *Handler) ListRegistries(c *gin.Context) {
+	targets := h.crawler.Targets()
+	resp := make([]gin.H, 0, len(targets))
+	for _, t := range targets {
+		// credentials stay out of the response
+		resp = append(resp, gin.H{
+			"name":       t.Name,
+			"host":       t.Host,
+			"include":    t.Include,
+			"exclude":    t.Exclude,
+			"latestTags": t.LatestTags,
+			"schedule":   t.Schedule,
+			"project":    t.Project,
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// CrawlRegistry queues a crawl of a registry target. A crawl may queue scans of
+// any image on the target's host, so the caller must be admitted to the
+// target's project and hold the scanner role on every image of the host.
+func (h *Handler) CrawlRegistry(c *gin.Context) {
+	name := c.Param("name")
+	t, ok := h.crawler.Target(name)
+	p, known := h.projects.Get(t.Project)
+	principal, _ := auth.GetPrincipal(c)
+	if !ok || !known || !p.Admits(principal) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "unknown registry " + name})
+		return
+	}
+	if !rbac.Can(c, rbac.Scanner, t.Host+"/*") {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not allowed to scan " + t.Host + "/*"})
+		return
+	}
+
+	id, err := h.enqueuer.EnqueueCrawl(c.Request.Context(), name)
+	if err != nil {
+		h.logger.Errorf("unable to queue crawl of %s : %s", name, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"status": "error adding to queue"})
+		return
+	}
+
+	h.logger.Infof("crawl of registry %s queued", name)
+	h.record(c, audit.RegistryCrawl, p.Name, name, nil, gin.H{"job": id})
+	c.JSON(http.StatusOK, gin.H{"ID": id})
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+
+	"github.com/trivy-web-dash/apikey"
+	"github.com/trivy-web-dash/audit"
+	"github.com/trivy-web-dash/pkg/auth"
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/pkg/project"
+	"github.com/trivy-web-dash/pkg/queue"
+	"github.com/trivy-web-dash/pkg/rbac"
+	"github.com/trivy-web-dash/pkg/registry"
+)
+
+type crawlEnqueuer struct {
+	queue.Enqueuer
+	crawls []string
+}
+
+func (e *crawlEnqueuer) EnqueueCrawl(ctx context.Context, target string) (string, error) {
+	e.crawls = append(e.crawls, target)
+	return "crawl-1", nil
+}
+
+func newTestLogger(t *testing.T, redis *miniredis.Miniredis) logger.Logger {
+	t.Helper()
+	log := logger.NewAppLogger("fatal")
+	log.InitLogger()
+	if err := apikey.NewAPIKeyClient(redis.Addr(), "", 8, false, false, log); err != nil {
+		t.Fatal(err)
+	}
+	if err := audit.NewAuditClient(redis.Addr(), "", 9, false, false, log); err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	return log
+}
+
+// newKey issues an API key and returns its ID and token.
+func newKey(t *testing.T, name, scope string) (string, string) {
+	t.Helper()
+	k, token, err := apikey.GetAPIKeyClient().Create(context.Background(), apikey.Key{Name: name, Scope: scope})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k.ID, token
+}
+
+func TestCrawlRegistry(t *testing.T) {
+	redis := miniredis.RunT(t)
+	log := newTestLogger(t, redis)
+
+	platform, platformToken := newKey(t, "platform", apikey.ScopeScan)
+	team, teamToken := newKey(t, "team", apikey.ScopeScan)
+	outsider, outsiderToken := newKey(t, "outsider", apikey.ScopeScan)
+	_, adminToken := newKey(t, "admin", apikey.ScopeAdmin)
+
+	rbac.SetPolicy(rbac.NewPolicy(
+		rbac.Grant{Subject: "key:" + platform, Role: rbac.Scanner, Images: []string{"registry.example.com/*"}},
+		rbac.Grant{Subject: "key:" + team, Role: rbac.Scanner, Images: []string{"registry.example.com/team/*"}},
+		rbac.Grant{Subject: "key:" + outsider, Role: rbac.Scanner, Images: []string{"*"}},
+	))
+	t.Cleanup(func() { rbac.SetPolicy(nil) })
+
+	projects := project.NewSet(project.Project{Name: "payments", Members: []string{"key:" + platform, "key:" + team}})
+	e := &crawlEnqueuer{}
+	crawler := registry.NewCrawler([]registry.Target{{Name: "internal", Host: "registry.example.com", Project: "payments"}}, e, log)
+	h := NewHandler(log, e, nil, nil, crawler, projects)
+
+	r := gin.New()
+	r.POST("/api/v1/registries/:name/crawl", auth.New(true, "", nil, log).Require(apikey.ScopeScan), h.CrawlRegistry)
+
+	tests := []struct {
+		name   string
+		token  string
+		target string
+		status int
+	}{
+		{"grant on the whole host", platformToken, "internal", http.StatusOK},
+		{"grant on part of the host", teamToken, "internal", http.StatusForbidden},
+		{"not a project member", outsiderToken, "internal", http.StatusNotFound},
+		{"admin", adminToken, "internal", http.StatusOK},
+		{"unknown target", platformToken, "missing", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/registries/"+tt.target+"/crawl", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.status, rec.Body)
+		}
+	}
+	if len(e.crawls) != 2 {
+		t.Errorf("queued crawls %q, want one per allowed request", e.crawls)
+	}
+
+	entries, err := audit.GetAuditClient().Query(context.Background(), audit.Filter{Action: audit.RegistryCrawl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Project != "payments" || entries[0].Target != "internal" {
+		t.Errorf("audit entries = %+v", entries)
+	}
+}
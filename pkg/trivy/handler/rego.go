@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trivy-web-dash/audit"
+	"github.com/trivy-web-dash/regopolicy"
+)
+
+const maxRegoSize = 1 << 20
+
+func (h *Handler) ListRegoPolicies(c *gin.Context) {
+	policies, err := regopolicy.GetRegoClient().List(c)
+	if err != nil {
+		h.logger.Errorf("unable to list rego policies : %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"status": "error listing rego policies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, policies)
+}
+
+func (h *Handler) PutRegoPolicy(c *gin.Context) {
+	b, err := io.ReadAll(io.LimitReader(c.Request.Body, maxRegoSize))
+	if err != nil {
+		h.logger.Errorf("unable to read rego upload : %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"status": "error reading request"})
+		return
+	}
+
+	var before interface{}
+	if old, err := regopolicy.GetRegoClient().Get(c, c.Param("name")); err == nil {
+		before = old
+	}
+
+	p, err := regopolicy.GetRegoClient().Put(c, c.Param("name"), string(b))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.logger.Infof("rego policy %s (%s) stored", p.Name, p.Package)
+	h.record(c, audit.RegoPut, "", p.Name, before, p)
+	c.JSON(http.StatusOK, p)
+}
+
+func (h *Handler) DeleteRegoPolicy(c *gin.Context) {
+	p, err := regopolicy.GetRegoClient().Get(c, c.Param("name"))
+	if err == nil {
+		err = regopolicy.GetRegoClient().Delete(c, c.Param("name"))
+	}
+	if errors.Is(err, regopolicy.ErrNotFound) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "rego policy not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Errorf("unable to delete rego policy : %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"status": "error deleting rego policy"})
+		return
+	}
+
+	h.record(c, audit.RegoDelete, "", p.Name, p, nil)
+	c.Status(http.StatusNoContent)
+}
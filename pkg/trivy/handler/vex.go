@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trivy-web-dash/audit"
+	"github.com/trivy-web-dash/vex"
+)
+
+const maxVEXSize = 8 << 20
+
+func (h *Handler) ListVEX(c *gin.Context) {
+	docs, err := vex.GetVEXClient().List(c.Request.Context())
+	if err != nil {
+		h.logger.Errorf("unable to list vex documents : %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"status": "error listing vex documents"})
+		return
+	}
+
+	resp := make([]gin.H, 0, len(docs))
+	for _, doc := range docs {
+		resp = append(resp, gin.H{
+			"key":        vex.Key(doc),
+			"id":         doc.ID,
+			"author":     doc.Author,
+			"timestamp":  doc.Timestamp,
+			"version":    doc.Version,
+			"statements": len(doc.Statements),
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) UploadVEX(c *gin.Context) {
+	b, err := io.ReadAll(io.LimitReader(c.Request.Body, maxVEXSize))
+	if err != nil {
+		h.logger.Errorf("unable to read vex upload : %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"status": "error reading request"})
+		return
+	}
+
+	doc, err := vex.GetVEXClient().Upload(c.Request.Context(), b)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.logger.Infof("vex document %s by %s uploaded with %d statements", doc.ID, doc.Author, len(doc.Statements))
+	h.record(c, audit.VEXUpload, "", vex.Key(*doc), nil, doc)
+	c.JSON(http.StatusCreated, gin.H{"key": vex.Key(*doc), "id": doc.ID, "statements": len(doc.Statements)})
+}
+
+func (h *Handler) DeleteVEX(c *gin.Context) {
+	doc, err := vex.GetVEXClient().Get(c.Request.Context(), c.Param("key"))
+	if err == nil {
+		err = vex.GetVEXClient().Delete(c.Request.Context(), c.Param("key"))
+	}
+	if errors.Is(err, vex.ErrNotFound) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "vex document not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Errorf("unable to delete vex document : %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"status": "error deleting vex document"})
+		return
+	}
+
+	h.record(c, audit.VEXDelete, "", c.Param("key"), doc, nil)
+	c.Status(http.StatusNoContent)
+}
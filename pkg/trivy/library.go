@@ -0,0 +1,194 @@
+//go:build trivylib
+
+package tyivy
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	dbTypes "github.com/aquasecurity/trivy-db/pkg/types"
+	"github.com/aquasecurity/trivy/pkg/commands/artifact"
+	ftypes "github.com/aquasecurity/trivy/pkg/fanal/types"
+	"github.com/aquasecurity/trivy/pkg/flag"
+	ttypes "github.com/aquasecurity/trivy/pkg/types"
+	"github.com/aquasecurity/trivy/pkg/version"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/pkg/metrics"
+	"github.com/trivy-web-dash/pkg/tracing"
+	"github.com/trivy-web-dash/types"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/xerrors"
+)
+
+// scanTimeout matches the default --timeout of the trivy binary.
+const scanTimeout = 5 * time.Minute
+
+// LibraryScanner scans with Trivy's Go packages in the calling process, in
+// client mode against the trivy server, so it holds no vulnerability DB.
+type LibraryScanner struct {
+	server   string
+	cacheDir string
+	logger   logger.Logger
+}
+
+func NewLibraryScanner(l logger.Logger, server, cacheDir string) (Scanner, error) {
+	return &LibraryScanner{
+		server:   server,
+		cacheDir: cacheDir,
+		logger:   l,
+	}, nil
+}
+
+// Scan scans imageRef, selecting the platform manifest when platform is set.
+func (s *LibraryScanner) Scan(ctx context.Context, imageRef, platform string) (*types.Report, error) {
+	opts := s.options(imageRef)
+	if platform != "" {
+		p, err := v1.ParsePlatform(platform)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing platform %s: %w", platform, err)
+		}
+		opts.ImageOptions.Platform = ftypes.Platform{Platform: p}
+	}
+	if creds, ok := CredentialsFrom(ctx); ok {
+		opts.RegistryOptions.Credentials = []ftypes.Credential{{Username: creds.Username, Password: creds.Password}}
+	}
+	return s.run(ctx, "image", opts, func(r artifact.Runner) (ttypes.Report, error) {
+		return r.ScanImage(ctx, opts)
+	})
+}
+
+// ScanSBOM scans a CycloneDX or SPDX document. Trivy reads SBOMs from a path,
+// so the document is staged in a temporary file.
+func (s *LibraryScanner) ScanSBOM(ctx context.Context, sbom []byte) (report *types.Report, err error) {
+	f, err := os.CreateTemp("", "sbom_*.json")
+	if err != nil {
+		return nil, xerrors.Errorf("creating sbom tmp file: %w", err)
+	}
+	defer func() {
+		if err := os.Remove(f.Name()); err != nil {
+			s.logger.Errorf("unable to remove sbom tmp file : %s", err.Error())
+		}
+	}()
+
+	if _, err := f.Write(sbom); err != nil {
+		f.Close()
+		return nil, xerrors.Errorf("writing sbom tmp file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, xerrors.Errorf("closing sbom tmp file: %w", err)
+	}
+
+	opts := s.options(f.Name())
+	return s.run(ctx, "sbom", opts, func(r artifact.Runner) (ttypes.Report, error) {
+		return r.ScanSBOM(ctx, opts)
+	})
+}
+
+// options mirrors the flags the cli scanner passes to the trivy binary.
+func (s *LibraryScanner) options(target string) flag.Options {
+	return flag.Options{
+		GlobalOptions: flag.GlobalOptions{
+			CacheDir: s.cacheDir,
+			Timeout:  scanTimeout,
+			Quiet:    true,
+		},
+		CacheOptions: flag.CacheOptions{CacheBackend: "fs"},
+		ImageOptions: flag.ImageOptions{ImageSources: ftypes.AllImageSources},
+		RemoteOptions: flag.RemoteOptions{
+			ServerAddr: s.server,
+		},
+		ReportOptions: flag.ReportOptions{
+			Format: ttypes.FormatJSON,
+			Severities: []dbTypes.Severity{
+				dbTypes.SeverityCritical,
+				dbTypes.SeverityHigh,
+				dbTypes.SeverityMedium,
+				dbTypes.SeverityLow,
+			},
+		},
+		ScanOptions: flag.ScanOptions{
+			Target:   target,
+			Scanners: ttypes.Scanners{ttypes.VulnerabilityScanner},
+		},
+		PackageOptions: flag.PackageOptions{
+			PkgTypes:         []string{string(ttypes.PkgTypeOS), string(ttypes.PkgTypeLibrary)},
+			PkgRelationships: ftypes.Relationships,
+		},
+		VulnerabilityOptions: flag.VulnerabilityOptions{
+			IgnoreStatuses: unfixed(),
+		},
+	}
+}
+
+// unfixed lists every status but fixed, which is what --ignore-unfixed ignores.
+func unfixed() []dbTypes.Status {
+	var statuses []dbTypes.Status
+	for _, name := range dbTypes.Statuses {
+		if st := dbTypes.NewStatus(name); st != dbTypes.StatusFixed {
+			statuses = append(statuses, st)
+		}
+	}
+	return statuses
+}
+
+func (s *LibraryScanner) run(ctx context.Context, subcommand string, opts flag.Options, scan func(artifact.Runner) (ttypes.Report, error)) (report *types.Report, err error) {
+	ctx, span := tracing.Start(ctx, "trivy "+subcommand, attribute.String("trivy.target", opts.Target))
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	runner, err := artifact.NewRunner(ctx, opts)
+	if err != nil {
+		return nil, xerrors.Errorf("initializing trivy: %w", err)
+	}
+	defer func() {
+		if err := runner.Close(ctx); err != nil {
+			s.logger.Errorf("unable to close trivy runner : %v", err)
+		}
+	}()
+
+	start := time.Now()
+	r, err := scan(runner)
+	metrics.ScanDuration.WithLabelValues(subcommand).Observe(time.Since(start).Seconds())
+	if err != nil {
+		s.logger.Errorf("trivy scan failed target : %s : %v", opts.Target, err)
+		return nil, xerrors.Errorf("scanning %s: %w", opts.Target, err)
+	}
+
+	r, err = runner.Filter(ctx, opts, r)
+	if err != nil {
+		return nil, xerrors.Errorf("filtering scan report: %w", err)
+	}
+
+	return convert(r)
+}
+
+// convert goes through the JSON report format, which types.Report follows.
+func convert(r ttypes.Report) (*types.Report, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, xerrors.Errorf("encoding scan report: %w", err)
+	}
+	var report types.Report
+	if err := json.Unmarshal(b, &report); err != nil {
+		return nil, xerrors.Errorf("decoding scan report: %w", err)
+	}
+	return &report, nil
+}
+
+// Version reads the versions of the linked Trivy and its DB in the cache dir.
+func (s *LibraryScanner) Version() (*types.VersionInfo, error) {
+	b, err := json.Marshal(version.NewVersionInfo(s.cacheDir))
+	if err != nil {
+		return nil, err
+	}
+	var vi types.VersionInfo
+	if err := json.Unmarshal(b, &vi); err != nil {
+		return nil, err
+	}
+	return &vi, nil
+}
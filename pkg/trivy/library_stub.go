@@ -0,0 +1,16 @@
+//go:build !trivylib
+
+package tyivy
+
+import (
+	"errors"
+
+	"github.com/trivy-web-dash/pkg/logger"
+)
+
+// ErrNoLibrary is returned for library mode by builds without the trivylib tag.
+var ErrNoLibrary = errors.New("trivy library mode needs a build with -tags trivylib")
+
+func NewLibraryScanner(l logger.Logger, server, cacheDir string) (Scanner, error) {
+	return nil, ErrNoLibrary
+}
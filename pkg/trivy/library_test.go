@@ -0,0 +1,70 @@
+//go:build trivylib
+
+package tyivy
+
+import (
+	"slices"
+	"testing"
+
+	dbTypes "github.com/aquasecurity/trivy-db/pkg/types"
+	ftypes "github.com/aquasecurity/trivy/pkg/fanal/types"
+	ttypes "github.com/aquasecurity/trivy/pkg/types"
+
+	"github.com/trivy-web-dash/pkg/logger"
+)
+
+func TestNewScannerLibrary(t *testing.T) {
+	log := logger.NewAppLogger("fatal")
+	log.InitLogger()
+	s, err := NewScanner(log, "library", "http://trivy:4954", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(*LibraryScanner); !ok {
+		t.Errorf("NewScanner(library) = %T", s)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	s := &LibraryScanner{server: "http://trivy:4954", cacheDir: "/cache"}
+	opts := s.options("alpine:3.19")
+
+	if opts.Target != "alpine:3.19" || opts.ServerAddr != "http://trivy:4954" || opts.CacheDir != "/cache" || opts.Timeout != scanTimeout {
+		t.Errorf("options() = %+v", opts)
+	}
+	if !slices.Equal(opts.PkgTypes, []string{string(ttypes.PkgTypeOS), string(ttypes.PkgTypeLibrary)}) {
+		t.Errorf("PkgTypes = %q", opts.PkgTypes)
+	}
+	// an empty list would drop every package from the report
+	if !slices.Equal(opts.PkgRelationships, ftypes.Relationships) {
+		t.Errorf("PkgRelationships = %v", opts.PkgRelationships)
+	}
+	// like --ignore-unfixed
+	if slices.Contains(opts.IgnoreStatuses, dbTypes.StatusFixed) || len(opts.IgnoreStatuses) != len(dbTypes.Statuses)-1 {
+		t.Errorf("IgnoreStatuses = %v", opts.IgnoreStatuses)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	r, err := convert(ttypes.Report{
+		ArtifactName: "alpine:3.19",
+		Results: ttypes.Results{{
+			Target: "alpine:3.19 (alpine 3.19.1)",
+			Vulnerabilities: []ttypes.DetectedVulnerability{{
+				VulnerabilityID:  "CVE-2024-0727",
+				PkgName:          "libcrypto3",
+				InstalledVersion: "3.1.4-r2",
+				FixedVersion:     "3.1.4-r5",
+			}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ArtifactName != "alpine:3.19" || len(r.Results) != 1 || len(r.Results[0].Vulnerabilities) != 1 {
+		t.Fatalf("convert() = %+v", r)
+	}
+	if v := r.Results[0].Vulnerabilities[0]; v.VulnerabilityID != "CVE-2024-0727" || v.FixedVersion != "3.1.4-r5" {
+		t.Errorf("converted vulnerability = %+v", v)
+	}
+}
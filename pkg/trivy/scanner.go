@@ -0,0 +1,33 @@
+package tyivy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/types"
+)
+
+// Scanner scans images and SBOMs into reports in trivy's format.
+type Scanner interface {
+	// Scan scans imageRef, or its platform variant when platform is set. The
+	// image is pulled with the Credentials of ctx, if any.
+	Scan(ctx context.Context, imageRef, platform string) (*types.Report, error)
+	// ScanSBOM scans a CycloneDX or SPDX document.
+	ScanSBOM(ctx context.Context, sbom []byte) (*types.Report, error)
+	// Version returns the scanner and vulnerability DB versions.
+	Version() (*types.VersionInfo, error)
+}
+
+// NewScanner returns the scanner for mode: cli runs the trivy binary, library
+// calls Trivy in-process. Both scan against the trivy server.
+func NewScanner(l logger.Logger, mode, server, cacheDir string) (Scanner, error) {
+	switch mode {
+	case "cli":
+		return NewTrivyClient(l, server), nil
+	case "library":
+		return NewLibraryScanner(l, server, cacheDir)
+	default:
+		return nil, fmt.Errorf("unknown trivy mode %q", mode)
+	}
+}
@@ -1,34 +1,92 @@
 package tyivy
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os/exec"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/pkg/metrics"
 	"github.com/trivy-web-dash/pkg/osmgr"
+	"github.com/trivy-web-dash/pkg/tracing"
 	"github.com/trivy-web-dash/types"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/xerrors"
 )
 
 const trivyoutput = "json"
 const trivyCmd = "trivy"
 
+// versionMaxAge is how long Version reuses the output of `trivy --version`.
+const versionMaxAge = time.Minute
+
 type TC struct {
 	Server string
 	logger logger.Logger
 	mgr    osmgr.Mgr
+
+	mu        sync.Mutex
+	version   *types.VersionInfo
+	versionAt time.Time
 }
 
 func NewTrivyClient(l logger.Logger, s string) *TC {
+	return NewTrivyClientWithMgr(l, s, osmgr.DefaultMgr)
+}
+
+// NewTrivyClientWithMgr runs trivy through mgr, which tests replace.
+func NewTrivyClientWithMgr(l logger.Logger, s string, mgr osmgr.Mgr) *TC {
 	return &TC{
 		Server: s,
 		logger: l,
-		mgr:    osmgr.DefaultMgr,
+		mgr:    mgr,
 	}
 }
 
-func (t *TC) Scan(imageRef string) (report *types.Report, err error) {
+// Scan scans imageRef with `trivy image`. For multi-platform images platform
+// selects the manifest to scan, e.g. "linux/arm64"; empty lets trivy choose.
+func (t *TC) Scan(ctx context.Context, imageRef, platform string) (report *types.Report, err error) {
+	var args []string
+	if platform != "" {
+		args = []string{"--platform", platform}
+	}
+	return t.run(ctx, "image", imageRef, args...)
+}
+
+// ScanSBOM scans a CycloneDX or SPDX document with `trivy sbom`.
+func (t *TC) ScanSBOM(ctx context.Context, sbom []byte) (report *types.Report, err error) {
+	sbomFile, err := t.mgr.TempFile("/tmp/", "sbom_*.json")
+	if err != nil {
+		t.logger.Debugf("error creating sbom tmp file : %v", err)
+		return nil, err
+	}
+
+	defer func() {
+		t.logger.Debugf("removing sbom tmp file path : %s", sbomFile.Name())
+		if err := t.mgr.Remove(sbomFile.Name()); err != nil {
+			t.logger.Errorf("unable to remove sbom tmp file : %s", err.Error())
+		}
+	}()
+
+	if _, err := sbomFile.Write(sbom); err != nil {
+		sbomFile.Close()
+		return nil, xerrors.Errorf("writing sbom tmp file: %w", err)
+	}
+	if err := sbomFile.Close(); err != nil {
+		return nil, xerrors.Errorf("closing sbom tmp file: %w", err)
+	}
+
+	return t.run(ctx, "sbom", sbomFile.Name())
+}
+
+func (t *TC) run(ctx context.Context, subcommand, target string, extraArgs ...string) (report *types.Report, err error) {
+	_, span := tracing.Start(ctx, "trivy "+subcommand, attribute.String("trivy.target", target))
+	defer func() { tracing.End(span, err) }()
+
 	reportFile, err := t.mgr.TempFile("/tmp/", "scan_report_*.json")
 	if err != nil {
 		t.logger.Debugf("error creating report tmp file : %v", err)
@@ -38,27 +96,33 @@ func (t *TC) Scan(imageRef string) (report *types.Report, err error) {
 	t.logger.Debugf("saving scan to tmp file path : %s", reportFile.Name())
 	defer func() {
 		t.logger.Debugf("removing scan report tmp file path : %s", reportFile.Name())
-		err = t.mgr.Remove(reportFile.Name())
-		if err != nil {
+		if err := t.mgr.Remove(reportFile.Name()); err != nil {
 			t.logger.Errorf("unable to remove scan tmp file : %s", err.Error())
 		}
 	}()
 
-	cmd, err := t.prepareScanCmd(imageRef, reportFile.Name())
+	cmd, err := t.prepareScanCmd(subcommand, target, reportFile.Name(), extraArgs...)
 	if err != nil {
 		t.logger.Errorf("failed to prepare scan command : %v", err)
 		return nil, err
 	}
+	if creds, ok := CredentialsFrom(ctx); ok {
+		cmd.Env = append(cmd.Env, "TRIVY_USERNAME="+creds.Username, "TRIVY_PASSWORD="+creds.Password)
+	}
 
 	t.logger.Debugf("executing command path: %s args: %+q", cmd.Path, cmd.Args)
 
+	start := time.Now()
 	stdout, err := t.mgr.RunCmd(cmd)
+	metrics.ScanDuration.WithLabelValues(subcommand).Observe(time.Since(start).Seconds())
+	metrics.TrivyExitCodes.WithLabelValues(subcommand, strconv.Itoa(cmd.ProcessState.ExitCode())).Inc()
+	span.SetAttributes(attribute.Int("trivy.exit_code", cmd.ProcessState.ExitCode()))
 	if err != nil {
-		t.logger.Errorf("trivy run failed image_ref : %s exit_code : %d stdout : %s", imageRef, cmd.ProcessState.ExitCode(), string(stdout))
+		t.logger.Errorf("trivy run failed target : %s exit_code : %d stdout : %s", target, cmd.ProcessState.ExitCode(), string(stdout))
 		return nil, xerrors.Errorf("running trivy: %v: %v", err, string(stdout))
 	}
 
-	t.logger.Debugf("trivy run finished image_ref : %s exit_code : %d stdout : %s", imageRef, cmd.ProcessState.ExitCode(), string(stdout))
+	t.logger.Debugf("trivy run finished target : %s exit_code : %d stdout : %s", target, cmd.ProcessState.ExitCode(), string(stdout))
 
 	var r types.Report
 	err = json.NewDecoder(reportFile).Decode(&r)
@@ -70,16 +134,17 @@ func (t *TC) Scan(imageRef string) (report *types.Report, err error) {
 	return &r, err
 }
 
-func (t *TC) prepareScanCmd(imageRef string, outputFile string) (*exec.Cmd, error) {
+func (t *TC) prepareScanCmd(subcommand, target string, outputFile string, extraArgs ...string) (*exec.Cmd, error) {
 	args := []string{
-		"image",
+		subcommand,
 		"--server", t.Server,
 		"--severity", "CRITICAL,HIGH,MEDIUM,LOW",
 		"--ignore-unfixed",
 		"--format", trivyoutput,
 		"--output", outputFile,
-		imageRef,
 	}
+	args = append(args, extraArgs...)
+	args = append(args, target)
 
 	name, err := t.mgr.LookPath(trivyCmd)
 	if err != nil {
@@ -112,6 +177,23 @@ func (t *TC) GetVersion() (*types.VersionInfo, error) {
 	return &vi, nil
 }
 
+// Version is GetVersion for callers on the request path, such as health checks
+// and the index page, which would otherwise start trivy on every request.
+func (t *TC) Version() (*types.VersionInfo, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.version != nil && time.Since(t.versionAt) < versionMaxAge {
+		return t.version, nil
+	}
+	vi, err := t.GetVersion()
+	if err != nil {
+		return nil, err
+	}
+	t.version, t.versionAt = vi, time.Now()
+	return vi, nil
+}
+
 func (t *TC) prepareVersionCmd() (*exec.Cmd, error) {
 	args := []string{
 		"--version",
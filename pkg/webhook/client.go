@@ -4,10 +4,17 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/trivy-web-dash/types"
 )
 
+// requestTimeout bounds each delivery, the worker holds the scan job until the
+// webhook answers.
+const requestTimeout = 30 * time.Second
+
+var httpClient = &http.Client{Timeout: requestTimeout}
+
 func Do(url string, report types.Report) (*int, error) {
 	buf := new(bytes.Buffer)
 	err := json.NewEncoder(buf).Encode(report)
@@ -20,7 +27,7 @@ func Do(url string, report types.Report) (*int, error) {
 		return nil, err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nil, err
 
@@ -0,0 +1,34 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/trivy-web-dash/types"
+)
+
+func TestDo(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hang" {
+			<-release
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+
+	status, err := Do(srv.URL+"/ok", types.Report{ArtifactName: "alpine:3.19"})
+	if err != nil || *status != http.StatusAccepted {
+		t.Errorf("Do() = %v, %v", status, err)
+	}
+
+	timeout := httpClient.Timeout
+	httpClient.Timeout = 50 * time.Millisecond
+	t.Cleanup(func() { httpClient.Timeout = timeout })
+	if _, err := Do(srv.URL+"/hang", types.Report{}); err == nil {
+		t.Error("Do() of a hanging webhook succeeded")
+	}
+}
@@ -0,0 +1,250 @@
+package regopolicy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/trivy-web-dash/pkg/db"
+	redisx "github.com/trivy-web-dash/pkg/db/redis"
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/pkg/reference"
+	"github.com/trivy-web-dash/types"
+)
+
+const keyPrefix = "rego/"
+
+var (
+	ErrNotFound = errors.New("rego policy not found")
+	validName   = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+)
+
+// Policy is an uploaded Rego module. It is evaluated after every scan with
+// input {"image": {...}, "report": types.Report} and may define "deny" and
+// "warn" rules producing strings or objects with a "msg" field.
+type Policy struct {
+	Name    string `json:"name"`
+	Package string `json:"package"`
+	Module  string `json:"module"`
+}
+
+type RegoClient struct {
+	client db.Store
+	log    logger.Logger
+}
+
+var regoClient *RegoClient
+
+func NewRegoClient(redisURI, redisPass string, redisDB int, redisTLS, redisTLSSkipVerify bool, log logger.Logger) error {
+	pool, err := redisx.NewPool(redisURI, redisPass, redisDB, redisTLS, redisTLSSkipVerify)
+	if err != nil {
+		return err
+	}
+
+	regoClient = &RegoClient{client: redisx.NewStore(pool), log: log}
+	return nil
+}
+
+func GetRegoClient() *RegoClient {
+	return regoClient
+}
+
+// Put validates and stores a module under name, replacing any previous version.
+func (c *RegoClient) Put(ctx context.Context, name, module string) (Policy, error) {
+	if !validName.MatchString(name) {
+		return Policy{}, fmt.Errorf("invalid policy name %q", name)
+	}
+
+	mod, err := ast.ParseModule(name+".rego", module)
+	if err != nil {
+		return Policy{}, err
+	}
+	p := Policy{Name: name, Package: mod.Package.Path.String(), Module: module}
+
+	// compile together with the stored policies so conflicting packages are
+	// rejected on upload rather than failing every scan
+	policies, err := c.List(ctx)
+	if err != nil {
+		return Policy{}, err
+	}
+	modules := map[string]string{p.Name + ".rego": p.Module}
+	for _, other := range policies {
+		if other.Name != name {
+			modules[other.Name+".rego"] = other.Module
+		}
+	}
+	if _, err := ast.CompileModules(modules); err != nil {
+		return Policy{}, err
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return Policy{}, err
+	}
+	if err := c.client.WithContext(ctx).Set(keyPrefix+name, b); err != nil {
+		c.log.Error(err)
+		return Policy{}, err
+	}
+	return p, nil
+}
+
+func (c *RegoClient) Get(ctx context.Context, name string) (Policy, error) {
+	b, _, err := c.client.WithContext(ctx).GetwithTTL(keyPrefix + name)
+	if errors.Is(err, redis.ErrNil) {
+		return Policy{}, ErrNotFound
+	}
+	if err != nil {
+		c.log.Error(err)
+		return Policy{}, err
+	}
+
+	var p Policy
+	if err := json.Unmarshal(b, &p); err != nil {
+		return Policy{}, err
+	}
+	return p, nil
+}
+
+func (c *RegoClient) Delete(ctx context.Context, name string) error {
+	if _, err := c.Get(ctx, name); err != nil {
+		return err
+	}
+	return c.client.WithContext(ctx).Delete(keyPrefix + name)
+}
+
+func (c *RegoClient) List(ctx context.Context) ([]Policy, error) {
+	keys, err := c.client.WithContext(ctx).GetAllKeys(keyPrefix + "*")
+	if err != nil {
+		c.log.Error(err)
+		return nil, err
+	}
+
+	policies := []Policy{}
+	for _, key := range keys {
+		p, err := c.Get(ctx, strings.TrimPrefix(key, keyPrefix))
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Name < policies[j].Name })
+	return policies, nil
+}
+
+// Evaluate runs every stored policy against the report of image. A policy that
+// fails to evaluate is reported in its result rather than failing the others.
+func (c *RegoClient) Evaluate(ctx context.Context, image string, report types.Report) ([]types.PolicyResult, error) {
+	policies, err := c.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.evaluate(ctx, policies, image, report)
+}
+
+func (c *RegoClient) evaluate(ctx context.Context, policies []Policy, image string, report types.Report) ([]types.PolicyResult, error) {
+	if len(policies) == 0 {
+		return nil, nil
+	}
+
+	input, err := newInput(image, report)
+	if err != nil {
+		return nil, err
+	}
+
+	var modules []func(*rego.Rego)
+	for _, p := range policies {
+		modules = append(modules, rego.Module(p.Name+".rego", p.Module))
+	}
+
+	results := make([]types.PolicyResult, 0, len(policies))
+	for _, p := range policies {
+		result := types.PolicyResult{Policy: p.Name, Deny: []string{}, Warn: []string{}}
+		for _, rule := range []string{"deny", "warn"} {
+			opts := append([]func(*rego.Rego){
+				rego.Query(p.Package + "." + rule),
+				rego.Input(input),
+			}, modules...)
+
+			rs, err := rego.New(opts...).Eval(ctx)
+			if err != nil {
+				c.log.Errorf("evaluating rego policy %s : %v", p.Name, err)
+				result.Error = err.Error()
+				break
+			}
+
+			msgs := messages(rs)
+			if rule == "deny" {
+				result.Deny = msgs
+			} else {
+				result.Warn = msgs
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// newInput round-trips the report through JSON so policies see the same field
+// names as the API.
+func newInput(image string, report types.Report) (map[string]interface{}, error) {
+	b, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	var r interface{}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+
+	img := map[string]interface{}{"name": image}
+	if ref, err := reference.Parse(image); err == nil {
+		img["registry"] = ref.Registry
+		img["repository"] = ref.Repository
+		img["tag"] = ref.Tag
+		img["digest"] = ref.Digest
+	}
+
+	return map[string]interface{}{"image": img, "report": r}, nil
+}
+
+// messages flattens the values of an undefined, set or array rule into strings.
+func messages(rs rego.ResultSet) []string {
+	msgs := []string{}
+	for _, r := range rs {
+		for _, expr := range r.Expressions {
+			values, ok := expr.Value.([]interface{})
+			if !ok {
+				values = []interface{}{expr.Value}
+			}
+			for _, v := range values {
+				msgs = append(msgs, message(v))
+			}
+		}
+	}
+	sort.Strings(msgs)
+	return msgs
+}
+
+func message(v interface{}) string {
+	switch m := v.(type) {
+	case string:
+		return m
+	case map[string]interface{}:
+		if msg, ok := m["msg"].(string); ok {
+			return msg
+		}
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,163 @@
+package regopolicy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/types"
+)
+
+const banned = `package org.banned
+
+deny[msg] {
+	v := input.report.Results[_].Vulnerabilities[_]
+	v.PkgName == "log4j-core"
+	msg := sprintf("log4j-core is banned (%s)", [v.InstalledVersion])
+}
+
+warn[{"msg": msg}] {
+	input.image.registry != "registry.example.com"
+	msg := sprintf("%s is not from the internal registry", [input.image.repository])
+}
+`
+
+// conflict fails at evaluation time: deny is a complete rule with two values.
+const conflict = `package org.conflict
+
+deny := "a" { true }
+deny := "b" { input.image.tag == "1.0" }
+`
+
+func newClient(t *testing.T) *RegoClient {
+	t.Helper()
+	redis := miniredis.RunT(t)
+	log := logger.NewAppLogger("fatal")
+	log.InitLogger()
+	if err := NewRegoClient(redis.Addr(), "", 0, false, false, log); err != nil {
+		t.Fatal(err)
+	}
+	return GetRegoClient()
+}
+
+func TestPut(t *testing.T) {
+	c := newClient(t)
+	ctx := context.Background()
+
+	p, err := c.Put(ctx, "banned", banned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Package != "data.org.banned" {
+		t.Errorf("package = %q, want data.org.banned", p.Package)
+	}
+
+	tests := []struct {
+		name   string
+		module string
+		err    string
+	}{
+		{"bad/name", banned, "invalid policy name"},
+		{"syntax", "package x\ndeny[msg] {", "rego_parse_error"},
+		{"clash", "package org.banned\n\ndeny := 1", "rego_type_error"},
+	}
+	for _, tt := range tests {
+		if _, err := c.Put(ctx, tt.name, tt.module); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Put(%s) error = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+
+	// replacing a policy is no conflict with its previous version
+	if _, err := c.Put(ctx, "banned", banned); err != nil {
+		t.Errorf("replacing a policy: %v", err)
+	}
+	if policies, _ := c.List(ctx); len(policies) != 1 {
+		t.Errorf("List() = %+v, want only banned", policies)
+	}
+
+	if err := c.Delete(ctx, "banned"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(ctx, "banned"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleting twice = %v, want ErrNotFound", err)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	c := newClient(t)
+	ctx := context.Background()
+
+	if results, err := c.Evaluate(ctx, "alpine:3.19", types.Report{}); err != nil || results != nil {
+		t.Errorf("without policies Evaluate() = %v, %v", results, err)
+	}
+
+	for name, module := range map[string]string{"banned": banned, "conflict": conflict} {
+		if _, err := c.Put(ctx, name, module); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report := types.Report{Results: []types.Result{{Vulnerabilities: []types.Vulnerability{
+		{VulnerabilityID: "CVE-2021-44228", PkgName: "log4j-core", InstalledVersion: "2.14.1"},
+		{VulnerabilityID: "CVE-2024-0001", PkgName: "openssl", InstalledVersion: "3.1.4"},
+	}}}}
+
+	tests := []struct {
+		image string
+		deny  []string
+		warn  []string
+	}{
+		{"docker.io/library/app:1.0", []string{"log4j-core is banned (2.14.1)"}, []string{"library/app is not from the internal registry"}},
+		{"registry.example.com/team/app:2.0", []string{"log4j-core is banned (2.14.1)"}, []string{}},
+	}
+	for _, tt := range tests {
+		results, err := c.Evaluate(ctx, tt.image, report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 || results[0].Policy != "banned" || results[1].Policy != "conflict" {
+			t.Fatalf("%s: results = %+v", tt.image, results)
+		}
+
+		b := results[0]
+		if b.Error != "" || strings.Join(b.Deny, "|") != strings.Join(tt.deny, "|") || strings.Join(b.Warn, "|") != strings.Join(tt.warn, "|") {
+			t.Errorf("%s: banned = %+v, want deny %q warn %q", tt.image, b, tt.deny, tt.warn)
+		}
+	}
+
+	// a broken policy is reported on its own result only
+	results, _ := c.Evaluate(ctx, "registry.example.com/team/app:1.0", report)
+	if results[1].Error == "" {
+		t.Errorf("conflicting policy evaluated without error: %+v", results[1])
+	}
+	if results[0].Error != "" || len(results[0].Deny) != 1 {
+		t.Errorf("other policy affected by the broken one: %+v", results[0])
+	}
+}
+
+func TestNewInput(t *testing.T) {
+	in, err := newInput("registry.example.com/team/app:1.0@sha256:abcd", types.Report{ArtifactName: "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := in["image"].(map[string]interface{})
+	want := map[string]string{
+		"name":       "registry.example.com/team/app:1.0@sha256:abcd",
+		"registry":   "registry.example.com",
+		"repository": "team/app",
+		"tag":        "1.0",
+		"digest":     "sha256:abcd",
+	}
+	for k, v := range want {
+		if img[k] != v {
+			t.Errorf("image.%s = %v, want %s", k, img[k], v)
+		}
+	}
+	if in["report"].(map[string]interface{})["ArtifactName"] != "app" {
+		t.Errorf("report is not keyed like the API: %v", in["report"])
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/trivy-web-dash/pkg/config"
+	"github.com/trivy-web-dash/pkg/kube"
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/pkg/policy"
+	"github.com/trivy-web-dash/pkg/project"
+	"github.com/trivy-web-dash/pkg/pushevent"
+	"github.com/trivy-web-dash/pkg/queue"
+	"github.com/trivy-web-dash/pkg/rbac"
+	"github.com/trivy-web-dash/pkg/registry"
+)
+
+// reloadables are the parts of the running application a SIGHUP updates in
+// place. syncer is nil without a kubernetes inventory.
+type reloadables struct {
+	log interface {
+		logger.Logger
+		SetLevel(level string) error
+	}
+	projects *project.Set
+	policies *policy.Set
+	crawler  *registry.Crawler
+	hooks    *pushevent.Set
+	worker   queue.Worker
+	syncer   *kube.Syncer
+}
+
+// reload applies the log level, the project, policy, registry and rbac files,
+// the push receivers and the inventory interval of cfg. A file that fails to load keeps its
+// previous contents, the other settings still apply.
+func reload(cfg *config.Config, r reloadables) {
+	if err := r.log.SetLevel(cfg.LogLevel); err != nil {
+		r.log.Errorf("reloading log level: %v", err)
+	}
+
+	projects, err := loadProjects(cfg.Files.Projects)
+	if err == nil {
+		err = r.projects.Replace(projects)
+	}
+	if err != nil {
+		r.log.Errorf("reloading projects: %v", err)
+	}
+
+	if policies, err := loadPolicies(cfg.Files.Policies); err != nil {
+		r.log.Errorf("reloading policies: %v", err)
+	} else {
+		r.policies.Replace(policies)
+	}
+
+	if grants, err := loadRBAC(cfg.Files.RBAC); err != nil {
+		r.log.Errorf("reloading rbac grants: %v", err)
+	} else {
+		rbac.SetPolicy(grants)
+	}
+
+	if targets, err := loadTargets(cfg.Files.Registries, r.projects); err != nil {
+		r.log.Errorf("reloading registry targets: %v", err)
+	} else {
+		schedules := r.crawler.Schedules()
+		r.crawler.Replace(targets)
+		// periodic jobs are fixed when the worker pool starts
+		if !reflect.DeepEqual(schedules, r.crawler.Schedules()) {
+			r.worker.Reload()
+		}
+	}
+
+	if hooks, err := loadHooks(cfg.Hooks, r.projects); err != nil {
+		r.log.Errorf("reloading push receivers: %v", err)
+	} else {
+		r.hooks.Replace(hooks)
+	}
+
+	if r.syncer != nil {
+		r.syncer.SetInterval(cfg.Kube.Interval)
+	}
+	r.log.Info("configuration reloaded")
+}
+
+// loadProjects reads the project file, only the default project exists
+// without one.
+func loadProjects(path string) (*project.Set, error) {
+	if path == "" {
+		return project.NewSet(), nil
+	}
+	return project.Load(path)
+}
+
+// loadPolicies reads the policy file, only the built-in policies exist
+// without one.
+func loadPolicies(path string) (*policy.Set, error) {
+	if path == "" {
+		return policy.NewSet(), nil
+	}
+	return policy.Load(path)
+}
+
+// loadRBAC reads the grants file, a nil policy allows everything.
+func loadRBAC(path string) (*rbac.Policy, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return rbac.Load(path)
+}
+
+// loadTargets reads the registry file and checks that every target scans
+// into a known project.
+func loadTargets(path string, projects *project.Set) ([]registry.Target, error) {
+	if path == "" {
+		return nil, nil
+	}
+	targets, err := registry.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range targets {
+		if _, ok := projects.Get(t.Project); !ok {
+			return nil, fmt.Errorf("registry target %s: unknown project %s", t.Name, t.Project)
+		}
+	}
+	return targets, nil
+}
+
+// loadHooks enables the push receivers whose secret is set and checks that
+// each is bound to a known project.
+func loadHooks(h config.Hooks, projects *project.Set) ([]pushevent.Hook, error) {
+	var hooks []pushevent.Hook
+	if h.RegistryToken != "" {
+		hooks = append(hooks, pushevent.Hook{Receiver: pushevent.NewDistribution(h.RegistryToken, h.RegistryHost), Project: h.RegistryProject})
+	}
+	if h.HarborAuth != "" {
+		hooks = append(hooks, pushevent.Hook{Receiver: pushevent.NewHarbor(h.HarborAuth, h.HarborHost), Project: h.HarborProject})
+	}
+	if h.GHCRSecret != "" {
+		hooks = append(hooks, pushevent.Hook{Receiver: pushevent.NewGHCR(h.GHCRSecret), Project: h.GHCRProject})
+	}
+	if h.GitLabToken != "" {
+		hooks = append(hooks, pushevent.Hook{Receiver: pushevent.NewGitLab(h.GitLabToken, h.GitLabHost), Project: h.GitLabProject})
+	}
+	for _, hook := range hooks {
+		if _, ok := projects.Get(hook.Project); !ok {
+			return nil, fmt.Errorf("hooks.%sProject %s is not a known project", hook.Receiver.Name(), hook.Project)
+		}
+	}
+	return hooks, nil
+}
@@ -0,0 +1,62 @@
+package report
+
+import (
+	"context"
+	"time"
+
+	"github.com/trivy-web-dash/exception"
+	"github.com/trivy-web-dash/types"
+	"github.com/trivy-web-dash/vex"
+)
+
+// Overlay holds the triage decisions layered over stored reports at read time:
+// active exception rules and uploaded VEX documents. Stored reports and
+// summaries keep raw counts, so expired rules or replaced documents need no
+// cleanup.
+type Overlay struct {
+	rules []exception.Rule
+	docs  []vex.Document
+}
+
+// LoadOverlay fetches the current exception rules of project and the VEX
+// documents.
+func LoadOverlay(ctx context.Context, project string) (*Overlay, error) {
+	rules, err := exception.GetExceptionClient().Active(ctx, project, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	docs, err := vex.GetVEXClient().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Overlay{rules: rules, docs: docs}, nil
+}
+
+// Covers reports whether any rule or statement could apply to image.
+func (o *Overlay) Covers(image string) bool {
+	if o == nil {
+		return false
+	}
+	return exception.AnyMatchImage(o.rules, image) || vex.AnyMatchImage(o.docs, image)
+}
+
+// Apply marks the findings of r and returns how many were touched.
+func (o *Overlay) Apply(image string, r *types.Report) int {
+	if o == nil {
+		return 0
+	}
+	return vex.Apply(o.docs, image, r) + exception.Apply(o.rules, image, r)
+}
+
+// Annotate applies the current overlay of project to a single report. Rules
+// match the image r was stored under, however it was looked up.
+func Annotate(ctx context.Context, project string, r *types.Report) error {
+	o, err := LoadOverlay(ctx, project)
+	if err != nil {
+		return err
+	}
+	o.Apply(r.ArtifactKey(), r)
+	return nil
+}
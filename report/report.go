@@ -5,9 +5,12 @@ import (
 	"context"
 	"encoding/gob"
 	"encoding/json"
+	"errors"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/gomodule/redigo/redis"
 	"github.com/trivy-web-dash/pkg/db"
 	redisx "github.com/trivy-web-dash/pkg/db/redis"
 	"github.com/trivy-web-dash/pkg/logger"
@@ -17,19 +20,21 @@ import (
 type ReportClient struct {
 	client db.Store
 	log    logger.Logger
+	// ttl is how long reports are kept after their last scan.
+	ttl time.Duration
 }
 
-const expirationTime = 2000 * time.Hour
+var ErrNotFound = errors.New("report not found")
 
 var reportClient *ReportClient
 
-func NewReportClient(redisURI, redisPass string, redisTLS, redisTLSSkipVerify bool, log logger.Logger) error {
-	pool, err := redisx.NewPool(redisURI, redisPass, "1", redisTLS, redisTLSSkipVerify)
+func NewReportClient(redisURI, redisPass string, redisDB int, redisTLS, redisTLSSkipVerify bool, ttl time.Duration, log logger.Logger) error {
+	pool, err := redisx.NewPool(redisURI, redisPass, redisDB, redisTLS, redisTLSSkipVerify)
 	if err != nil {
 		return err
 	}
 
-	reportClient = &ReportClient{client: redisx.NewStore(pool), log: log}
+	reportClient = &ReportClient{client: redisx.NewStore(pool), log: log, ttl: ttl}
 	return nil
 }
 
@@ -37,9 +42,27 @@ func GetReportClient() *ReportClient {
 	return reportClient
 }
 
-func (c *ReportClient) Get(ctx context.Context, image string) (types.Report, time.Duration, error) {
+// Age converts the remaining ttl of a stored report into the time since it
+// was saved.
+func (c *ReportClient) Age(ttl time.Duration) time.Duration {
+	return c.ttl - ttl
+}
+
+// Get returns the report of image in project, where image may be a tag, a
+// "repo@sha256:..." reference or a bare "sha256:..." digest.
+func (c *ReportClient) Get(ctx context.Context, project, image string) (types.Report, time.Duration, error) {
 	key := strings.TrimPrefix(image, "/")
-	value, ttl, err := c.client.GetwithTTL("vulndb/" + key)
+	if strings.HasPrefix(key, "sha256:") {
+		ref, err := c.findDigest(ctx, project, key)
+		if err != nil {
+			return types.Report{}, 0, err
+		}
+		key = ref
+	}
+	value, ttl, err := c.client.WithContext(ctx).GetwithTTL("vulndb/" + types.ProjectKey(project, key))
+	if errors.Is(err, redis.ErrNil) {
+		return types.Report{}, 0, ErrNotFound
+	}
 	if err != nil {
 		c.log.Error(err)
 		return types.Report{}, 0, err
@@ -56,8 +79,11 @@ func (c *ReportClient) Get(ctx context.Context, image string) (types.Report, tim
 
 func (c *ReportClient) Set(ctx context.Context, report types.Report) error {
 	var b bytes.Buffer
-	target := report.Results[0].Target
-	reg := strings.Split(target, " ")
+	key := report.ArtifactKey()
+	if key == "" {
+		return errors.New("report has no artifact name")
+	}
+	key = types.ProjectKey(report.Project, key)
 
 	if err := gob.NewEncoder(&b).Encode(report); err != nil {
 		c.log.Error(err)
@@ -70,10 +96,80 @@ func (c *ReportClient) Set(ctx context.Context, report types.Report) error {
 		return err
 	}
 
-	if err := c.client.SetwithTTL(reg[0], jbytes, expirationTime); err != nil {
+	if err := c.client.WithContext(ctx).SetwithTTL(key, jbytes, c.ttl); err != nil {
 		c.log.Error(err)
 		return err
 	}
 
+	if digestKey := types.ProjectKey(report.Project, report.DigestKey()); report.DigestKey() != "" && digestKey != key {
+		if err := c.client.WithContext(ctx).SetwithTTL(digestKey, jbytes, c.ttl); err != nil {
+			c.log.Error(err)
+			return err
+		}
+	}
+
 	return nil
 }
+
+// findDigest returns the "repo@digest" key a digest was stored under in project.
+func (c *ReportClient) findDigest(ctx context.Context, project, digest string) (string, error) {
+	keys, err := c.keys(ctx, project, "*@"+digest)
+	if err != nil {
+		return "", err
+	}
+	if len(keys) == 0 {
+		return "", ErrNotFound
+	}
+	sort.Strings(keys)
+	return keys[0], nil
+}
+
+// keys returns the unprefixed keys of project matching pattern. The pattern of
+// the default project also matches the keys of every other project, which are
+// left out.
+func (c *ReportClient) keys(ctx context.Context, project, pattern string) ([]string, error) {
+	keys, err := c.client.WithContext(ctx).GetAllKeys("vulndb/" + types.ProjectKey(project, pattern))
+	if err != nil {
+		c.log.Error(err)
+		return nil, err
+	}
+
+	var out []string
+	for _, key := range keys {
+		p, key := types.SplitProjectKey(strings.TrimPrefix(key, "vulndb/"))
+		if p == types.ProjectName(project) {
+			out = append(out, key)
+		}
+	}
+	return out, nil
+}
+
+// Combine merges the stored per-platform reports of image in project into one
+// report.
+func (c *ReportClient) Combine(ctx context.Context, project, image string) (types.Report, error) {
+	keys, err := c.keys(ctx, project, types.PlatformKey(image, "*"))
+	if err != nil {
+		return types.Report{}, err
+	}
+
+	var reports []types.Report
+	for _, key := range keys {
+		if parent, platform := types.SplitPlatformKey(key); parent != image || platform == "" {
+			continue
+		}
+		r, _, err := c.Get(ctx, project, key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return types.Report{}, err
+		}
+		reports = append(reports, r)
+	}
+	if len(reports) == 0 {
+		return types.Report{}, ErrNotFound
+	}
+	combined := types.Combine(image, reports)
+	combined.Project = reports[0].Project
+	return combined, nil
+}
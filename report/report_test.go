@@ -0,0 +1,99 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/types"
+)
+
+func newTestClient(t *testing.T) *ReportClient {
+	t.Helper()
+	redis := miniredis.RunT(t)
+	log := logger.NewAppLogger("fatal")
+	log.InitLogger()
+	if err := NewReportClient(redis.Addr(), "", 1, false, false, time.Hour, log); err != nil {
+		t.Fatal(err)
+	}
+	return GetReportClient()
+}
+
+func TestGetByDigest(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	for _, r := range []types.Report{
+		{ArtifactName: "app:1.0", Metadata: &types.ImageMetadata{RepoDigests: []string{"app@sha256:aaaa"}}},
+		// the tag moved, the previous build stays reachable by digest
+		{ArtifactName: "app:1.0", Metadata: &types.ImageMetadata{RepoDigests: []string{"app@sha256:bbbb"}}},
+		{ArtifactName: "app:1.0", Project: "payments", Metadata: &types.ImageMetadata{RepoDigests: []string{"app@sha256:cccc"}}},
+	} {
+		r.ResolveDigest()
+		if err := c.Set(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		project, image, digest string
+	}{
+		{"", "app:1.0", "sha256:bbbb"},
+		{"", "app@sha256:aaaa", "sha256:aaaa"},
+		{"", "sha256:aaaa", "sha256:aaaa"},
+		{"", "/sha256:bbbb", "sha256:bbbb"},
+		{"payments", "app:1.0", "sha256:cccc"},
+		{"payments", "sha256:cccc", "sha256:cccc"},
+	}
+	for _, tt := range tests {
+		r, _, err := c.Get(ctx, tt.project, tt.image)
+		if err != nil || r.Digest != tt.digest {
+			t.Errorf("Get(%q, %q) = %q, %v, want %q", tt.project, tt.image, r.Digest, err, tt.digest)
+		}
+	}
+
+	// digests are looked up within the project only
+	for _, tt := range []struct{ project, image string }{{"", "sha256:cccc"}, {"payments", "sha256:aaaa"}, {"", "app:2.0"}} {
+		if _, _, err := c.Get(ctx, tt.project, tt.image); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q, %q) = %v, want ErrNotFound", tt.project, tt.image, err)
+		}
+	}
+
+	if err := c.Set(ctx, types.Report{}); err == nil {
+		t.Error("Set() of a report without artifact name succeeded")
+	}
+}
+
+func TestCombine(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	for _, r := range []types.Report{
+		{ArtifactName: types.PlatformKey("app:1.2", "linux/amd64"), Project: "payments"},
+		{ArtifactName: types.PlatformKey("app:1.2", "linux/arm64"), Project: "payments"},
+		// another image sharing the prefix, and another project
+		{ArtifactName: types.PlatformKey("app:1.2-debug", "linux/amd64"), Project: "payments"},
+		{ArtifactName: types.PlatformKey("app:1.2", "linux/s390x")},
+	} {
+		if err := c.Set(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := c.Combine(ctx, "payments", "app:1.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ArtifactName != "app:1.2" || r.Project != "payments" || len(r.Platforms) != 2 ||
+		r.Platforms[0].Platform != "linux/amd64" || r.Platforms[1].Platform != "linux/arm64" {
+		t.Errorf("Combine() = %+v", r)
+	}
+
+	if _, err := c.Combine(ctx, "payments", "nginx:1.25"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Combine() of an image without platform reports = %v", err)
+	}
+}
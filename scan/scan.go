@@ -7,16 +7,26 @@ import (
 	"net/http"
 )
 
-func GetScanStatus(trivyWebScannerStatusURL string) ([]byte, error) {
-	return makeRequest("GET", trivyWebScannerStatusURL, nil)
+// forwardedHeaders carry the caller's credentials to the status endpoint.
+var forwardedHeaders = []string{"Authorization", "X-API-Key", "Cookie"}
+
+// GetScanStatus fetches the job counts, authenticating as the request whose
+// header is passed.
+func GetScanStatus(trivyWebScannerStatusURL string, header http.Header) ([]byte, error) {
+	return makeRequest("GET", trivyWebScannerStatusURL, nil, header)
 }
 
-func makeRequest(method, url string, reqBytes []byte) (responseBytes []byte, err error) {
+func makeRequest(method, url string, reqBytes []byte, header http.Header) (responseBytes []byte, err error) {
 	var httpClient = new(http.Client)
 	req, err := http.NewRequest(method, url, bytes.NewBuffer(reqBytes))
 	if err != nil {
 		return nil, errors.New("failed to create new http request: " + err.Error())
 	}
+	for _, h := range forwardedHeaders {
+		if v := header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/gob"
+	"errors"
 	"strings"
 	"time"
 
@@ -17,19 +18,19 @@ import (
 type SummaryClient struct {
 	client db.Store
 	log    logger.Logger
+	// ttl is how long reports are kept after their last scan.
+	ttl time.Duration
 }
 
 var summaryClient *SummaryClient
 
-const expirationTime = 2000 * time.Hour
-
-func NewSummaryClient(redisURI, redisPass string, redisTLS, redisTLSSkipVerify bool, log logger.Logger) error {
-	pool, err := redisx.NewPool(redisURI, redisPass, "2", redisTLS, redisTLSSkipVerify)
+func NewSummaryClient(redisURI, redisPass string, redisDB int, redisTLS, redisTLSSkipVerify bool, ttl time.Duration, log logger.Logger) error {
+	pool, err := redisx.NewPool(redisURI, redisPass, redisDB, redisTLS, redisTLSSkipVerify)
 	if err != nil {
 		return err
 	}
 
-	summaryClient = &SummaryClient{client: redisx.NewStore(pool), log: log}
+	summaryClient = &SummaryClient{client: redisx.NewStore(pool), log: log, ttl: ttl}
 	return nil
 }
 
@@ -41,9 +42,11 @@ func GetSummaryClient() *SummaryClient {
 	return summaryClient
 }
 
-func (c *SummaryClient) GetAll(ctx context.Context) ([]types.Summary, error) {
+// GetAll returns the summaries of every image of project visible reports true
+// for. A nil visible returns all of them.
+func (c *SummaryClient) GetAll(ctx context.Context, project string, visible func(image string) bool) ([]types.Summary, error) {
 	var result []types.Summary
-	keys, err := c.client.GetAllKeys("vulndb*")
+	keys, err := c.client.WithContext(ctx).GetAllKeys("vulndb/" + types.ProjectKey(project, "*"))
 	if err != nil {
 		c.log.Error(err)
 		return nil, err
@@ -51,7 +54,15 @@ func (c *SummaryClient) GetAll(ctx context.Context) ([]types.Summary, error) {
 
 	if len(keys) != 0 {
 		for _, key := range keys {
-			keyBytes, ttl, err := c.client.GetwithTTL(key)
+			// the default project's pattern matches every other project too
+			p, image := types.SplitProjectKey(strings.TrimPrefix(key, "vulndb/"))
+			if p != types.ProjectName(project) {
+				continue
+			}
+			if visible != nil && !visible(image) {
+				continue
+			}
+			keyBytes, ttl, err := c.client.WithContext(ctx).GetwithTTL(key)
 			if err != nil {
 				c.log.Error(err)
 				return nil, err
@@ -65,9 +76,9 @@ func (c *SummaryClient) GetAll(ctx context.Context) ([]types.Summary, error) {
 			}
 
 			r := types.Summary{
-				Image:    key,
+				Image:    image,
 				VSummary: s,
-				LastScan: util.ConvertToHumanReadable(expirationTime - ttl),
+				LastScan: util.ConvertToHumanReadable(c.ttl - ttl),
 			}
 			result = append(result, r)
 		}
@@ -77,7 +88,7 @@ func (c *SummaryClient) GetAll(ctx context.Context) ([]types.Summary, error) {
 }
 
 func (c *SummaryClient) Get(ctx context.Context, key string) (map[string]int, error) {
-	value, _, err := c.client.GetwithTTL(key)
+	value, _, err := c.client.WithContext(ctx).GetwithTTL(key)
 	if err != nil {
 		c.log.Error(err)
 		return nil, err
@@ -95,8 +106,11 @@ func (c *SummaryClient) Get(ctx context.Context, key string) (map[string]int, er
 func (c *SummaryClient) Set(ctx context.Context, report types.Report) error {
 	var b bytes.Buffer
 	var summary = map[string]int{}
-	target := report.Results[0].Target
-	reg := strings.Split(target, " ")
+	key := report.ArtifactKey()
+	if key == "" {
+		return errors.New("report has no artifact name")
+	}
+	key = types.ProjectKey(report.Project, key)
 
 	for _, t := range report.Results {
 		for _, v := range t.Vulnerabilities {
@@ -113,7 +127,7 @@ func (c *SummaryClient) Set(ctx context.Context, report types.Report) error {
 		return err
 	}
 
-	if err := c.client.SetwithTTL(reg[0], b.Bytes(), expirationTime); err != nil {
+	if err := c.client.WithContext(ctx).SetwithTTL(key, b.Bytes(), c.ttl); err != nil {
 		c.log.Error(err)
 		return err
 	}
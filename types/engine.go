@@ -0,0 +1,89 @@
+package types
+
+import "sort"
+
+// Scan engines. A report without Engine was scanned by trivy.
+const (
+	EngineTrivy = "trivy"
+	EngineGrype = "grype"
+	// EngineBoth scans with trivy and attaches a comparison with grype.
+	EngineBoth = "both"
+)
+
+// ValidEngine reports whether scans can be requested with engine, empty
+// selects the configured default.
+func ValidEngine(engine string) bool {
+	switch engine {
+	case "", EngineTrivy, EngineGrype, EngineBoth:
+		return true
+	}
+	return false
+}
+
+// ComparedFinding is a finding the engines of a comparison disagree on.
+type ComparedFinding struct {
+	VulnerabilityID  string `json:"VulnerabilityID"`
+	PkgName          string `json:"PkgName"`
+	InstalledVersion string `json:"InstalledVersion"`
+	// Severities maps each engine that found it to the severity it gave.
+	Severities map[string]string `json:"Severities"`
+}
+
+// Comparison sets the findings of a second engine against those of the
+// report it is attached to.
+type Comparison struct {
+	Engines []string `json:"Engines"`
+	// Agreed counts the findings both engines reported with the same severity.
+	Agreed   int               `json:"Agreed"`
+	Disputed []ComparedFinding `json:"Disputed"`
+}
+
+// EngineName is the engine that produced the report.
+func (r Report) EngineName() string {
+	if r.Engine == "" {
+		return EngineTrivy
+	}
+	return r.Engine
+}
+
+// Compare matches the findings of r and other by vulnerability, package and
+// installed version, regardless of the target they were found in.
+func Compare(r, other Report) *Comparison {
+	ours, theirs := r.EngineName(), other.EngineName()
+	c := &Comparison{Engines: []string{ours, theirs}}
+
+	findings := map[string]*ComparedFinding{}
+	var keys []string
+	add := func(engine string, report Report) {
+		for _, res := range report.Results {
+			for _, v := range res.Vulnerabilities {
+				key := v.VulnerabilityID + "|" + v.PkgName + "|" + v.InstalledVersion
+				f, ok := findings[key]
+				if !ok {
+					f = &ComparedFinding{
+						VulnerabilityID:  v.VulnerabilityID,
+						PkgName:          v.PkgName,
+						InstalledVersion: v.InstalledVersion,
+						Severities:       map[string]string{},
+					}
+					findings[key] = f
+					keys = append(keys, key)
+				}
+				f.Severities[engine] = v.Severity
+			}
+		}
+	}
+	add(ours, r)
+	add(theirs, other)
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		f := findings[key]
+		if len(f.Severities) == 2 && f.Severities[ours] == f.Severities[theirs] {
+			c.Agreed++
+			continue
+		}
+		c.Disputed = append(c.Disputed, *f)
+	}
+	return c
+}
@@ -0,0 +1,162 @@
+package types
+
+import (
+	"strings"
+	"time"
+)
+
+type OS struct {
+	Family string `json:"Family"`
+	Name   string `json:"Name"`
+	EOSL   bool   `json:"EOSL,omitempty"`
+}
+
+type History struct {
+	Created    *time.Time `json:"created,omitempty"`
+	CreatedBy  string     `json:"created_by,omitempty"`
+	Comment    string     `json:"comment,omitempty"`
+	EmptyLayer bool       `json:"empty_layer,omitempty"`
+}
+
+type RootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids"`
+}
+
+// Config is the runtime configuration of an image.
+type Config struct {
+	User         string              `json:"User,omitempty"`
+	Env          []string            `json:"Env,omitempty"`
+	Entrypoint   []string            `json:"Entrypoint,omitempty"`
+	Cmd          []string            `json:"Cmd,omitempty"`
+	WorkingDir   string              `json:"WorkingDir,omitempty"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+	Labels       map[string]string   `json:"Labels,omitempty"`
+}
+
+// ImageConfig is the OCI image config as trivy reports it.
+type ImageConfig struct {
+	Architecture string     `json:"architecture,omitempty"`
+	OS           string     `json:"os,omitempty"`
+	Variant      string     `json:"variant,omitempty"`
+	Created      *time.Time `json:"created,omitempty"`
+	History      []History  `json:"history,omitempty"`
+	RootFS       RootFS     `json:"rootfs"`
+	Config       Config     `json:"config"`
+}
+
+// ImageMetadata is the artifact metadata of an image scan. It is distinct from
+// Metadata, which describes the vulnerability DB.
+type ImageMetadata struct {
+	OS          *OS         `json:"OS,omitempty"`
+	ImageID     string      `json:"ImageID,omitempty"`
+	DiffIDs     []string    `json:"DiffIDs,omitempty"`
+	RepoTags    []string    `json:"RepoTags,omitempty"`
+	RepoDigests []string    `json:"RepoDigests,omitempty"`
+	ImageConfig ImageConfig `json:"ImageConfig"`
+}
+
+// RepoDigest returns the first "repo@sha256:..." reference of the image.
+func (m *ImageMetadata) RepoDigest() string {
+	if m == nil {
+		return ""
+	}
+	for _, d := range m.RepoDigests {
+		if strings.Contains(d, "@") {
+			return d
+		}
+	}
+	return ""
+}
+
+// Platform formats the image config as os/architecture[/variant].
+func (m *ImageMetadata) Platform() string {
+	if m == nil || m.ImageConfig.OS == "" || m.ImageConfig.Architecture == "" {
+		return ""
+	}
+	p := m.ImageConfig.OS + "/" + m.ImageConfig.Architecture
+	if m.ImageConfig.Variant != "" {
+		p += "/" + m.ImageConfig.Variant
+	}
+	return p
+}
+
+// BaseImage returns the base image recorded in the standard OCI annotation
+// label, if the image was built with one.
+func (m *ImageMetadata) BaseImage() string {
+	if m == nil {
+		return ""
+	}
+	return m.ImageConfig.Config.Labels["org.opencontainers.image.base.name"]
+}
+
+// ImageLayer is one filesystem layer with the history entry that created it
+// and the findings it introduced.
+type ImageLayer struct {
+	Index           int
+	DiffID          string
+	CreatedBy       string
+	Created         *time.Time
+	TotalSeverities Severities
+}
+
+// LayerHistory pairs the non-empty history entries of the image config with
+// its diff IDs, in order, and counts the unsuppressed findings each layer
+// introduced.
+func (r Report) LayerHistory() []ImageLayer {
+	if r.Metadata == nil {
+		return nil
+	}
+
+	diffIDs := r.Metadata.ImageConfig.RootFS.DiffIDs
+	if len(diffIDs) == 0 {
+		diffIDs = r.Metadata.DiffIDs
+	}
+
+	layers := make([]ImageLayer, len(diffIDs))
+	index := map[string]int{}
+	for i, d := range diffIDs {
+		layers[i] = ImageLayer{Index: i + 1, DiffID: d}
+		index[d] = i
+	}
+
+	i := 0
+	for _, h := range r.Metadata.ImageConfig.History {
+		if h.EmptyLayer {
+			continue
+		}
+		if i >= len(layers) {
+			break
+		}
+		layers[i].CreatedBy = h.CreatedBy
+		layers[i].Created = h.Created
+		i++
+	}
+
+	for _, res := range r.Results {
+		for _, v := range res.Vulnerabilities {
+			if v.Layer == nil || v.Suppressed() {
+				continue
+			}
+			j, ok := index[v.Layer.DiffID]
+			if !ok {
+				continue
+			}
+			layers[j].TotalSeverities.Add(v.Severity)
+		}
+	}
+	return layers
+}
+
+// LayerOf returns the layer a finding was introduced in, from r.Layers.
+func (r Report) LayerOf(l *Layer) *ImageLayer {
+	if l == nil {
+		return nil
+	}
+	for i := range r.Layers {
+		if r.Layers[i].DiffID == l.DiffID {
+			return &r.Layers[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package types
+
+import (
+	"testing"
+	"time"
+)
+
+func TestImageMetadata(t *testing.T) {
+	m := &ImageMetadata{ImageConfig: ImageConfig{
+		OS:           "linux",
+		Architecture: "arm",
+		Variant:      "v7",
+		Config:       Config{Labels: map[string]string{"org.opencontainers.image.base.name": "docker.io/library/alpine:3.19"}},
+	}}
+	if p := m.Platform(); p != "linux/arm/v7" {
+		t.Errorf("Platform() = %q", p)
+	}
+	if b := m.BaseImage(); b != "docker.io/library/alpine:3.19" {
+		t.Errorf("BaseImage() = %q", b)
+	}
+	if p := (&ImageMetadata{ImageConfig: ImageConfig{OS: "linux"}}).Platform(); p != "" {
+		t.Errorf("Platform() without architecture = %q", p)
+	}
+
+	var none *ImageMetadata
+	if none.Platform() != "" || none.BaseImage() != "" || none.RepoDigest() != "" {
+		t.Error("a report without metadata must have no platform, base image or digest")
+	}
+}
+
+func TestLayerHistory(t *testing.T) {
+	created := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	r := Report{
+		Metadata: &ImageMetadata{ImageConfig: ImageConfig{
+			History: []History{
+				{CreatedBy: "ADD rootfs.tar.gz /", Created: &created},
+				{CreatedBy: `CMD ["/bin/sh"]`, EmptyLayer: true},
+				{CreatedBy: "RUN apk add openssl"},
+				{CreatedBy: "COPY app /app"},
+			},
+			RootFS: RootFS{DiffIDs: []string{"sha256:base", "sha256:apk", "sha256:app"}},
+		}},
+		Results: []Result{{Vulnerabilities: []Vulnerability{
+			{Severity: "HIGH", Layer: &Layer{DiffID: "sha256:apk"}},
+			{Severity: "CRITICAL", Layer: &Layer{DiffID: "sha256:apk"}},
+			{Severity: "HIGH", Layer: &Layer{DiffID: "sha256:apk"}, Suppression: &Suppression{Source: "exception"}},
+			{Severity: "LOW", Layer: &Layer{DiffID: "sha256:base"}},
+			{Severity: "LOW", Layer: &Layer{DiffID: "sha256:unknown"}},
+			{Severity: "LOW"},
+		}}},
+	}
+
+	layers := r.LayerHistory()
+	if len(layers) != 3 {
+		t.Fatalf("LayerHistory() = %+v", layers)
+	}
+	// empty history entries created no layer
+	want := []struct {
+		createdBy string
+		counts    Severities
+	}{
+		{"ADD rootfs.tar.gz /", Severities{Low: 1}},
+		{"RUN apk add openssl", Severities{Critical: 1, High: 1}},
+		{"COPY app /app", Severities{}},
+	}
+	for i, w := range want {
+		l := layers[i]
+		if l.Index != i+1 || l.DiffID != r.Metadata.ImageConfig.RootFS.DiffIDs[i] || l.CreatedBy != w.createdBy || l.TotalSeverities != w.counts {
+			t.Errorf("layer %d = %+v, want %s with %+v", i, l, w.createdBy, w.counts)
+		}
+	}
+	if layers[0].Created == nil || !layers[0].Created.Equal(created) {
+		t.Errorf("layer 1 created %v", layers[0].Created)
+	}
+
+	r.Layers = layers
+	if l := r.LayerOf(&Layer{DiffID: "sha256:app"}); l == nil || l.Index != 3 {
+		t.Errorf("LayerOf() = %+v", l)
+	}
+	if r.LayerOf(nil) != nil || r.LayerOf(&Layer{DiffID: "sha256:unknown"}) != nil {
+		t.Error("LayerOf() found a layer that is not in the image")
+	}
+}
+
+func TestLayerHistoryDiffIDs(t *testing.T) {
+	// without a rootfs in the config the diff IDs of the metadata are used
+	r := Report{Metadata: &ImageMetadata{DiffIDs: []string{"sha256:a", "sha256:b"}}}
+	if layers := r.LayerHistory(); len(layers) != 2 || layers[1].DiffID != "sha256:b" || layers[1].CreatedBy != "" {
+		t.Errorf("LayerHistory() = %+v", layers)
+	}
+	if layers := (Report{}).LayerHistory(); layers != nil {
+		t.Errorf("LayerHistory() without metadata = %+v", layers)
+	}
+}
@@ -7,6 +7,21 @@ type IndexData struct {
 	Summary             []Summary      `json:"summary"`
 	TotalImages         int
 	TotalVulnerabilties int
+	// Clusters and Namespaces are the filter options from the Kubernetes
+	// inventory, Cluster and Namespace the selected ones.
+	Clusters   []string
+	Namespaces []string
+	Cluster    string
+	Namespace  string
+	// Projects are the projects the viewer may switch to, Project the shown
+	// one, empty for the default project.
+	Projects []string
+	Project  string
+	// Trivy is the version of the scanner and its vulnerability DB, nil when
+	// it could not be determined.
+	Trivy *VersionInfo
+	// User is the name of the viewer when signed in with SSO, who can sign out.
+	User string
 }
 
 type Severities struct {
@@ -15,3 +30,36 @@ type Severities struct {
 	Medium   int
 	Low      int
 }
+
+// Add counts one finding of severity. UNKNOWN findings are not tallied.
+func (s *Severities) Add(severity string) {
+	switch severity {
+	case "CRITICAL":
+		s.Critical++
+	case "HIGH":
+		s.High++
+	case "MEDIUM":
+		s.Medium++
+	case "LOW":
+		s.Low++
+	}
+}
+
+var severityRank = map[string]int{
+	"UNKNOWN":  0,
+	"LOW":      1,
+	"MEDIUM":   2,
+	"HIGH":     3,
+	"CRITICAL": 4,
+}
+
+// SeverityRank orders trivy severities from UNKNOWN (0) to CRITICAL (4).
+func SeverityRank(severity string) int {
+	return severityRank[severity]
+}
+
+// ValidSeverity reports whether severity is one of the trivy severities.
+func ValidSeverity(severity string) bool {
+	_, ok := severityRank[severity]
+	return ok
+}
@@ -0,0 +1,9 @@
+package types
+
+// Workload is a place an image was found running.
+type Workload struct {
+	Cluster   string `json:"cluster"`
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+}
@@ -0,0 +1,90 @@
+package types
+
+import (
+	"sort"
+	"strings"
+)
+
+// platformSep joins an image and a platform into the key of a per-platform
+// report, e.g. "app:1.2+linux/arm64". "+" cannot occur in image references.
+const platformSep = "+"
+
+func PlatformKey(image, platform string) string {
+	return image + platformSep + platform
+}
+
+// SplitPlatformKey returns the image and platform of a per-platform report
+// key, or key and "" for any other key. Platforms always contain a "/", which
+// tells them apart from SBOM versions such as "1.0+build.1".
+func SplitPlatformKey(key string) (string, string) {
+	if i := strings.LastIndex(key, platformSep); i >= 0 && strings.Contains(key[i+1:], "/") {
+		return key[:i], key[i+1:]
+	}
+	return key, ""
+}
+
+// PlatformSummary is one architecture of a multi-platform report.
+type PlatformSummary struct {
+	Platform        string     `json:"Platform"`
+	Digest          string     `json:"Digest,omitempty"`
+	TotalSeverities Severities `json:"-"`
+}
+
+// Combine merges per-platform reports of image into one report. Findings
+// present on several platforms are listed once with all their platforms.
+func Combine(image string, reports []Report) Report {
+	combined := Report{ArtifactName: image}
+	results := map[string]int{}
+	findings := map[string][2]int{}
+
+	sort.Slice(reports, func(i, j int) bool { return reports[i].ArtifactName < reports[j].ArtifactName })
+	for _, r := range reports {
+		_, platform := SplitPlatformKey(r.ArtifactName)
+		combined.Engine = r.Engine
+		combined.Platforms = append(combined.Platforms, PlatformSummary{Platform: platform, Digest: r.Digest})
+
+		for _, res := range r.Results {
+			resultKey := res.Target + "|" + res.Class + "|" + res.Type
+			i, ok := results[resultKey]
+			if !ok {
+				i = len(combined.Results)
+				results[resultKey] = i
+				combined.Results = append(combined.Results, Result{Target: res.Target, Class: res.Class, Type: res.Type})
+			}
+
+			for _, v := range res.Vulnerabilities {
+				key := resultKey + "|" + v.VulnerabilityID + "|" + v.PkgName + "|" + v.InstalledVersion
+				if f, ok := findings[key]; ok {
+					found := &combined.Results[f[0]].Vulnerabilities[f[1]]
+					found.Platforms = append(found.Platforms, platform)
+					continue
+				}
+				v.Platforms = []string{platform}
+				findings[key] = [2]int{i, len(combined.Results[i].Vulnerabilities)}
+				combined.Results[i].Vulnerabilities = append(combined.Results[i].Vulnerabilities, v)
+			}
+		}
+	}
+	return combined
+}
+
+// CountPlatforms fills the severity counts of every platform from the
+// unsuppressed findings that affect it.
+func (r *Report) CountPlatforms() {
+	for i := range r.Platforms {
+		var s Severities
+		for _, res := range r.Results {
+			for _, v := range res.Vulnerabilities {
+				if v.Suppressed() {
+					continue
+				}
+				for _, p := range v.Platforms {
+					if p == r.Platforms[i].Platform {
+						s.Add(v.Severity)
+					}
+				}
+			}
+		}
+		r.Platforms[i].TotalSeverities = s
+	}
+}
@@ -0,0 +1,75 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitPlatformKey(t *testing.T) {
+	tests := []struct {
+		key, image, platform string
+	}{
+		{PlatformKey("app:1.2", "linux/arm64"), "app:1.2", "linux/arm64"},
+		{"registry.example.com:5000/app@sha256:9f2a+linux/arm/v7", "registry.example.com:5000/app@sha256:9f2a", "linux/arm/v7"},
+		{"app:1.2", "app:1.2", ""},
+		// sbom versions may carry build metadata
+		{"app:1.0+build.1", "app:1.0+build.1", ""},
+	}
+	for _, tt := range tests {
+		if image, platform := SplitPlatformKey(tt.key); image != tt.image || platform != tt.platform {
+			t.Errorf("SplitPlatformKey(%q) = %q, %q", tt.key, image, platform)
+		}
+	}
+}
+
+func TestCombine(t *testing.T) {
+	vuln := func(id, pkg, severity string) Vulnerability {
+		return Vulnerability{VulnerabilityID: id, PkgName: pkg, InstalledVersion: "1.0", Severity: severity}
+	}
+	osPkgs := func(vulns ...Vulnerability) Result {
+		return Result{Target: "app:1.2 (alpine 3.19.1)", Class: "os-pkgs", Type: "alpine", Vulnerabilities: vulns}
+	}
+	reports := []Report{
+		{ArtifactName: "app:1.2+linux/arm64", Engine: EngineTrivy, Digest: "sha256:arm", Results: []Result{
+			osPkgs(vuln("CVE-1", "openssl", "HIGH"), vuln("CVE-2", "busybox", "LOW")),
+		}},
+		{ArtifactName: "app:1.2+linux/amd64", Engine: EngineTrivy, Digest: "sha256:amd", Results: []Result{
+			osPkgs(vuln("CVE-1", "openssl", "HIGH")),
+			{Target: "app/go.mod", Class: "lang-pkgs", Type: "gomod", Vulnerabilities: []Vulnerability{vuln("CVE-3", "x/net", "CRITICAL")}},
+		}},
+	}
+
+	r := Combine("app:1.2", reports)
+	if r.ArtifactName != "app:1.2" || r.Engine != EngineTrivy {
+		t.Errorf("Combine() = %+v", r)
+	}
+	// platforms are sorted whatever order their scans finished in
+	if len(r.Platforms) != 2 || r.Platforms[0] != (PlatformSummary{Platform: "linux/amd64", Digest: "sha256:amd"}) || r.Platforms[1].Platform != "linux/arm64" {
+		t.Errorf("platforms = %+v", r.Platforms)
+	}
+	if len(r.Results) != 2 || len(r.Results[0].Vulnerabilities) != 2 || len(r.Results[1].Vulnerabilities) != 1 {
+		t.Fatalf("results = %+v", r.Results)
+	}
+
+	platforms := map[string]string{}
+	for _, res := range r.Results {
+		for _, v := range res.Vulnerabilities {
+			platforms[v.VulnerabilityID] = strings.Join(v.Platforms, ",")
+		}
+	}
+	want := map[string]string{"CVE-1": "linux/amd64,linux/arm64", "CVE-2": "linux/arm64", "CVE-3": "linux/amd64"}
+	for id, p := range want {
+		if platforms[id] != p {
+			t.Errorf("%s on %q, want %q", id, platforms[id], p)
+		}
+	}
+
+	r.Results[0].Vulnerabilities[0].Suppression = &Suppression{Source: "exception"}
+	r.CountPlatforms()
+	if amd := r.Platforms[0].TotalSeverities; amd != (Severities{Critical: 1}) {
+		t.Errorf("linux/amd64 counts %+v", amd)
+	}
+	if arm := r.Platforms[1].TotalSeverities; arm != (Severities{Low: 1}) {
+		t.Errorf("linux/arm64 counts %+v", arm)
+	}
+}
@@ -0,0 +1,40 @@
+package types
+
+import "strings"
+
+// DefaultProject owns everything scanned without a project. Its keys carry no
+// project prefix, so the data of single-tenant installs stays where it was.
+const DefaultProject = "default"
+
+// projectPrefix starts the report and summary keys of a project, e.g.
+// "@team-a/app:1.2". Image references cannot start with "@".
+const projectPrefix = "@"
+
+// ProjectName returns project, or DefaultProject when it is empty.
+func ProjectName(project string) string {
+	if project == "" {
+		return DefaultProject
+	}
+	return project
+}
+
+// ProjectKey namespaces the key of a report or summary to project.
+func ProjectKey(project, key string) string {
+	if ProjectName(project) == DefaultProject {
+		return key
+	}
+	return projectPrefix + project + "/" + key
+}
+
+// SplitProjectKey returns the project and the unprefixed key of a key built
+// with ProjectKey.
+func SplitProjectKey(key string) (string, string) {
+	if !strings.HasPrefix(key, projectPrefix) {
+		return DefaultProject, key
+	}
+	project, rest, ok := strings.Cut(key[len(projectPrefix):], "/")
+	if !ok {
+		return DefaultProject, key
+	}
+	return project, rest
+}
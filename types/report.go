@@ -1,6 +1,9 @@
 package types
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type Layer struct {
 	Digest string `json:"Digest"`
@@ -14,9 +17,39 @@ type CVSSInfo struct {
 	V3Score  *float32 `json:"V3Score,omitempty"`
 }
 
+type PkgIdentifier struct {
+	PURL string `json:"PURL,omitempty"`
+	UID  string `json:"UID,omitempty"`
+}
+
+// Suppression marks a finding that stays visible but is excluded from counts.
+type Suppression struct {
+	Source        string     `json:"Source"`
+	RuleID        string     `json:"RuleID"`
+	Owner         string     `json:"Owner,omitempty"`
+	Justification string     `json:"Justification"`
+	ExpiresAt     *time.Time `json:"ExpiresAt,omitempty"`
+}
+
+// VEXStatement is the status a VEX document assigns to a finding.
+type VEXStatement struct {
+	Status          string `json:"Status"`
+	Justification   string `json:"Justification,omitempty"`
+	ImpactStatement string `json:"ImpactStatement,omitempty"`
+	ActionStatement string `json:"ActionStatement,omitempty"`
+	DocumentID      string `json:"DocumentID"`
+	Author          string `json:"Author,omitempty"`
+}
+
+// Excludes reports whether the VEX status takes the finding out of the counts.
+func (s VEXStatement) Excludes() bool {
+	return s.Status == "not_affected" || s.Status == "fixed"
+}
+
 type Vulnerability struct {
 	VulnerabilityID  string              `json:"VulnerabilityID"`
 	PkgName          string              `json:"PkgName"`
+	PkgIdentifier    *PkgIdentifier      `json:"PkgIdentifier,omitempty"`
 	InstalledVersion string              `json:"InstalledVersion"`
 	FixedVersion     string              `json:"FixedVersion"`
 	Title            string              `json:"Title"`
@@ -27,6 +60,18 @@ type Vulnerability struct {
 	Layer            *Layer              `json:"Layer"`
 	CVSS             map[string]CVSSInfo `json:"CVSS"`
 	CweIDs           []string            `json:"CweIDs"`
+	PublishedDate    *time.Time          `json:"PublishedDate,omitempty"`
+	LastModifiedDate *time.Time          `json:"LastModifiedDate,omitempty"`
+	Suppression      *Suppression        `json:"Suppression,omitempty"`
+	VEX              *VEXStatement       `json:"VEX,omitempty"`
+	// Platforms lists the architectures a finding of a combined multi-platform
+	// report was found on.
+	Platforms []string `json:"Platforms,omitempty"`
+}
+
+// Suppressed reports whether the finding should be left out of severity counts.
+func (v Vulnerability) Suppressed() bool {
+	return v.Suppression != nil || (v.VEX != nil && v.VEX.Excludes())
 }
 
 type Metadata struct {
@@ -41,11 +86,96 @@ type VersionInfo struct {
 
 type Result struct {
 	Target          string          `json:"Target"`
+	Class           string          `json:"Class,omitempty"`
+	Type            string          `json:"Type,omitempty"`
 	Vulnerabilities []Vulnerability `json:"Vulnerabilities"`
 }
 
+// PolicyResult holds the deny and warn messages one Rego policy produced for a
+// report.
+type PolicyResult struct {
+	Policy string   `json:"Policy"`
+	Deny   []string `json:"Deny"`
+	Warn   []string `json:"Warn"`
+	Error  string   `json:"Error,omitempty"`
+}
+
 type Report struct {
-	Results         []Result `json:"Results"`
+	ArtifactName string `json:"ArtifactName,omitempty"`
+	ArtifactType string `json:"ArtifactType,omitempty"`
+	// Engine is the scanner that produced the report, see EngineName.
+	Engine string `json:"Engine,omitempty"`
+	// Project owns the report, empty for the default project.
+	Project  string         `json:"Project,omitempty"`
+	Metadata *ImageMetadata `json:"Metadata,omitempty"`
+	Digest   string         `json:"Digest,omitempty"`
+	Platform string         `json:"Platform,omitempty"`
+	// Platforms is set on the combined report of a multi-platform scan.
+	Platforms     []PlatformSummary `json:"Platforms,omitempty"`
+	Results       []Result          `json:"Results"`
+	PolicyResults []PolicyResult    `json:"PolicyResults,omitempty"`
+	// Comparison is set when a second engine scanned the image as well.
+	Comparison      *Comparison `json:"Comparison,omitempty"`
 	TotalSeverities Severities
+	TotalSuppressed int `json:",omitempty"`
 	LastScanAt      string
-}
\ No newline at end of file
+	// Layers is the layer history with per-layer counts, built for display.
+	Layers []ImageLayer `json:"-"`
+}
+
+// ArtifactKey is the name a report and its summary are stored under. Image
+// scans fall back to the first result target, e.g. "alpine:3.19 (alpine 3.19.1)".
+func (r Report) ArtifactKey() string {
+	if r.ArtifactName != "" {
+		return r.ArtifactName
+	}
+	if len(r.Results) == 0 {
+		return ""
+	}
+	return strings.Split(r.Results[0].Target, " ")[0]
+}
+
+// ResolveDigest fills Digest and Platform from the scanned image's metadata.
+func (r *Report) ResolveDigest() {
+	if d := r.Metadata.RepoDigest(); d != "" {
+		r.Digest = d[strings.LastIndex(d, "@")+1:]
+	}
+	r.Platform = r.Metadata.Platform()
+}
+
+// DigestKey is the "repo@sha256:..." name a report is additionally stored
+// under, so that every build of a moving tag keeps its own report. Platform
+// reports keep their platform suffix.
+func (r Report) DigestKey() string {
+	d := r.Metadata.RepoDigest()
+	if _, platform := SplitPlatformKey(r.ArtifactName); d != "" && platform != "" {
+		return PlatformKey(d, platform)
+	}
+	return d
+}
+
+// CountSeverities tallies the unsuppressed findings of every result by severity.
+func (r Report) CountSeverities() Severities {
+	var s Severities
+	for _, res := range r.Results {
+		for _, v := range res.Vulnerabilities {
+			if !v.Suppressed() {
+				s.Add(v.Severity)
+			}
+		}
+	}
+	return s
+}
+
+// CountSuppressed returns the number of findings excluded from CountSeverities.
+func (r Report) CountSuppressed() int {
+	n := 0
+	for _, res := range r.Results {
+		for _, v := range res.Vulnerabilities {
+			if v.Suppressed() {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,56 @@
+package types
+
+import "testing"
+
+func TestResolveDigest(t *testing.T) {
+	tests := []struct {
+		name     string
+		r        Report
+		digest   string
+		platform string
+		key      string
+	}{
+		{
+			"tagged image",
+			Report{ArtifactName: "alpine:3.19", Metadata: &ImageMetadata{
+				RepoDigests: []string{"alpine@sha256:c5b1261d6d3e"},
+				ImageConfig: ImageConfig{OS: "linux", Architecture: "amd64"},
+			}},
+			"sha256:c5b1261d6d3e", "linux/amd64", "alpine@sha256:c5b1261d6d3e",
+		},
+		{
+			"platform report",
+			Report{ArtifactName: "app:1.0+linux/arm64/v8", Metadata: &ImageMetadata{
+				RepoDigests: []string{"registry.example.com:5000/app@sha256:9f2a"},
+				ImageConfig: ImageConfig{OS: "linux", Architecture: "arm64", Variant: "v8"},
+			}},
+			"sha256:9f2a", "linux/arm64/v8", "registry.example.com:5000/app@sha256:9f2a+linux/arm64/v8",
+		},
+		{
+			"digests without repo are skipped",
+			Report{ArtifactName: "app:1.0", Metadata: &ImageMetadata{RepoDigests: []string{"sha256:0000", "app@sha256:1111"}}},
+			"sha256:1111", "", "app@sha256:1111",
+		},
+		{"local image", Report{ArtifactName: "app:dev", Metadata: &ImageMetadata{}}, "", "", ""},
+		{"sbom", Report{ArtifactName: "app:1.0"}, "", "", ""},
+	}
+	for _, tt := range tests {
+		r := tt.r
+		r.ResolveDigest()
+		if r.Digest != tt.digest || r.Platform != tt.platform || r.DigestKey() != tt.key {
+			t.Errorf("%s: digest %q, platform %q, key %q", tt.name, r.Digest, r.Platform, r.DigestKey())
+		}
+	}
+}
+
+func TestArtifactKey(t *testing.T) {
+	if k := (Report{ArtifactName: "alpine:3.19", Results: []Result{{Target: "other"}}}).ArtifactKey(); k != "alpine:3.19" {
+		t.Errorf("ArtifactKey() = %q", k)
+	}
+	if k := (Report{Results: []Result{{Target: "alpine:3.19 (alpine 3.19.1)"}}}).ArtifactKey(); k != "alpine:3.19" {
+		t.Errorf("ArtifactKey() from the target = %q", k)
+	}
+	if k := (Report{}).ArtifactKey(); k != "" {
+		t.Errorf("ArtifactKey() of an empty report = %q", k)
+	}
+}
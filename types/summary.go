@@ -4,4 +4,6 @@ type Summary struct {
 	Image    string
 	VSummary map[string]int
 	LastScan string
+	// Workloads lists where the image runs, from the Kubernetes inventory.
+	Workloads []Workload
 }
@@ -0,0 +1,18 @@
+package util
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MatchImage reports whether an image reference matches a glob pattern where
+// "*" matches any run of characters, including "/", so that
+// "registry.example.com/payments/*" covers every repository below payments.
+func MatchImage(pattern, image string) bool {
+	if pattern == "" || pattern == "*" {
+		return true
+	}
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+	ok, _ := regexp.MatchString(expr, image)
+	return ok
+}
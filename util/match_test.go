@@ -0,0 +1,31 @@
+package util
+
+import "testing"
+
+func TestMatchImage(t *testing.T) {
+	tests := []struct {
+		pattern string
+		image   string
+		want    bool
+	}{
+		{"", "alpine:3.19", true},
+		{"*", "registry.example.com/team/app:1.0", true},
+		{"alpine:3.19", "alpine:3.19", true},
+		{"alpine:3.19", "alpine:3.20", false},
+		{"alpine", "alpine:3.19", false},
+		{"alpine:*", "alpine:3.19", true},
+		{"registry.example.com/*", "registry.example.com/team/app:1.0", true},
+		{"registry.example.com/*", "registry.example.com.evil.io/app:1.0", false},
+		{"registry.example.com/team/*", "registry.example.com/other/app:1.0", false},
+		{"*/team/*", "registry.example.com/team/app:1.0", true},
+		{"registry.example.com/team/app:1.?", "registry.example.com/team/app:1.0", false},
+		{"registry.example.com/team/app:[0-9]", "registry.example.com/team/app:1", false},
+		{"registry.example.com/team/app:[0-9]", "registry.example.com/team/app:[0-9]", true},
+		{"*@sha256:*", "registry.example.com/team/app@sha256:abcd", true},
+	}
+	for _, tt := range tests {
+		if got := MatchImage(tt.pattern, tt.image); got != tt.want {
+			t.Errorf("MatchImage(%q, %q) = %v, want %v", tt.pattern, tt.image, got, tt.want)
+		}
+	}
+}
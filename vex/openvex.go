@@ -0,0 +1,270 @@
+package vex
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/trivy-web-dash/pkg/reference"
+	"github.com/trivy-web-dash/types"
+	"github.com/trivy-web-dash/util"
+)
+
+var validStatus = map[string]bool{
+	"not_affected":        true,
+	"affected":            true,
+	"fixed":               true,
+	"under_investigation": true,
+}
+
+// Document is an OpenVEX document. Both the v0.0.x string forms and the v0.2
+// object forms of vulnerabilities and products are accepted.
+type Document struct {
+	Context    string      `json:"@context"`
+	ID         string      `json:"@id"`
+	Author     string      `json:"author"`
+	Timestamp  time.Time   `json:"timestamp"`
+	Version    int         `json:"version"`
+	Statements []Statement `json:"statements"`
+}
+
+type Statement struct {
+	Vulnerability   Vulnerability `json:"vulnerability"`
+	Products        []Component   `json:"products"`
+	Status          string        `json:"status"`
+	Justification   string        `json:"justification,omitempty"`
+	ImpactStatement string        `json:"impact_statement,omitempty"`
+	ActionStatement string        `json:"action_statement,omitempty"`
+	Timestamp       *time.Time    `json:"timestamp,omitempty"`
+}
+
+type Vulnerability struct {
+	ID      string   `json:"@id,omitempty"`
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+func (v *Vulnerability) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		v.Name = name
+		return nil
+	}
+	type plain Vulnerability
+	return json.Unmarshal(b, (*plain)(v))
+}
+
+type Component struct {
+	ID            string            `json:"@id,omitempty"`
+	Identifiers   map[string]string `json:"identifiers,omitempty"`
+	Subcomponents []Component       `json:"subcomponents,omitempty"`
+}
+
+func (c *Component) UnmarshalJSON(b []byte) error {
+	var id string
+	if err := json.Unmarshal(b, &id); err == nil {
+		c.ID = id
+		return nil
+	}
+	type plain Component
+	return json.Unmarshal(b, (*plain)(c))
+}
+
+// Parse decodes and validates an OpenVEX document.
+func Parse(b []byte) (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	if !strings.Contains(doc.Context, "openvex") {
+		return nil, errors.New("not an OpenVEX document: @context must reference openvex")
+	}
+	if len(doc.Statements) == 0 {
+		return nil, errors.New("OpenVEX document has no statements")
+	}
+	for _, s := range doc.Statements {
+		if s.Vulnerability.Name == "" {
+			return nil, errors.New("statement without vulnerability name")
+		}
+		if !validStatus[s.Status] {
+			return nil, errors.New("statement with invalid status " + s.Status)
+		}
+		if len(s.Products) == 0 {
+			return nil, errors.New("statement for " + s.Vulnerability.Name + " has no products")
+		}
+	}
+	return &doc, nil
+}
+
+func (s Statement) matchesVulnerability(id string) bool {
+	if strings.EqualFold(s.Vulnerability.Name, id) {
+		return true
+	}
+	for _, a := range s.Vulnerability.Aliases {
+		if strings.EqualFold(a, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// product returns the first product of the statement that describes image.
+func (s Statement) product(image string) (Component, bool) {
+	for _, p := range s.Products {
+		if p.matchesImage(image) {
+			return p, true
+		}
+	}
+	return Component{}, false
+}
+
+func (c Component) ids() []string {
+	ids := []string{c.ID}
+	for _, k := range []string{"purl", "cpe23", "cpe22"} {
+		if v := c.Identifiers[k]; v != "" {
+			ids = append(ids, v)
+		}
+	}
+	return ids
+}
+
+func (c Component) matchesImage(image string) bool {
+	img, err := reference.Parse(image)
+	if err != nil {
+		return false
+	}
+	for _, id := range c.ids() {
+		if id == "" {
+			continue
+		}
+		if strings.HasPrefix(id, "pkg:oci/") || strings.HasPrefix(id, "pkg:docker/") {
+			if p, ok := imageFromPURL(id); ok && sameImage(p, img) {
+				return true
+			}
+			continue
+		}
+		if strings.Contains(id, "*") {
+			if util.MatchImage(id, image) {
+				return true
+			}
+			continue
+		}
+		if p, err := reference.Parse(id); err == nil {
+			if !hasTagOrDigest(id) {
+				p.Tag = ""
+			}
+			if sameImage(p, img) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchesPackage reports whether a subcomponent describes the vulnerable package.
+func (c Component) matchesPackage(v types.Vulnerability) bool {
+	for _, id := range c.ids() {
+		if id == "" {
+			continue
+		}
+		if v.PkgIdentifier != nil && v.PkgIdentifier.PURL != "" && stripQualifiers(id) == stripQualifiers(v.PkgIdentifier.PURL) {
+			return true
+		}
+		name, version := id, ""
+		if strings.HasPrefix(id, "pkg:") {
+			name, version = purlNameVersion(id)
+		}
+		if name == v.PkgName && (version == "" || version == v.InstalledVersion) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameImage compares repository names and, where the VEX product pins them,
+// the tag and digest.
+func sameImage(product, image reference.Reference) bool {
+	if product.Name() != image.Name() {
+		return false
+	}
+	if product.Digest != "" && image.Digest != "" && product.Digest != image.Digest {
+		return false
+	}
+	if product.Tag != "" && product.Digest == "" && product.Tag != image.Tag {
+		return false
+	}
+	return true
+}
+
+// imageFromPURL converts pkg:oci/app@sha256:..?repository_url=reg/team/app&tag=1
+// and pkg:docker/team/app@1?repository_url=reg into an image reference.
+func imageFromPURL(purl string) (reference.Reference, bool) {
+	u, err := url.Parse(purl)
+	if err != nil {
+		return reference.Reference{}, false
+	}
+	typ, rest, _ := strings.Cut(u.Opaque, "/")
+	rest, version, _ := strings.Cut(rest, "@")
+	rest, _ = url.PathUnescape(rest)
+	version, _ = url.PathUnescape(version)
+	q := u.Query()
+
+	var ref string
+	switch typ {
+	case "oci":
+		ref = q.Get("repository_url")
+		if ref == "" {
+			ref = rest
+		}
+	case "docker":
+		ref = rest
+		if repo := q.Get("repository_url"); repo != "" {
+			ref = repo + "/" + rest
+		}
+	default:
+		return reference.Reference{}, false
+	}
+	if tag := q.Get("tag"); tag != "" {
+		ref += ":" + tag
+	}
+
+	r, err := reference.Parse(ref)
+	if err != nil {
+		return reference.Reference{}, false
+	}
+	if strings.HasPrefix(version, "sha256:") {
+		r.Digest = version
+	} else if version != "" {
+		r.Tag = version
+	}
+	if q.Get("tag") == "" && version == "" {
+		// an unversioned product covers every tag of the repository
+		r.Tag = ""
+	}
+	return r, true
+}
+
+func hasTagOrDigest(ref string) bool {
+	last := ref[strings.LastIndex(ref, "/")+1:]
+	return strings.ContainsAny(last, ":@")
+}
+
+func purlNameVersion(purl string) (string, string) {
+	p := stripQualifiers(strings.TrimPrefix(purl, "pkg:"))
+	p, version, _ := strings.Cut(p, "@")
+	if i := strings.LastIndex(p, "/"); i >= 0 {
+		p = p[i+1:]
+	}
+	name, _ := url.PathUnescape(p)
+	version, _ = url.PathUnescape(version)
+	return name, version
+}
+
+func stripQualifiers(purl string) string {
+	if i := strings.IndexAny(purl, "?#"); i >= 0 {
+		return purl[:i]
+	}
+	return purl
+}
@@ -0,0 +1,186 @@
+package vex
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trivy-web-dash/types"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		err  string
+	}{
+		{
+			name: "v0.2 objects",
+			doc: `{"@context": "https://openvex.dev/ns/v0.2.0", "@id": "doc-1", "statements": [{
+				"vulnerability": {"name": "CVE-2024-0001", "aliases": ["GHSA-aaaa"]},
+				"products": [{"@id": "pkg:oci/app", "subcomponents": [{"@id": "pkg:apk/alpine/openssl@3.1.4"}]}],
+				"status": "not_affected", "justification": "vulnerable_code_not_present"}]}`,
+		},
+		{
+			name: "v0.0.x strings",
+			doc: `{"@context": "https://openvex.dev/ns", "statements": [{
+				"vulnerability": "CVE-2024-0001", "products": ["registry.example.com/app"], "status": "fixed"}]}`,
+		},
+		{
+			name: "not openvex",
+			doc:  `{"@context": "https://cyclonedx.org", "statements": []}`,
+			err:  "@context must reference openvex",
+		},
+		{
+			name: "no statements",
+			doc:  `{"@context": "https://openvex.dev/ns/v0.2.0", "statements": []}`,
+			err:  "no statements",
+		},
+		{
+			name: "no vulnerability",
+			doc:  `{"@context": "https://openvex.dev/ns/v0.2.0", "statements": [{"products": ["app"], "status": "fixed"}]}`,
+			err:  "without vulnerability name",
+		},
+		{
+			name: "invalid status",
+			doc:  `{"@context": "https://openvex.dev/ns/v0.2.0", "statements": [{"vulnerability": "CVE-1", "products": ["app"], "status": "wontfix"}]}`,
+			err:  "invalid status wontfix",
+		},
+		{
+			name: "no products",
+			doc:  `{"@context": "https://openvex.dev/ns/v0.2.0", "statements": [{"vulnerability": "CVE-1", "status": "fixed"}]}`,
+			err:  "has no products",
+		},
+		{
+			name: "not json",
+			doc:  `{`,
+			err:  "unexpected end of JSON input",
+		},
+	}
+	for _, tt := range tests {
+		doc, err := Parse([]byte(tt.doc))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: Parse() error = %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Parse() error = %v", tt.name, err)
+			continue
+		}
+		if doc.Statements[0].Vulnerability.Name != "CVE-2024-0001" || len(doc.Statements[0].Products) != 1 {
+			t.Errorf("%s: statement = %+v", tt.name, doc.Statements[0])
+		}
+	}
+}
+
+func TestMatchesImage(t *testing.T) {
+	tests := []struct {
+		product string
+		image   string
+		want    bool
+	}{
+		{"registry.example.com/team/app", "registry.example.com/team/app:1.0", true},
+		{"registry.example.com/team/app:1.0", "registry.example.com/team/app:1.0", true},
+		{"registry.example.com/team/app:1.0", "registry.example.com/team/app:2.0", false},
+		{"registry.example.com/team/app", "registry.example.com/team/other:1.0", false},
+		{"registry.example.com/team/*", "registry.example.com/team/other:1.0", true},
+		{"alpine", "docker.io/library/alpine:3.19", true},
+		{"pkg:oci/app?repository_url=registry.example.com/team/app", "registry.example.com/team/app:1.0", true},
+		{"pkg:oci/app?repository_url=registry.example.com/team/app&tag=1.0", "registry.example.com/team/app:2.0", false},
+		{"pkg:oci/app@sha256:aaaa?repository_url=registry.example.com/team/app", "registry.example.com/team/app@sha256:aaaa", true},
+		{"pkg:oci/app@sha256:aaaa?repository_url=registry.example.com/team/app", "registry.example.com/team/app@sha256:bbbb", false},
+		{"pkg:docker/team/app@1.0?repository_url=registry.example.com", "registry.example.com/team/app:1.0", true},
+		{"pkg:docker/team/app@1.0?repository_url=registry.example.com", "registry.example.com/team/app:1.1", false},
+		{"pkg:npm/lodash@4.17.20", "registry.example.com/team/app:1.0", false},
+	}
+	for _, tt := range tests {
+		if got := (Component{ID: tt.product}).matchesImage(tt.image); got != tt.want {
+			t.Errorf("product %q matches %q = %v, want %v", tt.product, tt.image, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesPackage(t *testing.T) {
+	v := types.Vulnerability{
+		PkgName:          "openssl",
+		InstalledVersion: "3.1.4-r0",
+		PkgIdentifier:    &types.PkgIdentifier{PURL: "pkg:apk/alpine/openssl@3.1.4-r0?arch=x86_64"},
+	}
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"pkg:apk/alpine/openssl@3.1.4-r0", true},
+		{"pkg:apk/alpine/openssl@3.1.4-r0?distro=3.19", true},
+		{"pkg:apk/alpine/openssl", true},
+		{"pkg:apk/alpine/openssl@3.1.5-r0", false},
+		{"openssl", true},
+		{"zlib", false},
+	}
+	for _, tt := range tests {
+		if got := (Component{ID: tt.id}).matchesPackage(v); got != tt.want {
+			t.Errorf("subcomponent %q matches openssl = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	day := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	report := func() *types.Report {
+		return &types.Report{Results: []types.Result{{Vulnerabilities: []types.Vulnerability{
+			{VulnerabilityID: "CVE-2024-0001", PkgName: "openssl", InstalledVersion: "3.1.4"},
+			{VulnerabilityID: "CVE-2024-0001", PkgName: "libcrypto3", InstalledVersion: "3.1.4"},
+			{VulnerabilityID: "GHSA-bbbb", PkgName: "zlib", InstalledVersion: "1.3"},
+		}}}}
+	}
+	statement := func(vuln, status string, subcomponents ...string) Statement {
+		p := Component{ID: "registry.example.com/team/app"}
+		for _, s := range subcomponents {
+			p.Subcomponents = append(p.Subcomponents, Component{ID: s})
+		}
+		return Statement{Vulnerability: Vulnerability{Name: vuln, Aliases: []string{"GHSA-bbbb"}}, Products: []Component{p}, Status: status}
+	}
+	const image = "registry.example.com/team/app:1.0"
+
+	r := report()
+	docs := []Document{{ID: "doc-1", Author: "sec", Timestamp: day, Statements: []Statement{
+		statement("CVE-2024-0001", "not_affected", "openssl"),
+		statement("CVE-2024-0009", "fixed"),
+	}}}
+	if !AnyMatchImage(docs, image) || AnyMatchImage(docs, "registry.example.com/team/other:1.0") {
+		t.Error("AnyMatchImage does not follow the product")
+	}
+	if n := Apply(docs, image, r); n != 2 {
+		t.Errorf("Apply() = %d, want 2", n)
+	}
+	vulns := r.Results[0].Vulnerabilities
+	if vulns[0].VEX == nil || vulns[0].VEX.Status != "not_affected" || vulns[0].VEX.DocumentID != "doc-1" || vulns[0].VEX.Author != "sec" {
+		t.Errorf("openssl vex = %+v", vulns[0].VEX)
+	}
+	if vulns[1].VEX != nil {
+		t.Errorf("libcrypto3 is not a listed subcomponent but got %+v", vulns[1].VEX)
+	}
+	if vulns[2].VEX == nil || vulns[2].VEX.Status != "fixed" {
+		t.Errorf("zlib matched by alias vex = %+v", vulns[2].VEX)
+	}
+	if !vulns[0].Suppressed() || vulns[1].Suppressed() {
+		t.Error("not_affected findings must be suppressed, others not")
+	}
+
+	if n := Apply(docs, "registry.example.com/team/other:1.0", report()); n != 0 {
+		t.Errorf("Apply() to another image = %d, want 0", n)
+	}
+
+	// newer statements win whatever order the documents come in
+	older := Document{ID: "old", Timestamp: day, Statements: []Statement{statement("CVE-2024-0001", "not_affected")}}
+	newer := Document{ID: "new", Timestamp: day.Add(time.Hour), Statements: []Statement{statement("CVE-2024-0001", "affected")}}
+	for _, docs := range [][]Document{{older, newer}, {newer, older}} {
+		r := report()
+		Apply(docs, image, r)
+		if v := r.Results[0].Vulnerabilities[0].VEX; v.DocumentID != "new" || v.Status != "affected" || r.Results[0].Vulnerabilities[0].Suppressed() {
+			t.Errorf("vex after %s then %s = %+v, want the newer affected statement", docs[0].ID, docs[1].ID, v)
+		}
+	}
+}
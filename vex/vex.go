@@ -0,0 +1,200 @@
+package vex
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/trivy-web-dash/pkg/db"
+	redisx "github.com/trivy-web-dash/pkg/db/redis"
+	"github.com/trivy-web-dash/pkg/logger"
+	"github.com/trivy-web-dash/types"
+)
+
+const keyPrefix = "vex/"
+
+var ErrNotFound = errors.New("vex document not found")
+
+type VEXClient struct {
+	client db.Store
+	log    logger.Logger
+}
+
+var vexClient *VEXClient
+
+func NewVEXClient(redisURI, redisPass string, redisDB int, redisTLS, redisTLSSkipVerify bool, log logger.Logger) error {
+	pool, err := redisx.NewPool(redisURI, redisPass, redisDB, redisTLS, redisTLSSkipVerify)
+	if err != nil {
+		return err
+	}
+
+	vexClient = &VEXClient{client: redisx.NewStore(pool), log: log}
+	return nil
+}
+
+func GetVEXClient() *VEXClient {
+	return vexClient
+}
+
+// Key identifies a stored document; uploading a document with the same @id
+// replaces the previous version.
+func Key(doc Document) string {
+	sum := sha256.Sum256([]byte(doc.ID))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (c *VEXClient) Upload(ctx context.Context, b []byte) (*Document, error) {
+	doc, err := Parse(b)
+	if err != nil {
+		return nil, err
+	}
+	if doc.ID == "" {
+		sum := sha256.Sum256(b)
+		doc.ID = "urn:sha256:" + hex.EncodeToString(sum[:])
+	}
+
+	value, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.client.WithContext(ctx).Set(keyPrefix+Key(*doc), value); err != nil {
+		c.log.Error(err)
+		return nil, err
+	}
+	return doc, nil
+}
+
+func (c *VEXClient) Get(ctx context.Context, key string) (*Document, error) {
+	b, _, err := c.client.WithContext(ctx).GetwithTTL(keyPrefix + key)
+	if errors.Is(err, redis.ErrNil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		c.log.Error(err)
+		return nil, err
+	}
+
+	var doc Document
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+func (c *VEXClient) Delete(ctx context.Context, key string) error {
+	if _, err := c.Get(ctx, key); err != nil {
+		return err
+	}
+	return c.client.WithContext(ctx).Delete(keyPrefix + key)
+}
+
+// List returns all documents, oldest first, which is the order statements are
+// applied in so that newer documents win.
+func (c *VEXClient) List(ctx context.Context) ([]Document, error) {
+	keys, err := c.client.WithContext(ctx).GetAllKeys(keyPrefix + "*")
+	if err != nil {
+		c.log.Error(err)
+		return nil, err
+	}
+
+	docs := []Document{}
+	for _, key := range keys {
+		doc, err := c.Get(ctx, strings.TrimPrefix(key, keyPrefix))
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, *doc)
+	}
+
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Timestamp.Before(docs[j].Timestamp) })
+	return docs, nil
+}
+
+// AnyMatchImage reports whether a statement of docs names image as a product.
+func AnyMatchImage(docs []Document, image string) bool {
+	for _, doc := range docs {
+		for _, s := range doc.Statements {
+			if _, ok := s.product(image); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Apply sets the VEX status of every finding of report covered by docs and
+// returns how many findings were marked. Later statements override earlier ones.
+func Apply(docs []Document, image string, report *types.Report) int {
+	type match struct {
+		doc       Document
+		statement Statement
+		product   Component
+	}
+
+	var matches []match
+	for _, doc := range docs {
+		for _, s := range doc.Statements {
+			if p, ok := s.product(image); ok {
+				matches = append(matches, match{doc: doc, statement: s, product: p})
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return statementTime(matches[i].doc, matches[i].statement).Before(statementTime(matches[j].doc, matches[j].statement))
+	})
+	if len(matches) == 0 {
+		return 0
+	}
+
+	n := 0
+	for i := range report.Results {
+		vulns := report.Results[i].Vulnerabilities
+		for j := range vulns {
+			for _, m := range matches {
+				if !m.statement.matchesVulnerability(vulns[j].VulnerabilityID) {
+					continue
+				}
+				if len(m.product.Subcomponents) > 0 && !anyPackage(m.product.Subcomponents, vulns[j]) {
+					continue
+				}
+				if vulns[j].VEX == nil {
+					n++
+				}
+				vulns[j].VEX = &types.VEXStatement{
+					Status:          m.statement.Status,
+					Justification:   m.statement.Justification,
+					ImpactStatement: m.statement.ImpactStatement,
+					ActionStatement: m.statement.ActionStatement,
+					DocumentID:      m.doc.ID,
+					Author:          m.doc.Author,
+				}
+			}
+		}
+	}
+	return n
+}
+
+func anyPackage(components []Component, v types.Vulnerability) bool {
+	for _, c := range components {
+		if c.matchesPackage(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func statementTime(doc Document, s Statement) time.Time {
+	if s.Timestamp != nil {
+		return *s.Timestamp
+	}
+	return doc.Timestamp
+}